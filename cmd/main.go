@@ -1,25 +1,60 @@
 package main
 
 import (
+	"context"
+	"log"
+	"net"
 	"os"
+	"strings"
+	"time"
+	_ "time/tzdata" // the image has no time zone database, user time zones are checked against it
 
 	"github.com/AthanatiusC/SawitPro/generated"
 	"github.com/AthanatiusC/SawitPro/handler"
+	"github.com/AthanatiusC/SawitPro/notification"
+	"github.com/AthanatiusC/SawitPro/phonenumber"
 	"github.com/AthanatiusC/SawitPro/repository"
+	"github.com/AthanatiusC/SawitPro/storage"
+	"github.com/AthanatiusC/SawitPro/worker"
 
 	"github.com/labstack/echo/v4"
 )
 
 func main() {
 	e := echo.New()
+	e.IPExtractor = newIPExtractor()
 
-	var server generated.ServerInterface = newServer()
+	blobs := newBlobStore(e)
+	server := newServer(blobs)
+	e.HTTPErrorHandler = server.HTTPErrorHandler
+	e.Use(server.AuditContext)
+	e.Use(server.AuditImpersonation)
+	e.Use(server.Localize)
+	e.Use(server.ValidateContract)
+
+	purger := worker.NewPurger(worker.NewPurgerOptions{
+		Repository:  server.Repository,
+		GracePeriod: server.DeletionGracePeriod,
+		Blobs:       blobs,
+		Interval:    time.Hour,
+		Logger:      log.New(os.Stdout, "purge ", log.LstdFlags),
+	})
+	go purger.Run(context.Background())
+
+	exporter := worker.NewExporter(worker.NewExporterOptions{
+		Repository: server.Repository,
+		TTL:        parseDuration("DATA_EXPORT_TTL"),
+		Timeout:    parseDuration("DATA_EXPORT_TIMEOUT"),
+		Interval:   time.Minute,
+		Logger:     log.New(os.Stdout, "export ", log.LstdFlags),
+	})
+	go exporter.Run(context.Background())
 
 	generated.RegisterHandlers(e, server)
 	e.Logger.Fatal(e.Start(":1323"))
 }
 
-func newServer() *handler.Server {
+func newServer(blobs storage.BlobStore) *handler.Server {
 	dbDsn := os.Getenv("DATABASE_URL")
 	secret := os.Getenv("SECRET")
 
@@ -28,9 +63,105 @@ func newServer() *handler.Server {
 	})
 
 	opts := handler.NewServerOptions{
-		Repository: repo,
-		Secret:     secret,
+		Repository:           repo,
+		Secret:               secret,
+		IntrospectionClients: parseClients(os.Getenv("INTROSPECTION_CLIENTS")),
+		Notifier:             notification.NewLogNotifier(log.New(os.Stdout, "", log.LstdFlags)),
+		OTPSender:            notification.NewLogOTPSender(log.New(os.Stdout, "", log.LstdFlags)),
+		Mailer:               newMailer(),
+		EmailVerificationURL: os.Getenv("EMAIL_VERIFICATION_URL"),
+		PhoneNumbers:         newPhoneNumberParser(),
+		DeletionGracePeriod:  parseDuration("ACCOUNT_DELETION_GRACE_PERIOD"),
+		ValidateResponses:    os.Getenv("VALIDATE_RESPONSES") == "true",
+		Blobs:                blobs,
 	}
 
 	return handler.NewServer(opts)
 }
+
+// Keep blobs in BLOB_DIR, served under BLOB_BASE_URL. They are served by e unless BLOB_BASE_URL is absolute,
+// in which case something else e.g a CDN serves BLOB_DIR
+func newBlobStore(e *echo.Echo) storage.BlobStore {
+	dir := os.Getenv("BLOB_DIR")
+	if dir == "" {
+		dir = "./blobs"
+	}
+	baseURL := os.Getenv("BLOB_BASE_URL")
+	if baseURL == "" {
+		baseURL = "/blobs"
+	}
+
+	if strings.HasPrefix(baseURL, "/") {
+		e.Static(baseURL, dir)
+	}
+	return storage.NewLocalStore(dir, baseURL)
+}
+
+/*
+Take the client ip from X-Forwarded-For only when the request comes from one of the comma separated TRUSTED_PROXIES
+ranges in CIDR notation e.g 10.0.0.0/8, the address of the connection is used otherwise.
+Nothing is trusted by default, a client connecting directly could forge the header
+*/
+func newIPExtractor() echo.IPExtractor {
+	options := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, value := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if value = strings.TrimSpace(value); value == "" {
+			continue
+		}
+		_, ipRange, err := net.ParseCIDR(value)
+		if err != nil {
+			log.Fatalf("invalid TRUSTED_PROXIES: %v", err)
+		}
+		options = append(options, echo.TrustIPRange(ipRange))
+	}
+	return echo.ExtractIPFromXFFHeader(options...)
+}
+
+// Write emails to MAIL_DIR when set so they can be opened locally, log them otherwise
+func newMailer() notification.Mailer {
+	if dir := os.Getenv("MAIL_DIR"); dir != "" {
+		return notification.NewFileMailer(dir)
+	}
+	return notification.NewLogMailer(log.New(os.Stdout, "", log.LstdFlags))
+}
+
+// Allow phone numbers of the comma separated PHONE_REGIONS, Indonesian numbers only when unset
+func newPhoneNumberParser() *phonenumber.Parser {
+	regions := []string{"ID"}
+	if value := os.Getenv("PHONE_REGIONS"); value != "" {
+		regions = strings.Split(value, ",")
+	}
+
+	parser, err := phonenumber.NewParser(regions...)
+	if err != nil {
+		log.Fatalf("invalid PHONE_REGIONS: %v", err)
+	}
+	return parser
+}
+
+// Parse comma separated client_id:client_secret pairs into a lookup map
+func parseClients(value string) map[string]string {
+	clients := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		id, secret, found := strings.Cut(strings.TrimSpace(pair), ":")
+		if !found || id == "" {
+			continue
+		}
+		clients[id] = secret
+	}
+	return clients
+}
+
+// Parse an optional duration envar e.g 720h, returns 0 when unset so the server default applies
+func parseDuration(key string) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return 0
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("invalid %s: %v", key, err)
+	}
+	return duration
+}
@@ -7,19 +7,54 @@
   In this assignment we will use PostgreSQL as the database.
   */
 
+/**
+  pg_trgm gives trigram similarity for typo tolerant name search, unaccent strips diacritics e.g é to e
+  unaccent() is only STABLE so it is wrapped in an IMMUTABLE function to be usable in an index
+*/
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE EXTENSION IF NOT EXISTS unaccent;
+
+CREATE OR REPLACE FUNCTION immutable_unaccent(value text) RETURNS text AS $$
+  SELECT public.unaccent('public.unaccent', value)
+$$ LANGUAGE sql IMMUTABLE PARALLEL SAFE STRICT;
+
 /**
   id serial, primary key user identifier
   name varchar(60), bussiness requirements to limit name to 60 characters
-  phone varchar(13), Indonesian phone number have 9-13 digits length e.g 628xxxxxxxxxx
+  phone varchar(16), E.164 phone number of one of the allowed regions e.g +628xxxxxxxxxx, at most 15 digits after the +
+  email varchar(254), optional second sign in identifier, lower cased, only set once verified so it cannot be claimed for someone else
+  email_verified_at timestamp, time the email was verified
+  locale varchar(8), language of messages chosen by the user e.g id, null to follow Accept-Language
+  timezone varchar(64), IANA time zone chosen by the user e.g Asia/Jakarta, null to follow the client
+  avatar varchar(64), blob key prefix of the avatar images e.g avatars/1/5f2b..., null when the user has none
+  attributes jsonb, custom attributes by name e.g {"estate_code": "KLT-02"}, defined in attribute_definitions
   password varchar(72), store password in bcrypt+salt which have 72 character limit
+  role varchar(16), authorization role of the user e.g user or admin
+  status varchar(16), lifecycle status, active, suspended (temporarily blocked) or disabled (permanently blocked), only active users can sign in
+  token_version integer, embedded in issued tokens, increment to invalidate every token of the user
+  version integer, incremented on every update of the row, exposed as the profile ETag for optimistic concurrency
+  deleted_at timestamp, set when the user deletes the account, the account is hidden and can be restored during the grace period
+  purged_at timestamp, set when the account is anonymized after the grace period, it cannot be restored anymore
   updated_at timestamp, to track last time data was updated
   created_at timestamp, to track when data was created
 */
 CREATE TABLE IF NOT EXISTS users (
 	id serial PRIMARY KEY,
 	name VARCHAR(60) NOT NULL,
-  phone VARCHAR(13) UNIQUE NOT NULL, 
+  phone VARCHAR(16) UNIQUE NOT NULL, 
+  email VARCHAR(254) UNIQUE,
+  email_verified_at TIMESTAMP,
+  locale VARCHAR(8),
+  timezone VARCHAR(64),
+  avatar VARCHAR(64),
+  attributes JSONB NOT NULL DEFAULT '{}',
   password VARCHAR(74) NOT NULL,
+  role VARCHAR(16) NOT NULL DEFAULT 'user',
+  status VARCHAR(16) NOT NULL DEFAULT 'active',
+  token_version INTEGER NOT NULL DEFAULT 0,
+  version INTEGER NOT NULL DEFAULT 1,
+  deleted_at TIMESTAMP,
+  purged_at TIMESTAMP,
   updated_at TIMESTAMP DEFAULT NOW(),
   created_at TIMESTAMP DEFAULT NOW()
 );
@@ -31,7 +66,316 @@ Create unique index for column users phone and users password, phone can be used
 CREATE UNIQUE INDEX index_user_id ON users(id);
 CREATE UNIQUE INDEX index_user_phone_and_password ON users(phone,password);
 
--- I would like to make a audit trail but i think it is unecessary in this case
+/**
+Create partial index for column deleted_at, the purge job scans deleted accounts which are not purged yet
+*/
+CREATE INDEX index_user_pending_deletion ON users(deleted_at) WHERE deleted_at IS NOT NULL AND purged_at IS NULL;
+
+/**
+Create index for columns created_at and id, updated_at and id, name and id, the admin user listing pages through users sorted by one of them
+Create index for column phone with pattern ops, the admin user listing filters by phone prefix
+*/
+CREATE INDEX index_user_created_at ON users(created_at, id);
+CREATE INDEX index_user_updated_at ON users(updated_at, id);
+CREATE INDEX index_user_name ON users(name, id);
+CREATE INDEX index_user_phone_prefix ON users(phone varchar_pattern_ops);
+
+/**
+Create trigram index on the lower cased, unaccented name, the fuzzy name search matches against it
+*/
+CREATE INDEX index_user_name_trigram ON users USING GIN (immutable_unaccent(lower(name)) gin_trgm_ops);
+
+/**
+Create GIN index on column attributes, the admin user listing filters by attribute values with containment (@>)
+Unique attributes get their own unique index index_user_attribute_<name>, created and dropped with their definition
+*/
+CREATE INDEX index_user_attributes ON users USING GIN (attributes jsonb_path_ops);
+
+/**
+  name varchar(32), primary key, key of the attribute in users.attributes, lower case letters, digits and underscores
+  type varchar(16), type of the values, string, number or boolean
+  required boolean, every user must have a value when registering or when an admin sets the attributes
+  allowed_values jsonb, array of the values accepted, null accepts any value of the type
+  is_unique boolean, no two users can have the same value, enforced by index_user_attribute_<name>
+  description varchar(255), what the attribute holds, shown to admins
+  updated_at timestamp, to track last time data was updated
+  created_at timestamp, to track when data was created
+*/
+CREATE TABLE IF NOT EXISTS attribute_definitions (
+  name VARCHAR(32) PRIMARY KEY,
+  type VARCHAR(16) NOT NULL,
+  required BOOLEAN NOT NULL DEFAULT FALSE,
+  allowed_values JSONB,
+  is_unique BOOLEAN NOT NULL DEFAULT FALSE,
+  description VARCHAR(255) NOT NULL DEFAULT '',
+  updated_at TIMESTAMP DEFAULT NOW(),
+  created_at TIMESTAMP DEFAULT NOW()
+);
+
+/**
+  id serial, primary key api key identifier
+  user_id integer, owner of the key, keys are removed together with the user
+  name varchar(60), label given by the user to tell keys apart
+  prefix varchar(16), first characters of the key, safe to display since the key is only shown once
+  key_hash char(64), hex sha256 of the key, keys are high entropy random values so a fast hash is sufficient
+  scopes text[], scopes granted to requests authenticated by this key
+  expires_at timestamp, optional expiry, null means the key does not expire
+  last_used_at timestamp, last time the key authenticated a request
+  revoked_at timestamp, set when the user revokes the key, kept for history instead of deleting
+  created_at timestamp, to track when data was created
+*/
+CREATE TABLE IF NOT EXISTS api_keys (
+  id serial PRIMARY KEY,
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  name VARCHAR(60) NOT NULL,
+  prefix VARCHAR(16) NOT NULL,
+  key_hash CHAR(64) UNIQUE NOT NULL,
+  scopes TEXT[] NOT NULL DEFAULT '{}',
+  expires_at TIMESTAMP,
+  last_used_at TIMESTAMP,
+  revoked_at TIMESTAMP,
+  created_at TIMESTAMP DEFAULT NOW()
+);
+
+/**
+Create index for column api_keys user_id, keys are listed per user
+key_hash lookup is covered by its unique constraint
+*/
+CREATE INDEX index_api_key_user_id ON api_keys(user_id);
+
+/**
+  id serial, primary key session identifier, carried in the sid claim of tokens issued by login
+  user_id integer, owner of the session, sessions are removed together with the user
+  device_name varchar(60), name given by the client on login or derived from the user agent
+  user_agent varchar(255), ip varchar(45), client which logged in, 45 characters fits IPv6
+  expires_at timestamp, expiry of the token issued with the session
+  last_seen_at timestamp, last time a token of the session was used
+  revoked_at timestamp, set when the user signs the session out, tokens of the session are refused
+  created_at timestamp, login time
+*/
+CREATE TABLE IF NOT EXISTS sessions (
+  id serial PRIMARY KEY,
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  device_name VARCHAR(60) NOT NULL,
+  user_agent VARCHAR(255) NOT NULL DEFAULT '',
+  ip VARCHAR(45) NOT NULL DEFAULT '',
+  expires_at TIMESTAMP NOT NULL,
+  last_seen_at TIMESTAMP DEFAULT NOW(),
+  revoked_at TIMESTAMP,
+  created_at TIMESTAMP DEFAULT NOW()
+);
+
+/**
+Create index for column sessions user_id, sessions are listed per user
+*/
+CREATE INDEX index_session_user_id ON sessions(user_id);
+
+/**
+  id bigserial, primary key attempt identifier, grows with every login request
+  user_id integer, account the phone number belongs to, null when the phone number is not registered
+  phone varchar(16), phone number as submitted after normalizing, kept for attempts on unknown accounts
+  success boolean, outcome of the attempt
+  reason varchar(32), why the attempt failed e.g invalid_password, empty on success
+  ip varchar(45), user_agent varchar(255), client which attempted, 45 characters fits IPv6
+  device_fingerprint char(64), hex sha256 of the client identity, used to detect new devices
+  created_at timestamp, attempt time
+*/
+CREATE TABLE IF NOT EXISTS login_attempts (
+  id bigserial PRIMARY KEY,
+  user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+  phone VARCHAR(16) NOT NULL DEFAULT '',
+  success BOOLEAN NOT NULL,
+  reason VARCHAR(32) NOT NULL DEFAULT '',
+  ip VARCHAR(45) NOT NULL DEFAULT '',
+  user_agent VARCHAR(255) NOT NULL DEFAULT '',
+  device_fingerprint CHAR(64) NOT NULL,
+  created_at TIMESTAMP DEFAULT NOW()
+);
+
+/**
+Create index for columns user_id and id, history is listed per user newest first
+Create index for columns user_id and device_fingerprint on successful attempts, to detect new devices on login
+*/
+CREATE INDEX index_login_attempt_user_id ON login_attempts(user_id, id);
+CREATE INDEX index_login_attempt_user_device ON login_attempts(user_id, device_fingerprint) WHERE success;
+
+/**
+  id bigserial, primary key event identifier, expected to grow faster than users
+  actor_id integer, admin who impersonated
+  target_id integer, user being impersonated
+  action varchar(16), start when the token is issued, request for every request made with it
+  reason varchar(255), justification given by the admin on start
+  method varchar(8), path varchar(255), status smallint, request made with the impersonation token
+  ip varchar(45), user_agent varchar(255), client of the admin, 45 characters fits IPv6
+  created_at timestamp, to track when the event happened
+  Rows are append only and reference users without cascade so history outlives accounts
+*/
+CREATE TABLE IF NOT EXISTS impersonation_events (
+  id bigserial PRIMARY KEY,
+  actor_id INTEGER NOT NULL REFERENCES users(id),
+  target_id INTEGER NOT NULL REFERENCES users(id),
+  action VARCHAR(16) NOT NULL,
+  reason VARCHAR(255) NOT NULL DEFAULT '',
+  method VARCHAR(8) NOT NULL DEFAULT '',
+  path VARCHAR(255) NOT NULL DEFAULT '',
+  status SMALLINT NOT NULL DEFAULT 0,
+  ip VARCHAR(45) NOT NULL DEFAULT '',
+  user_agent VARCHAR(255) NOT NULL DEFAULT '',
+  created_at TIMESTAMP DEFAULT NOW()
+);
+
+/**
+Create index for columns actor_id and target_id, events are reviewed per admin or per user
+*/
+CREATE INDEX index_impersonation_event_actor_id ON impersonation_events(actor_id, created_at);
+CREATE INDEX index_impersonation_event_target_id ON impersonation_events(target_id, created_at);
+
+/**
+  id bigserial, primary key event identifier
+  user_id integer, user whose status changed
+  actor_id integer, admin who changed the status
+  from_status varchar(16), to_status varchar(16), status before and after the change
+  reason varchar(255), justification given by the admin
+  created_at timestamp, to track when the status changed
+  Rows are append only and reference users without cascade so history outlives accounts
+*/
+CREATE TABLE IF NOT EXISTS user_status_events (
+  id bigserial PRIMARY KEY,
+  user_id INTEGER NOT NULL REFERENCES users(id),
+  actor_id INTEGER NOT NULL REFERENCES users(id),
+  from_status VARCHAR(16) NOT NULL,
+  to_status VARCHAR(16) NOT NULL,
+  reason VARCHAR(255) NOT NULL,
+  created_at TIMESTAMP DEFAULT NOW()
+);
+
+/**
+Create index for column user_id, the status history is read per user
+*/
+CREATE INDEX index_user_status_event_user_id ON user_status_events(user_id, id);
+
+/**
+  id serial, primary key export identifier
+  user_id integer, user who requested the export and whose data it contains
+  format varchar(8), archive format, json or zip
+  status varchar(16), pending, running, completed, failed or expired
+  error varchar(255), why the export failed, empty otherwise
+  archive bytea, generated archive, dropped once expired, archives are small since they hold a single user
+  expires_at timestamp, time the archive stops being downloadable
+  started_at timestamp, time a worker claimed the export, a running export started before the timeout is claimed again
+  completed_at timestamp, time the export completed or failed
+  created_at timestamp, time the export was requested
+*/
+CREATE TABLE IF NOT EXISTS data_exports (
+  id serial PRIMARY KEY,
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  format VARCHAR(8) NOT NULL,
+  status VARCHAR(16) NOT NULL DEFAULT 'pending',
+  error VARCHAR(255) NOT NULL DEFAULT '',
+  archive BYTEA,
+  expires_at TIMESTAMP,
+  started_at TIMESTAMP,
+  completed_at TIMESTAMP,
+  created_at TIMESTAMP DEFAULT NOW()
+);
+
+/**
+Create unique partial index for column user_id, a user can only have one export in progress
+Create partial index for column id on exports in progress, the worker claims the oldest pending or abandoned export
+*/
+CREATE UNIQUE INDEX index_data_export_user_in_progress ON data_exports(user_id) WHERE status IN ('pending', 'running');
+CREATE INDEX index_data_export_in_progress ON data_exports(id) WHERE status IN ('pending', 'running');
+
+/**
+  id serial, primary key email verification identifier
+  user_id integer, user adding the email
+  email varchar(254), lower cased email, becomes users.email once verified
+  token_hash varchar(64), sha256 hash of the token of the emailed link, the token is never stored
+  expires_at timestamp, time the link stops working
+  verified_at timestamp, time the link was followed, null while pending
+  created_at timestamp, time the verification was requested
+*/
+CREATE TABLE IF NOT EXISTS email_verifications (
+  id serial PRIMARY KEY,
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  email VARCHAR(254) NOT NULL,
+  token_hash VARCHAR(64) NOT NULL,
+  expires_at TIMESTAMP NOT NULL,
+  verified_at TIMESTAMP,
+  created_at TIMESTAMP DEFAULT NOW()
+);
+
+/**
+Create unique partial index for column user_id, a user can only have one pending email verification
+Create unique index for column token_hash, links are looked up by token
+*/
+CREATE UNIQUE INDEX index_email_verification_user_pending ON email_verifications(user_id) WHERE verified_at IS NULL;
+CREATE UNIQUE INDEX index_email_verification_token_hash ON email_verifications(token_hash);
+
+/**
+  id serial, primary key phone change identifier
+  user_id integer, user changing their phone number
+  phone varchar(16), new phone number in E.164, becomes users.phone once verified
+  code_hash varchar(64), keyed hash of the one time password sent to the new number, the code is never stored
+  attempts smallint, codes submitted, the change is void after too many
+  expires_at timestamp, time the code stops being accepted
+  verified_at timestamp, time the code was verified and the number switched, null while pending
+  cancelled_at timestamp, time the change was cancelled or replaced by another, null while pending. Cancelled changes
+    are kept so the time between two codes is enforced across cancellations
+  created_at timestamp, time the change was requested
+*/
+CREATE TABLE IF NOT EXISTS phone_changes (
+  id serial PRIMARY KEY,
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  phone VARCHAR(16) NOT NULL,
+  code_hash VARCHAR(64) NOT NULL,
+  attempts SMALLINT NOT NULL DEFAULT 0,
+  expires_at TIMESTAMP NOT NULL,
+  verified_at TIMESTAMP,
+  cancelled_at TIMESTAMP,
+  created_at TIMESTAMP DEFAULT NOW()
+);
+
+/**
+Create unique partial index for column user_id, a user can only have one pending phone change
+Create index for columns user_id and id, the latest change of a user is read to space out codes
+*/
+CREATE UNIQUE INDEX index_phone_change_user_pending ON phone_changes(user_id) WHERE verified_at IS NULL AND cancelled_at IS NULL;
+CREATE INDEX index_phone_change_user_id ON phone_changes(user_id, id);
+
+/**
+  id bigserial, primary key event identifier
+  actor_id integer, authenticated caller who made the write, null for registration and background jobs
+  target_id integer, user who was written
+  action varchar(32), what was done e.g user.create, user.update or user.status_change
+  before jsonb, after jsonb, audited fields of the user around the write, password is always redacted
+  request_id varchar(64), X-Request-ID of the request, empty for background jobs
+  ip varchar(45), client ip of the request
+  created_at timestamp, to track when the write happened
+  Rows are inserted in the transaction of the write they record and never updated or deleted,
+  except purging an account removes its personal fields (name, phone, email, locale, timezone, avatar, attributes) from before and after
+*/
+CREATE TABLE IF NOT EXISTS audit_events (
+  id bigserial PRIMARY KEY,
+  actor_id INTEGER REFERENCES users(id),
+  target_id INTEGER NOT NULL REFERENCES users(id),
+  action VARCHAR(32) NOT NULL,
+  before JSONB,
+  after JSONB,
+  request_id VARCHAR(64) NOT NULL DEFAULT '',
+  ip VARCHAR(45) NOT NULL DEFAULT '',
+  created_at TIMESTAMP DEFAULT NOW()
+);
+
+/**
+Create index for column target_id, events are reviewed per user newest first
+Create index for column actor_id, data exports list the writes a user made
+Create index for column created_at, events of every user are reviewed per time range
+*/
+CREATE INDEX index_audit_event_target_id ON audit_events(target_id, id);
+CREATE INDEX index_audit_event_actor_id ON audit_events(actor_id, id);
+CREATE INDEX index_audit_event_created_at ON audit_events(created_at);
 
 -- Seed users entry
-INSERT INTO users(name,phone,password) VALUES ('user','6280000000000','$2a$06$bt380.sYY0HEAa1tz2eyfOOQDHarjgiABmv.ZJTXzKdXMU.hQFAyi');
\ No newline at end of file
+INSERT INTO users(name,phone,password) VALUES ('user','+6280000000000','$2a$06$bt380.sYY0HEAa1tz2eyfOOQDHarjgiABmv.ZJTXzKdXMU.hQFAyi');
+INSERT INTO users(name,phone,password,role) VALUES ('admin','+6280000000001','$2a$06$bt380.sYY0HEAa1tz2eyfOOQDHarjgiABmv.ZJTXzKdXMU.hQFAyi','admin');
\ No newline at end of file
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/AthanatiusC/SawitPro/generated"
+	"github.com/AthanatiusC/SawitPro/i18n"
+	"github.com/AthanatiusC/SawitPro/repository"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Grace period used when none is configured
+const defaultDeletionGracePeriod = 30 * 24 * time.Hour
+
+// (DELETE /user) Delete user endpoint, schedules the account for deletion and signs it out everywhere
+func (s *Server) DeleteUser(ctx echo.Context, params generated.DeleteUserParams) error {
+	principal, err := s.AuthorizeOwner(ctx.Request().Context(), params.Authorization, ScopeProfileWrite)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.Repository.DeleteUserById(ctx.Request().Context(), principal.User.Id)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, generated.AccountDeletionResponse{
+		PurgeAfter: user.DeletedAt.Add(s.DeletionGracePeriod),
+	})
+}
+
+// (POST /user/restore) Restore user endpoint, cancels a pending deletion using the account credentials
+func (s *Server) RestoreUser(ctx echo.Context) error {
+	var request generated.RestoreUserJSONRequestBody
+	if err := ctx.Bind(&request); err != nil {
+		return err
+	}
+
+	errors := s.Users.Validate(request)
+	if len(errors) != 0 {
+		return validationProblem(http.StatusBadRequest, errors...)
+	}
+
+	phoneNumber := s.NormalizePhoneNumber(request.PhoneNumber)
+	user, err := s.Repository.GetDeletedUserByPhoneNumber(ctx.Request().Context(), phoneNumber, time.Now().Add(-s.DeletionGracePeriod))
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	// Same answer for unknown, active or expired accounts and wrong passwords
+	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(request.Password))
+	if err != nil {
+		return newProblem(http.StatusBadRequest, i18n.IncorrectPhoneCredentials)
+	}
+
+	// Restore is authenticated by the credentials instead of a token, the user restores their own account
+	if metadata := repository.AuditMetadataFromContext(ctx.Request().Context()); metadata != nil {
+		metadata.ActorId = user.Id
+	}
+
+	_, err = s.Repository.RestoreUserById(ctx.Request().Context(), user.Id)
+	if err == sql.ErrNoRows {
+		return newProblem(http.StatusBadRequest, i18n.IncorrectPhoneCredentials)
+	} else if err != nil {
+		return err
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
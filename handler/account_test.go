@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AthanatiusC/SawitPro/generated"
+	"github.com/AthanatiusC/SawitPro/repository"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+TestDeleteUser Criteria:
+- Valid JWT
+- Account is soft deleted and purge time follows the grace period
+*/
+func TestDeleteUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	e := echo.New()
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	h := NewServer(NewServerOptions{Repository: repo, DeletionGracePeriod: time.Hour})
+
+	deletedAt := time.Now()
+	user := repository.User{Id: 1, Status: repository.UserStatusActive}
+	repo.EXPECT().GetUserById(gomock.Any(), user.Id).Return(user, nil)
+	repo.EXPECT().DeleteUserById(gomock.Any(), user.Id).Return(repository.User{Id: user.Id, DeletedAt: &deletedAt}, nil)
+
+	token, err := h.GenerateJWT(JWTClaims{UserId: user.Id})
+	if err != nil {
+		t.Error(err)
+	}
+	token = fmt.Sprintf("Bearer %s", token)
+
+	req := httptest.NewRequest(http.MethodDelete, "/user", nil)
+	rec := httptest.NewRecorder()
+	if assert.NoError(t, serve(h, e.NewContext(req, rec), func(ctx echo.Context) error {
+		return h.DeleteUser(ctx, generated.DeleteUserParams{Authorization: &token})
+	})) {
+		assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var response generated.AccountDeletionResponse
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		assert.True(t, deletedAt.Add(time.Hour).Equal(response.PurgeAfter))
+	}
+}
+
+/*
+TestRestoreUser Criteria:
+- Account pending deletion is restored with valid credentials
+- Wrong password is refused without restoring
+*/
+func TestRestoreUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	e := echo.New()
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	h := NewServer(NewServerOptions{Repository: repo})
+
+	user := repository.User{Id: 1, Status: repository.UserStatusActive, Phone: "+6280000000000", Password: "$2a$06$bt380.sYY0HEAa1tz2eyfOOQDHarjgiABmv.ZJTXzKdXMU.hQFAyi"}
+	repo.EXPECT().GetDeletedUserByPhoneNumber(gomock.Any(), user.Phone, gomock.Any()).Return(user, nil).Times(2)
+	repo.EXPECT().RestoreUserById(gomock.Any(), user.Id).Return(user, nil)
+
+	restore := func(password string) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"phone_number":"+6280000000000","password":"%s"}`, password)
+		req := httptest.NewRequest(http.MethodPost, "/user/restore", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		assert.NoError(t, serve(h, e.NewContext(req, rec), h.RestoreUser))
+		return rec
+	}
+
+	rec := restore("Wrongpassw0rd!")
+	assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+
+	rec = restore("Userpassw0rd!")
+	assert.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+}
@@ -0,0 +1,315 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/AthanatiusC/SawitPro/generated"
+	"github.com/AthanatiusC/SawitPro/i18n"
+	"github.com/AthanatiusC/SawitPro/repository"
+	"github.com/AthanatiusC/SawitPro/validation"
+	"github.com/labstack/echo/v4"
+)
+
+// Sort orders accepted by the user listing, a leading - sorts descending
+var userSorts = map[string]string{
+	"created_at": repository.UserSortCreatedAt,
+	"updated_at": repository.UserSortUpdatedAt,
+	"name":       repository.UserSortName,
+}
+
+var userStatuses = []string{
+	repository.UserStatusActive,
+	repository.UserStatusSuspended,
+	repository.UserStatusDisabled,
+	repository.UserStatusDeleted,
+	repository.UserStatusPurged,
+}
+
+// Lifecycle transitions admins can make, disabled is final
+var userStatusTransitions = map[string][]string{
+	repository.UserStatusActive:    {repository.UserStatusSuspended, repository.UserStatusDisabled},
+	repository.UserStatusSuspended: {repository.UserStatusActive, repository.UserStatusDisabled},
+}
+
+var userRoles = []string{repository.RoleUser, repository.RoleAdmin}
+
+// Lowest score a name search result can have when the caller sets none, low enough to catch a typo in a short name
+const defaultSearchMinScore = 0.3
+
+// (GET /admin/users) List users endpoint, admin only, filters and sorts every user including deleted ones
+func (s *Server) ListUsers(ctx echo.Context, params generated.ListUsersParams) error {
+	_, err := s.AuthorizeAdmin(ctx.Request().Context(), params.Authorization)
+	if err != nil {
+		return err
+	}
+
+	input, errors, err := s.listUsersInput(ctx.Request().Context(), params)
+	if err != nil {
+		return err
+	} else if len(errors) != 0 {
+		return validationProblem(http.StatusBadRequest, errors...)
+	}
+
+	// Fetch one extra row to know whether another page exists
+	limit := input.Limit
+	input.Limit++
+	users, err := s.Repository.ListUsers(ctx.Request().Context(), input)
+	if err != nil {
+		return err
+	}
+
+	response := generated.UserListResponse{Users: []generated.AdminUser{}}
+	if len(users) > limit {
+		users = users[:limit]
+		nextCursor := encodeUserCursor(input.SortBy, input.Descending, users[limit-1])
+		response.NextCursor = &nextCursor
+	}
+
+	for _, user := range users {
+		response.Users = append(response.Users, adminUserResponse(user))
+	}
+
+	return ctx.JSON(http.StatusOK, response)
+}
+
+// (GET /admin/users/search) Search users endpoint, admin only, typo tolerant and accent insensitive name search ranked by score
+func (s *Server) SearchUsers(ctx echo.Context, params generated.SearchUsersParams) error {
+	_, err := s.AuthorizeAdmin(ctx.Request().Context(), params.Authorization)
+	if err != nil {
+		return err
+	}
+
+	var errors validation.Errors
+	query := strings.TrimSpace(params.Q)
+	if len([]rune(query)) < 2 || len([]rune(query)) > 60 {
+		errors.Add("q", i18n.LengthRange, 2, 60)
+	}
+
+	limit, limitErr := pageLimit(params.Limit)
+	if limitErr != nil {
+		errors = append(errors, limitErr)
+	}
+
+	minScore := defaultSearchMinScore
+	if params.MinScore != nil {
+		if *params.MinScore < 0 || *params.MinScore > 1 {
+			errors.Add("min_score", i18n.Between, 0, 1)
+		}
+		minScore = *params.MinScore
+	}
+
+	if len(errors) != 0 {
+		return validationProblem(http.StatusBadRequest, errors...)
+	}
+
+	matches, err := s.Repository.SearchUsersByName(ctx.Request().Context(), repository.SearchUsersInput{
+		Query:    query,
+		MinScore: minScore,
+		Limit:    limit,
+	})
+	if err != nil {
+		return err
+	}
+
+	response := generated.UserSearchResponse{Results: []generated.UserSearchResult{}}
+	for _, match := range matches {
+		response.Results = append(response.Results, generated.UserSearchResult{
+			User:  adminUserResponse(match.User),
+			Score: match.Score,
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, response)
+}
+
+// (PUT /admin/users/{id}/status) Update user status endpoint, admin only, suspends, reactivates or disables a user.
+// The change is recorded with the admin and the reason, and signs the user out everywhere
+func (s *Server) UpdateUserStatus(ctx echo.Context, id int, params generated.UpdateUserStatusParams) error {
+	principal, err := s.AuthorizeAdmin(ctx.Request().Context(), params.Authorization)
+	if err != nil {
+		return err
+	}
+
+	var request generated.UpdateUserStatusJSONRequestBody
+	if err := ctx.Bind(&request); err != nil {
+		return err
+	}
+
+	var errors validation.Errors
+	if request.Status != repository.UserStatusActive && request.Status != repository.UserStatusSuspended && request.Status != repository.UserStatusDisabled {
+		errors.Add("status", i18n.OneOf, "active, suspended, disabled")
+	}
+	if request.Reason == "" {
+		errors.Add("reason", i18n.Required, "reason")
+	} else if len(request.Reason) > 255 {
+		errors.Add("reason", i18n.MaxLength, 255)
+	}
+	if len(errors) != 0 {
+		return validationProblem(http.StatusBadRequest, errors...)
+	}
+
+	// An admin locking themselves out is never intended
+	if id == principal.User.Id {
+		return newProblem(http.StatusBadRequest, i18n.CannotChangeOwnStatus)
+	}
+
+	user, err := s.Repository.GetUserById(ctx.Request().Context(), id)
+	if err == sql.ErrNoRows {
+		return newProblem(http.StatusNotFound, i18n.UserNotFound)
+	} else if err != nil {
+		return err
+	}
+
+	if !containsString(userStatusTransitions[user.Status], request.Status) {
+		return newProblem(http.StatusConflict, i18n.StatusTransitionInvalid, user.Status, request.Status)
+	}
+
+	user, err = s.Repository.UpdateUserStatus(ctx.Request().Context(), repository.UpdateUserStatusInput{
+		Id:      user.Id,
+		ActorId: principal.User.Id,
+		From:    user.Status,
+		To:      request.Status,
+		Reason:  request.Reason,
+	})
+	if err == sql.ErrNoRows { // deleted or changed by someone else meanwhile
+		return newProblem(http.StatusConflict, i18n.UserStatusChanged)
+	} else if err != nil {
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, adminUserResponse(user))
+}
+
+// Validate the query of the user listing and translate it into repository filters
+func (s *Server) listUsersInput(ctx context.Context, params generated.ListUsersParams) (input repository.ListUsersInput, errors validation.Errors, err error) {
+	limit, limitErr := pageLimit(params.Limit)
+	if limitErr != nil {
+		errors = append(errors, limitErr)
+	}
+	input.Limit = limit
+
+	if params.Name != nil {
+		input.NameContains = strings.TrimSpace(*params.Name)
+	}
+	if params.Phone != nil {
+		input.PhonePrefix = s.PhoneNumbers.Prefix(*params.Phone)
+	}
+
+	input.CreatedAfter = params.CreatedAfter
+	input.CreatedBefore = params.CreatedBefore
+	input.UpdatedAfter = params.UpdatedAfter
+	input.UpdatedBefore = params.UpdatedBefore
+
+	if params.Status != nil {
+		if !containsString(userStatuses, *params.Status) {
+			errors.Add("status", i18n.OneOf, strings.Join(userStatuses, ", "))
+		}
+		input.Status = *params.Status
+	}
+	if params.Role != nil {
+		if !containsString(userRoles, *params.Role) {
+			errors.Add("role", i18n.OneOf, strings.Join(userRoles, ", "))
+		}
+		input.Role = *params.Role
+	}
+	if params.Attribute != nil {
+		var attributeErrors validation.Errors
+		if input.Attributes, attributeErrors, err = s.attributeFilters(ctx, *params.Attribute); err != nil {
+			return
+		}
+		errors = append(errors, attributeErrors...)
+	}
+
+	input.SortBy = repository.UserSortCreatedAt
+	if params.Sort != nil {
+		sort := strings.TrimPrefix(*params.Sort, "-")
+		sortBy, ok := userSorts[sort]
+		if !ok {
+			errors.Add("sort", i18n.InvalidSort)
+		}
+		input.SortBy = sortBy
+		input.Descending = strings.HasPrefix(*params.Sort, "-")
+	}
+
+	if params.Cursor != nil && len(errors) == 0 {
+		after, err := decodeUserCursor(*params.Cursor, input.SortBy, input.Descending)
+		if err != nil {
+			errors.Add("cursor", i18n.InvalidCursor)
+		}
+		input.After = &after
+	}
+
+	return
+}
+
+func adminUserResponse(user repository.User) generated.AdminUser {
+	return generated.AdminUser{
+		Id:          user.Id,
+		FullName:    user.Name,
+		PhoneNumber: user.Phone,
+		Role:        user.Role,
+		Status:      user.EffectiveStatus(),
+		Attributes:  attributesResponse(user.Attributes),
+		DeletedAt:   user.DeletedAt,
+		UpdatedAt:   user.UpdatedAt,
+		CreatedAt:   user.CreatedAt,
+	}
+}
+
+// Position of the last user of a page, carries the sort column value so pages stay stable under inserts
+type userCursor struct {
+	Sort       string `json:"s"`
+	Descending bool   `json:"d,omitempty"`
+	Value      string `json:"v"`
+	Id         int    `json:"id"`
+}
+
+func encodeUserCursor(sortBy string, descending bool, user repository.User) string {
+	cursor := userCursor{Sort: sortBy, Descending: descending, Id: user.Id}
+	switch sortBy {
+	case repository.UserSortUpdatedAt:
+		cursor.Value = user.UpdatedAt.Format(time.RFC3339Nano)
+	case repository.UserSortName:
+		cursor.Value = user.Name
+	default:
+		cursor.Value = user.CreatedAt.Format(time.RFC3339Nano)
+	}
+
+	encoded, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+// Decode a cursor into the last user of the previous page, the cursor must come from a listing with the same sort and
+// direction, resuming in the other direction would skip or repeat users
+func decodeUserCursor(value string, sortBy string, descending bool) (user repository.User, err error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return
+	}
+
+	var cursor userCursor
+	if err = json.Unmarshal(decoded, &cursor); err != nil {
+		return
+	}
+	if cursor.Sort != sortBy || cursor.Descending != descending || cursor.Id < 1 {
+		return user, errors.New("invalid cursor")
+	}
+
+	user.Id = cursor.Id
+	switch sortBy {
+	case repository.UserSortUpdatedAt:
+		user.UpdatedAt, err = time.Parse(time.RFC3339Nano, cursor.Value)
+	case repository.UserSortName:
+		user.Name = cursor.Value
+	default:
+		user.CreatedAt, err = time.Parse(time.RFC3339Nano, cursor.Value)
+	}
+	return
+}
@@ -0,0 +1,240 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AthanatiusC/SawitPro/generated"
+	"github.com/AthanatiusC/SawitPro/repository"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+TestListUsers Criteria:
+- Admin only
+- Filters and sort are passed to the repository
+- Next cursor resumes after the last user of the page, and is refused with another sort direction
+- Unknown sort is refused
+*/
+func TestListUsers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	e := echo.New()
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	h := NewServer(NewServerOptions{Repository: repo})
+
+	admin := repository.User{Id: 1, Status: repository.UserStatusActive, Role: repository.RoleAdmin}
+	user := repository.User{Id: 2, Status: repository.UserStatusActive, Role: repository.RoleUser}
+	repo.EXPECT().GetUserById(gomock.Any(), admin.Id).Return(admin, nil).AnyTimes()
+	repo.EXPECT().GetUserById(gomock.Any(), user.Id).Return(user, nil)
+
+	createdAt := time.Date(2024, 1, 1, 0, 0, 0, 123000, time.UTC)
+	page := []repository.User{
+		{Id: 5, Name: "Budi", Status: repository.UserStatusActive, CreatedAt: createdAt.Add(time.Hour)},
+		{Id: 4, Name: "Budiman", Status: repository.UserStatusActive, CreatedAt: createdAt},
+		{Id: 3, Name: "Budi Santoso", Status: repository.UserStatusActive, CreatedAt: createdAt},
+	}
+	gomock.InOrder(
+		repo.EXPECT().ListUsers(gomock.Any(), repository.ListUsersInput{
+			NameContains: "budi",
+			Status:       repository.UserStatusActive,
+			SortBy:       repository.UserSortCreatedAt,
+			Descending:   true,
+			Limit:        3,
+		}).Return(page, nil),
+		repo.EXPECT().ListUsers(gomock.Any(), repository.ListUsersInput{
+			NameContains: "budi",
+			Status:       repository.UserStatusActive,
+			SortBy:       repository.UserSortCreatedAt,
+			Descending:   true,
+			After:        &repository.User{Id: 4, CreatedAt: createdAt},
+			Limit:        3,
+		}).Return(page[2:], nil),
+	)
+
+	list := func(id int, params generated.ListUsersParams) *httptest.ResponseRecorder {
+		token, err := h.GenerateJWT(JWTClaims{UserId: id})
+		if err != nil {
+			t.Error(err)
+		}
+		token = fmt.Sprintf("Bearer %s", token)
+		params.Authorization = &token
+
+		req := httptest.NewRequest(http.MethodGet, "/admin/users", nil)
+		rec := httptest.NewRecorder()
+		assert.NoError(t, serve(h, e.NewContext(req, rec), func(ctx echo.Context) error { return h.ListUsers(ctx, params) }))
+		return rec
+	}
+
+	name, status, sort, limit := "budi", repository.UserStatusActive, "-created_at", 2
+	params := generated.ListUsersParams{Name: &name, Status: &status, Sort: &sort, Limit: &limit}
+
+	rec := list(user.Id, params)
+	assert.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+
+	rec = list(admin.Id, params)
+	var response generated.UserListResponse
+	if assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String()) {
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		assert.Len(t, response.Users, 2)
+		assert.Equal(t, repository.UserStatusActive, response.Users[0].Status)
+		assert.NotNil(t, response.NextCursor)
+	}
+
+	params.Cursor = response.NextCursor
+	rec = list(admin.Id, params)
+	if assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String()) {
+		response = generated.UserListResponse{}
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		assert.Len(t, response.Users, 1)
+		assert.Nil(t, response.NextCursor)
+	}
+
+	sort = "created_at"
+	rec = list(admin.Id, params)
+	if assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String()) {
+		assert.Contains(t, rec.Body.String(), "cursor")
+	}
+
+	sort = "phone"
+	rec = list(admin.Id, generated.ListUsersParams{Sort: &sort})
+	assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+}
+
+/*
+TestSearchUsers Criteria:
+- Matches are returned best first with their score
+- Query shorter than 2 characters is refused
+*/
+func TestSearchUsers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	e := echo.New()
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	h := NewServer(NewServerOptions{Repository: repo})
+
+	admin := repository.User{Id: 1, Status: repository.UserStatusActive, Role: repository.RoleAdmin}
+	repo.EXPECT().GetUserById(gomock.Any(), admin.Id).Return(admin, nil).Times(2)
+	repo.EXPECT().SearchUsersByName(gomock.Any(), repository.SearchUsersInput{Query: "Budy", MinScore: defaultSearchMinScore, Limit: defaultPageLimit}).
+		Return([]repository.UserMatch{
+			{User: repository.User{Id: 2, Name: "Budi"}, Score: 0.43},
+			{User: repository.User{Id: 3, Name: "Budi Santoso"}, Score: 0.43},
+		}, nil)
+
+	token, err := h.GenerateJWT(JWTClaims{UserId: admin.Id})
+	if err != nil {
+		t.Error(err)
+	}
+	token = fmt.Sprintf("Bearer %s", token)
+
+	search := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/admin/users/search", nil)
+		rec := httptest.NewRecorder()
+		assert.NoError(t, serve(h, e.NewContext(req, rec), func(ctx echo.Context) error {
+			return h.SearchUsers(ctx, generated.SearchUsersParams{Q: query, Authorization: &token})
+		}))
+		return rec
+	}
+
+	rec := search(" Budy ")
+	if assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String()) {
+		var response generated.UserSearchResponse
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		if assert.Len(t, response.Results, 2) {
+			assert.Equal(t, "Budi", response.Results[0].User.FullName)
+			assert.Equal(t, 0.43, response.Results[0].Score)
+		}
+	}
+
+	rec = search("b")
+	assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+}
+
+/*
+TestUpdateUserStatus Criteria:
+- Admin suspends an active user with a reason
+- Disabled user cannot be reactivated
+- Suspended user cannot sign in nor use an issued token
+*/
+func TestUpdateUserStatus(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	e := echo.New()
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	h := NewServer(NewServerOptions{Repository: repo})
+
+	admin := repository.User{Id: 1, Status: repository.UserStatusActive, Role: repository.RoleAdmin}
+	user := repository.User{Id: 2, Status: repository.UserStatusActive, Role: repository.RoleUser}
+	suspended := repository.User{Id: 2, Status: repository.UserStatusSuspended, Role: repository.RoleUser, TokenVersion: 1,
+		Password: "$2a$06$bt380.sYY0HEAa1tz2eyfOOQDHarjgiABmv.ZJTXzKdXMU.hQFAyi"}
+	disabled := repository.User{Id: 3, Status: repository.UserStatusDisabled, Role: repository.RoleUser}
+	repo.EXPECT().GetUserById(gomock.Any(), admin.Id).Return(admin, nil).Times(2)
+	repo.EXPECT().GetUserById(gomock.Any(), user.Id).Return(user, nil)
+	repo.EXPECT().GetUserById(gomock.Any(), disabled.Id).Return(disabled, nil)
+	repo.EXPECT().UpdateUserStatus(gomock.Any(), repository.UpdateUserStatusInput{
+		Id:      user.Id,
+		ActorId: admin.Id,
+		From:    repository.UserStatusActive,
+		To:      repository.UserStatusSuspended,
+		Reason:  "left the plantation",
+	}).Return(suspended, nil)
+
+	token, err := h.GenerateJWT(JWTClaims{UserId: admin.Id})
+	if err != nil {
+		t.Error(err)
+	}
+	token = fmt.Sprintf("Bearer %s", token)
+
+	update := func(id int, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/admin/users/%d/status", id), strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		assert.NoError(t, serve(h, e.NewContext(req, rec), func(ctx echo.Context) error {
+			return h.UpdateUserStatus(ctx, id, generated.UpdateUserStatusParams{Authorization: &token})
+		}))
+		return rec
+	}
+
+	rec := update(user.Id, `{"status":"suspended","reason":"left the plantation"}`)
+	if assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String()) {
+		assert.Contains(t, rec.Body.String(), `"status":"suspended"`)
+	}
+
+	rec = update(disabled.Id, `{"status":"active","reason":"came back"}`)
+	assert.Equal(t, http.StatusConflict, rec.Code, rec.Body.String())
+
+	// The suspended user signs in with the right password
+	repo.EXPECT().GetUserByPhoneNumber(gomock.Any(), "+6280000000000").Return(suspended, nil)
+	repo.EXPECT().CreateLoginAttempt(gomock.Any(), gomock.Any()).
+		Do(func(_ context.Context, attempt repository.CreateLoginAttemptInput) {
+			assert.Equal(t, repository.LoginReasonInactiveUser, attempt.Reason)
+		}).Return(nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"phone_number":"+6280000000000","password":"Userpassw0rd!"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec = httptest.NewRecorder()
+	if assert.NoError(t, serve(h, e.NewContext(req, rec), h.Login)) {
+		assert.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+	}
+
+	// A token carrying the current version is still refused
+	repo.EXPECT().GetUserById(gomock.Any(), suspended.Id).Return(suspended, nil)
+	userToken, err := h.GenerateJWT(JWTClaims{UserId: suspended.Id, Version: suspended.TokenVersion})
+	if err != nil {
+		t.Error(err)
+	}
+	userToken = fmt.Sprintf("Bearer %s", userToken)
+	_, err = h.Authenticate(context.Background(), &userToken)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+}
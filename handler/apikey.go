@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/AthanatiusC/SawitPro/generated"
+	"github.com/AthanatiusC/SawitPro/i18n"
+	"github.com/AthanatiusC/SawitPro/repository"
+	"github.com/AthanatiusC/SawitPro/validation"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	apiKeyPrefix       = "sp_" // Prefix of personal api keys, tells them apart from JWTs in the Authorization header
+	apiKeyPrefixLength = 11    // Characters of the key stored in clear for display, apiKeyPrefix included
+)
+
+/*
+Scopes an api key can be granted, keys can never grant more than a login token.
+Keys are managed with the user's own login token only (AuthorizeOwner), a leaked key must not be able
+to mint more keys and an admin impersonating the user must not be able to create long-lived credentials.
+*/
+var apiKeyScopes = defaultScopes
+
+// (GET /user/api-keys) List api keys endpoint, returns every active api key of the user
+func (s *Server) ListApiKeys(ctx echo.Context, params generated.ListApiKeysParams) error {
+	principal, err := s.AuthorizeOwner(ctx.Request().Context(), params.Authorization, ScopeProfileWrite)
+	if err != nil {
+		return err
+	}
+
+	apiKeys, err := s.Repository.ListApiKeysByUserId(ctx.Request().Context(), principal.User.Id)
+	if err != nil {
+		return err
+	}
+
+	response := generated.ApiKeyListResponse{ApiKeys: []generated.ApiKey{}}
+	for _, apiKey := range apiKeys {
+		response.ApiKeys = append(response.ApiKeys, apiKeyResponse(apiKey))
+	}
+
+	return ctx.JSON(http.StatusOK, response)
+}
+
+// (POST /user/api-keys) Create api key endpoint, returns the key in clear only once
+func (s *Server) CreateApiKey(ctx echo.Context, params generated.CreateApiKeyParams) error {
+	principal, err := s.AuthorizeOwner(ctx.Request().Context(), params.Authorization, ScopeProfileWrite)
+	if err != nil {
+		return err
+	}
+
+	var request generated.CreateApiKeyJSONRequestBody
+	if err := ctx.Bind(&request); err != nil {
+		return err
+	}
+
+	scopes := apiKeyScopes
+	if request.Scopes != nil {
+		scopes = *request.Scopes
+	}
+
+	errors := validateApiKey(request.Name, scopes, request.ExpiresAt)
+	if len(errors) != 0 {
+		return validationProblem(http.StatusBadRequest, errors...)
+	}
+
+	key, err := generateApiKey()
+	if err != nil {
+		return err
+	}
+
+	apiKey, err := s.Repository.CreateApiKey(ctx.Request().Context(), repository.CreateApiKeyInput{
+		UserId:    principal.User.Id,
+		Name:      request.Name,
+		Prefix:    key[:apiKeyPrefixLength],
+		KeyHash:   hashApiKey(key),
+		Scopes:    scopes,
+		ExpiresAt: request.ExpiresAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(http.StatusCreated, generated.CreateApiKeyResponse{
+		ApiKey: apiKeyResponse(apiKey),
+		Key:    key,
+	})
+}
+
+// (PATCH /user/api-keys/{id}) Update api key endpoint, renames an active api key of the user
+func (s *Server) UpdateApiKey(ctx echo.Context, id int, params generated.UpdateApiKeyParams) error {
+	principal, err := s.AuthorizeOwner(ctx.Request().Context(), params.Authorization, ScopeProfileWrite)
+	if err != nil {
+		return err
+	}
+
+	var request generated.UpdateApiKeyJSONRequestBody
+	if err := ctx.Bind(&request); err != nil {
+		return err
+	}
+
+	errors := validateApiKey(request.Name, nil, nil)
+	if len(errors) != 0 {
+		return validationProblem(http.StatusBadRequest, errors...)
+	}
+
+	apiKey, err := s.Repository.UpdateApiKeyName(ctx.Request().Context(), repository.UpdateApiKeyInput{
+		Id:     id,
+		UserId: principal.User.Id,
+		Name:   request.Name,
+	})
+	if err == sql.ErrNoRows {
+		return newProblem(http.StatusNotFound, i18n.ApiKeyNotFound)
+	} else if err != nil {
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, apiKeyResponse(apiKey))
+}
+
+// (DELETE /user/api-keys/{id}) Revoke api key endpoint, the key is refused from then on
+func (s *Server) RevokeApiKey(ctx echo.Context, id int, params generated.RevokeApiKeyParams) error {
+	principal, err := s.AuthorizeOwner(ctx.Request().Context(), params.Authorization, ScopeProfileWrite)
+	if err != nil {
+		return err
+	}
+
+	err = s.Repository.RevokeApiKey(ctx.Request().Context(), principal.User.Id, id)
+	if err == sql.ErrNoRows {
+		return newProblem(http.StatusNotFound, i18n.ApiKeyNotFound)
+	} else if err != nil {
+		return err
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// Validate api key fields, scopes and expiry are skipped when nil
+func validateApiKey(name string, scopes []string, expiresAt *time.Time) (errors validation.Errors) {
+	if name == "" {
+		errors.Add("name", i18n.Required, "name")
+	} else if len(name) > 60 {
+		errors.Add("name", i18n.MaxLength, 60)
+	}
+
+	for _, scope := range scopes {
+		if !containsString(apiKeyScopes, scope) {
+			errors.Add("scopes", i18n.UnknownScope, scope)
+		}
+	}
+
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		errors.Add("expires_at", i18n.MustBeFuture)
+	}
+
+	return
+}
+
+// Generate a random api key, 32 bytes of entropy encoded url safe
+func generateApiKey() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// Hash an api key for storage and lookup
+func hashApiKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func apiKeyResponse(apiKey repository.ApiKey) generated.ApiKey {
+	return generated.ApiKey{
+		Id:         apiKey.Id,
+		Name:       apiKey.Name,
+		Prefix:     apiKey.Prefix,
+		Scopes:     apiKey.Scopes,
+		ExpiresAt:  apiKey.ExpiresAt,
+		LastUsedAt: apiKey.LastUsedAt,
+		CreatedAt:  apiKey.CreatedAt,
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
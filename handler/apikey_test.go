@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AthanatiusC/SawitPro/generated"
+	"github.com/AthanatiusC/SawitPro/repository"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+TestCreateApiKey Criteria:
+- Valid JWT
+- Key is returned once and only its hash is stored
+- Unknown scopes are refused
+*/
+func TestCreateApiKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	e := echo.New()
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	h := NewServer(NewServerOptions{Repository: repo})
+
+	user := repository.User{Id: 1, Status: repository.UserStatusActive}
+	repo.EXPECT().GetUserById(gomock.Any(), user.Id).Return(user, nil).Times(2)
+
+	var stored repository.CreateApiKeyInput
+	repo.EXPECT().CreateApiKey(gomock.Any(), gomock.Any()).DoAndReturn(func(_ interface{}, input repository.CreateApiKeyInput) (repository.ApiKey, error) {
+		stored = input
+		return repository.ApiKey{Id: 1, UserId: input.UserId, Name: input.Name, Prefix: input.Prefix, Scopes: input.Scopes}, nil
+	})
+
+	token, err := h.GenerateJWT(JWTClaims{UserId: user.Id})
+	if err != nil {
+		t.Error(err)
+	}
+	token = fmt.Sprintf("Bearer %s", token)
+
+	createApiKey := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/user/api-keys", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+		rec := httptest.NewRecorder()
+		assert.NoError(t, serve(h, e.NewContext(req, rec), func(ctx echo.Context) error {
+			return h.CreateApiKey(ctx, generated.CreateApiKeyParams{Authorization: &token})
+		}))
+		return rec
+	}
+
+	rec := createApiKey(`{"name":"spreadsheet","scopes":["profile:read"]}`)
+	if assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String()) {
+		var response generated.CreateApiKeyResponse
+		json.Unmarshal(rec.Body.Bytes(), &response)
+
+		assert.True(t, strings.HasPrefix(response.Key, apiKeyPrefix))
+		assert.Equal(t, hashApiKey(response.Key), stored.KeyHash)
+		assert.Equal(t, []string{ScopeProfileRead}, stored.Scopes)
+	}
+
+	rec = createApiKey(`{"name":"spreadsheet","scopes":["admin"]}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+}
+
+/*
+TestApiKeyAuthentication Criteria:
+- Api key is accepted as a bearer token within its scopes
+- Last used timestamp is tracked
+- Expired api key is refused
+*/
+func TestApiKeyAuthentication(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	e := echo.New()
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	h := NewServer(NewServerOptions{Repository: repo})
+
+	key, err := generateApiKey()
+	if err != nil {
+		t.Error(err)
+	}
+	expired, err := generateApiKey()
+	if err != nil {
+		t.Error(err)
+	}
+	past := time.Now().Add(-time.Hour)
+
+	user := repository.User{Id: 1, Status: repository.UserStatusActive, Name: "user"}
+	repo.EXPECT().GetApiKeyByHash(gomock.Any(), hashApiKey(key)).Return(repository.ApiKey{Id: 7, UserId: user.Id, Scopes: []string{ScopeProfileRead}}, nil).Times(2)
+	repo.EXPECT().GetApiKeyByHash(gomock.Any(), hashApiKey(expired)).Return(repository.ApiKey{Id: 8, UserId: user.Id, Scopes: []string{ScopeProfileRead}, ExpiresAt: &past}, nil)
+	repo.EXPECT().GetUserById(gomock.Any(), user.Id).Return(user, nil).Times(2)
+	repo.EXPECT().TouchApiKey(gomock.Any(), 7).Return(nil).Times(2)
+	repo.EXPECT().GetPendingPhoneChange(gomock.Any(), user.Id).Return(repository.PhoneChange{}, sql.ErrNoRows).AnyTimes()
+
+	getUser := func(auth string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/user", nil)
+		rec := httptest.NewRecorder()
+		assert.NoError(t, serve(h, e.NewContext(req, rec), func(ctx echo.Context) error { return h.GetUser(ctx, generated.GetUserParams{Authorization: &auth}) }))
+		return rec
+	}
+
+	rec := getUser("Bearer " + key)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = getUser("Bearer " + expired)
+	assert.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+
+	// Read only key cannot update the profile
+	auth := "Bearer " + key
+	req := httptest.NewRequest(http.MethodPut, "/user", strings.NewReader(`{"full_name":"user"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec = httptest.NewRecorder()
+	if assert.NoError(t, serve(h, e.NewContext(req, rec), func(ctx echo.Context) error {
+		return h.UpdateUser(ctx, generated.UpdateUserParams{Authorization: &auth})
+	})) {
+		assert.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,338 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/AthanatiusC/SawitPro/generated"
+	"github.com/AthanatiusC/SawitPro/i18n"
+	"github.com/AthanatiusC/SawitPro/repository"
+	"github.com/AthanatiusC/SawitPro/validation"
+	"github.com/labstack/echo/v4"
+)
+
+// Names of custom attributes, they are json keys and part of the name of the index of unique attributes
+var attributeName = regexp.MustCompile(`^[a-z][a-z0-9_]{0,31}$`)
+
+var attributeTypes = []string{repository.AttributeTypeString, repository.AttributeTypeNumber, repository.AttributeTypeBoolean}
+
+// Longest string value of an attribute and description of a definition
+const attributeMaxLength = 255
+
+// (GET /admin/attributes) List attribute definitions endpoint, admin only, the custom attributes users can have
+func (s *Server) ListAttributeDefinitions(ctx echo.Context, params generated.ListAttributeDefinitionsParams) error {
+	_, err := s.AuthorizeAdmin(ctx.Request().Context(), params.Authorization)
+	if err != nil {
+		return err
+	}
+
+	definitions, err := s.Repository.ListAttributeDefinitions(ctx.Request().Context())
+	if err != nil {
+		return err
+	}
+
+	response := generated.AttributeDefinitionListResponse{Attributes: []generated.AttributeDefinition{}}
+	for _, definition := range definitions {
+		response.Attributes = append(response.Attributes, attributeDefinitionResponse(definition))
+	}
+	return ctx.JSON(http.StatusOK, response)
+}
+
+// (PUT /admin/attributes/{name}) Put attribute definition endpoint, admin only, defines a custom attribute or replaces its definition.
+// Values users already have are checked against it the next time their attributes are written
+func (s *Server) PutAttributeDefinition(ctx echo.Context, name string, params generated.PutAttributeDefinitionParams) error {
+	_, err := s.AuthorizeAdmin(ctx.Request().Context(), params.Authorization)
+	if err != nil {
+		return err
+	}
+
+	var request generated.PutAttributeDefinitionJSONRequestBody
+	if err := ctx.Bind(&request); err != nil {
+		return err
+	}
+
+	definition := repository.AttributeDefinition{
+		Name:     name,
+		Type:     request.Type,
+		Required: request.Required != nil && *request.Required,
+		Unique:   request.Unique != nil && *request.Unique,
+	}
+	if request.Description != nil {
+		definition.Description = *request.Description
+	}
+	if request.AllowedValues != nil && len(*request.AllowedValues) != 0 {
+		definition.AllowedValues = *request.AllowedValues
+	}
+
+	var errors validation.Errors
+	if !attributeName.MatchString(name) {
+		errors.Add("name", i18n.Pattern, attributeName.String())
+	}
+	if !containsString(attributeTypes, definition.Type) {
+		errors.Add("type", i18n.OneOf, strings.Join(attributeTypes, ", "))
+	}
+	if len(definition.Description) > attributeMaxLength {
+		errors.Add("description", i18n.MaxLength, attributeMaxLength)
+	}
+	for i, value := range definition.AllowedValues {
+		if attributeType(value) != definition.Type {
+			errors.Add(fmt.Sprintf("allowed_values.%d", i), i18n.InvalidType, definition.Type)
+		}
+	}
+	if len(errors) != 0 {
+		return validationProblem(http.StatusBadRequest, errors...)
+	}
+
+	definition, err = s.Repository.PutAttributeDefinition(ctx.Request().Context(), definition)
+	if attributeTaken(err) != nil {
+		return newProblem(http.StatusConflict, i18n.AttributeNotUnique)
+	} else if err != nil {
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, attributeDefinitionResponse(definition))
+}
+
+// (DELETE /admin/attributes/{name}) Delete attribute definition endpoint, admin only, removes the attribute from every user
+func (s *Server) DeleteAttributeDefinition(ctx echo.Context, name string, params generated.DeleteAttributeDefinitionParams) error {
+	_, err := s.AuthorizeAdmin(ctx.Request().Context(), params.Authorization)
+	if err != nil {
+		return err
+	}
+
+	err = s.Repository.DeleteAttributeDefinition(ctx.Request().Context(), name)
+	if err == sql.ErrNoRows {
+		return newProblem(http.StatusNotFound, i18n.AttributeNotFound)
+	} else if err != nil {
+		return err
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// (PUT /admin/users/{id}/attributes) Update user attributes endpoint, admin only, replaces the custom attributes of a user
+func (s *Server) UpdateUserAttributes(ctx echo.Context, id int, params generated.UpdateUserAttributesParams) error {
+	_, err := s.AuthorizeAdmin(ctx.Request().Context(), params.Authorization)
+	if err != nil {
+		return err
+	}
+
+	var request generated.UpdateUserAttributesJSONRequestBody
+	if err := ctx.Bind(&request); err != nil {
+		return err
+	}
+
+	user, err := s.Repository.GetUserById(ctx.Request().Context(), id)
+	if err == sql.ErrNoRows {
+		return newProblem(http.StatusNotFound, i18n.UserNotFound)
+	} else if err != nil {
+		return err
+	}
+
+	attributes, errors, err := s.checkAttributes(ctx.Request().Context(), request.Attributes)
+	if err != nil {
+		return err
+	} else if len(errors) != 0 {
+		return validationProblem(http.StatusBadRequest, errors...)
+	}
+
+	user, err = s.Repository.UpdateUserById(ctx.Request().Context(), repository.UpdateUserInput{
+		Id:         user.Id,
+		Name:       user.Name,
+		Phone:      user.Phone,
+		Locale:     user.Locale,
+		Timezone:   user.Timezone,
+		Avatar:     user.Avatar,
+		Attributes: attributes,
+		Version:    user.Version,
+	})
+	if err == repository.ErrVersionMismatch {
+		return newProblem(http.StatusConflict, i18n.UserModifiedRetry)
+	} else if takenErr := attributeTaken(err); takenErr != nil {
+		return validationProblem(http.StatusBadRequest, takenErr)
+	} else if err != nil {
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, adminUserResponse(user))
+}
+
+/*
+Check the attributes a user is given against their definitions, shared by registration and admins.
+  - every attribute must be defined and its value of the type of the definition, among its allowed values
+  - required attributes must have a value, null is no value
+  - failures are named attributes.<name> in the order of the names
+
+Uniqueness is left to the database, see repository.PutAttributeDefinition
+*/
+func (s *Server) checkAttributes(ctx context.Context, values map[string]interface{}) (attributes repository.Attributes, errors validation.Errors, err error) {
+	definitions, err := s.attributeDefinitions(ctx)
+	if err != nil {
+		return
+	}
+	attributes, errors = validateAttributes(definitions, values)
+	return
+}
+
+// Check attributes against definitions already loaded, imports check every row against the same definitions
+func validateAttributes(definitions map[string]repository.AttributeDefinition, values map[string]interface{}) (attributes repository.Attributes, errors validation.Errors) {
+	names := make([]string, 0, len(values)+len(definitions))
+	for name := range values {
+		names = append(names, name)
+	}
+	for name := range definitions {
+		if _, ok := values[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	attributes = make(repository.Attributes)
+	for _, name := range names {
+		field := "attributes." + name
+		definition, defined := definitions[name]
+		value := values[name]
+		switch {
+		case !defined:
+			errors.Add(field, i18n.UnknownField)
+		case value == nil && definition.Required:
+			errors.Add(field, i18n.Required, field)
+		case value == nil:
+		case attributeType(value) != definition.Type:
+			errors.Add(field, i18n.InvalidType, definition.Type)
+		case definition.Type == repository.AttributeTypeString && len(value.(string)) > attributeMaxLength:
+			errors.Add(field, i18n.MaxLength, attributeMaxLength)
+		case definition.AllowedValues != nil && !containsValue(definition.AllowedValues, value):
+			errors.Add(field, i18n.OneOf, formatValues(definition.AllowedValues))
+		default:
+			attributes[name] = value
+		}
+	}
+	return
+}
+
+// Parse name:value filters of the user listing into the values users must have, typed by their definitions
+func (s *Server) attributeFilters(ctx context.Context, filters []string) (attributes repository.Attributes, errors validation.Errors, err error) {
+	definitions, err := s.attributeDefinitions(ctx)
+	if err != nil {
+		return
+	}
+
+	attributes = make(repository.Attributes)
+	for _, filter := range filters {
+		name, value, found := strings.Cut(filter, ":")
+		definition, defined := definitions[name]
+		field := "attribute." + name
+		if !found {
+			errors.Add("attribute", i18n.InvalidValue)
+			continue
+		} else if !defined {
+			errors.Add(field, i18n.UnknownField)
+			continue
+		}
+
+		var parseErr error
+		if attributes[name], parseErr = parseAttribute(definition, value); parseErr != nil {
+			errors.Add(field, i18n.InvalidType, definition.Type)
+		}
+	}
+	return
+}
+
+// Parse the text form of a value, as written in query strings and csv cells, into the type of its definition
+func parseAttribute(definition repository.AttributeDefinition, value string) (interface{}, error) {
+	switch definition.Type {
+	case repository.AttributeTypeNumber:
+		return strconv.ParseFloat(value, 64)
+	case repository.AttributeTypeBoolean:
+		return strconv.ParseBool(value)
+	default:
+		return value, nil
+	}
+}
+
+// Failure of a write refused because another user has the value of a unique attribute, nil for other errors
+func attributeTaken(err error) *validation.Error {
+	var takenErr *repository.AttributeTakenError
+	if !errors.As(err, &takenErr) {
+		return nil
+	}
+	return validation.NewError("attributes."+takenErr.Name, i18n.AttributeTaken)
+}
+
+// Attribute definitions by name
+func (s *Server) attributeDefinitions(ctx context.Context) (map[string]repository.AttributeDefinition, error) {
+	definitions, err := s.Repository.ListAttributeDefinitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]repository.AttributeDefinition, len(definitions))
+	for _, definition := range definitions {
+		byName[definition.Name] = definition
+	}
+	return byName, nil
+}
+
+// Type of an attribute value decoded from json, empty for arrays, objects and null
+func attributeType(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return repository.AttributeTypeString
+	case float64:
+		return repository.AttributeTypeNumber
+	case bool:
+		return repository.AttributeTypeBoolean
+	default:
+		return ""
+	}
+}
+
+// Values decoded from json are strings, float64 and bool, which compare with ==
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func formatValues(values []interface{}) string {
+	formatted := make([]string, len(values))
+	for i, value := range values {
+		formatted[i] = fmt.Sprint(value)
+	}
+	return strings.Join(formatted, ", ")
+}
+
+func attributeDefinitionResponse(definition repository.AttributeDefinition) generated.AttributeDefinition {
+	response := generated.AttributeDefinition{
+		Name:        definition.Name,
+		Type:        definition.Type,
+		Required:    definition.Required,
+		Unique:      definition.Unique,
+		Description: definition.Description,
+		UpdatedAt:   definition.UpdatedAt,
+		CreatedAt:   definition.CreatedAt,
+	}
+	if definition.AllowedValues != nil {
+		response.AllowedValues = &definition.AllowedValues
+	}
+	return response
+}
+
+// Attributes of a user as api.yml returns them, left out when the user has none
+func attributesResponse(attributes repository.Attributes) *generated.Attributes {
+	if len(attributes) == 0 {
+		return nil
+	}
+	response := generated.Attributes(attributes)
+	return &response
+}
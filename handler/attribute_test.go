@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/AthanatiusC/SawitPro/generated"
+	"github.com/AthanatiusC/SawitPro/repository"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// Definitions shared by the attribute tests
+var testAttributeDefinitions = []repository.AttributeDefinition{
+	{Name: "employee_number", Type: repository.AttributeTypeString, Required: true, Unique: true},
+	{Name: "estate_code", Type: repository.AttributeTypeString, AllowedValues: []interface{}{"KLT-01", "KLT-02"}},
+	{Name: "team_size", Type: repository.AttributeTypeNumber},
+}
+
+/*
+TestPutAttributeDefinition Criteria:
+- Names, types and allowed values of the wrong type are refused field by field
+- Making an attribute unique while users share a value is refused with 409
+*/
+func TestPutAttributeDefinition(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	e := echo.New()
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	h := NewServer(NewServerOptions{Repository: repo})
+
+	admin := repository.User{Id: 1, Status: repository.UserStatusActive, Role: repository.RoleAdmin}
+	repo.EXPECT().GetUserById(gomock.Any(), admin.Id).Return(admin, nil).AnyTimes()
+	repo.EXPECT().PutAttributeDefinition(gomock.Any(), repository.AttributeDefinition{Name: "estate_code", Type: repository.AttributeTypeString, AllowedValues: []interface{}{"KLT-01"}}).
+		Return(repository.AttributeDefinition{Name: "estate_code", Type: repository.AttributeTypeString, AllowedValues: []interface{}{"KLT-01"}}, nil)
+	repo.EXPECT().PutAttributeDefinition(gomock.Any(), repository.AttributeDefinition{Name: "employee_number", Type: repository.AttributeTypeString, Unique: true}).
+		Return(repository.AttributeDefinition{}, &repository.AttributeTakenError{Name: "employee_number"})
+
+	token, err := h.GenerateJWT(JWTClaims{UserId: admin.Id})
+	if err != nil {
+		t.Error(err)
+	}
+	token = fmt.Sprintf("Bearer %s", token)
+
+	put := func(name string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/admin/attributes/"+name, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		assert.NoError(t, serve(h, e.NewContext(req, rec), func(ctx echo.Context) error {
+			return h.PutAttributeDefinition(ctx, name, generated.PutAttributeDefinitionParams{Authorization: &token})
+		}))
+		return rec
+	}
+
+	rec := put("estate_code", `{"type":"string","allowed_values":["KLT-01"]}`)
+	if assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String()) {
+		assert.Contains(t, rec.Body.String(), `"allowed_values":["KLT-01"]`)
+	}
+
+	rec = put("Estate-Code", `{"type":"date","allowed_values":[1]}`)
+	if assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String()) {
+		assert.Contains(t, rec.Body.String(), `"code":"pattern","field":"name"`)
+		assert.Contains(t, rec.Body.String(), `"code":"one_of","field":"type"`)
+		assert.Contains(t, rec.Body.String(), `"code":"invalid_type","field":"allowed_values.0"`)
+	}
+
+	rec = put("employee_number", `{"type":"string","unique":true}`)
+	if assert.Equal(t, http.StatusConflict, rec.Code, rec.Body.String()) {
+		assert.Contains(t, rec.Body.String(), `"code":"attribute_not_unique"`)
+	}
+}
+
+/*
+TestUpdateUserAttributes Criteria:
+- Attributes are checked against their definitions, every failure is listed
+- Valid attributes replace those of the user and are returned
+- A value of a unique attribute another user has is refused on that attribute
+*/
+func TestUpdateUserAttributes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	e := echo.New()
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	h := NewServer(NewServerOptions{Repository: repo})
+
+	admin := repository.User{Id: 1, Status: repository.UserStatusActive, Role: repository.RoleAdmin}
+	user := repository.User{Id: 2, Name: "user", Phone: "+6280000000000", Status: repository.UserStatusActive, Version: 4}
+	repo.EXPECT().GetUserById(gomock.Any(), admin.Id).Return(admin, nil).AnyTimes()
+	repo.EXPECT().GetUserById(gomock.Any(), user.Id).Return(user, nil).AnyTimes()
+	repo.EXPECT().ListAttributeDefinitions(gomock.Any()).Return(testAttributeDefinitions, nil).AnyTimes()
+
+	attributes := repository.Attributes{"employee_number": "EMP-001", "team_size": float64(12)}
+	repo.EXPECT().UpdateUserById(gomock.Any(), repository.UpdateUserInput{Id: user.Id, Name: user.Name, Phone: user.Phone, Attributes: attributes, Version: 4}).
+		Return(repository.User{Id: user.Id, Name: user.Name, Phone: user.Phone, Status: user.Status, Attributes: attributes, Version: 5}, nil)
+	repo.EXPECT().UpdateUserById(gomock.Any(), gomock.Any()).Return(repository.User{}, &repository.AttributeTakenError{Name: "employee_number"})
+
+	token, err := h.GenerateJWT(JWTClaims{UserId: admin.Id})
+	if err != nil {
+		t.Error(err)
+	}
+	token = fmt.Sprintf("Bearer %s", token)
+
+	put := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/admin/users/2/attributes", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		assert.NoError(t, serve(h, e.NewContext(req, rec), func(ctx echo.Context) error {
+			return h.UpdateUserAttributes(ctx, user.Id, generated.UpdateUserAttributesParams{Authorization: &token})
+		}))
+		return rec
+	}
+
+	rec := put(`{"attributes":{"estate_code":"KLT-09","team_size":"twelve","harvest_team":"A"}}`)
+	if assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String()) {
+		body := rec.Body.String()
+		assert.Contains(t, body, `"code":"required","field":"attributes.employee_number"`)
+		assert.Contains(t, body, `"code":"one_of","field":"attributes.estate_code"`)
+		assert.Contains(t, body, `"code":"unknown_field","field":"attributes.harvest_team"`)
+		assert.Contains(t, body, `"code":"invalid_type","field":"attributes.team_size"`)
+	}
+
+	rec = put(`{"attributes":{"employee_number":"EMP-001","team_size":12,"estate_code":null}}`)
+	if assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String()) {
+		assert.Contains(t, rec.Body.String(), `"attributes":{"employee_number":"EMP-001","team_size":12}`)
+	}
+
+	rec = put(`{"attributes":{"employee_number":"EMP-001"}}`)
+	if assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String()) {
+		assert.Contains(t, rec.Body.String(), `"code":"attribute_taken","field":"attributes.employee_number"`)
+	}
+}
+
+/*
+TestListUsersByAttribute Criteria:
+- Attribute filters are typed by their definition and passed to the repository
+- Unknown attributes and values of the wrong type are refused
+*/
+func TestListUsersByAttribute(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	e := echo.New()
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	h := NewServer(NewServerOptions{Repository: repo})
+
+	admin := repository.User{Id: 1, Status: repository.UserStatusActive, Role: repository.RoleAdmin}
+	repo.EXPECT().GetUserById(gomock.Any(), admin.Id).Return(admin, nil).AnyTimes()
+	repo.EXPECT().ListAttributeDefinitions(gomock.Any()).Return(testAttributeDefinitions, nil).AnyTimes()
+	repo.EXPECT().ListUsers(gomock.Any(), repository.ListUsersInput{
+		Attributes: repository.Attributes{"estate_code": "KLT-02", "team_size": float64(12)},
+		SortBy:     repository.UserSortCreatedAt,
+		Limit:      21,
+	}).Return(nil, nil)
+
+	token, err := h.GenerateJWT(JWTClaims{UserId: admin.Id})
+	if err != nil {
+		t.Error(err)
+	}
+	token = fmt.Sprintf("Bearer %s", token)
+
+	list := func(attributes ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/admin/users", nil)
+		rec := httptest.NewRecorder()
+		assert.NoError(t, serve(h, e.NewContext(req, rec), func(ctx echo.Context) error {
+			return h.ListUsers(ctx, generated.ListUsersParams{Attribute: &attributes, Authorization: &token})
+		}))
+		return rec
+	}
+
+	rec := list("estate_code:KLT-02", "team_size:12")
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = list("team_size:twelve", "harvest_team:A", "estate_code")
+	if assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String()) {
+		body := rec.Body.String()
+		assert.Contains(t, body, `"code":"invalid_type","field":"attribute.team_size"`)
+		assert.Contains(t, body, `"code":"unknown_field","field":"attribute.harvest_team"`)
+		assert.Contains(t, body, `"code":"invalid_value","field":"attribute"`)
+	}
+}
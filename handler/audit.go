@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+
+	"github.com/AthanatiusC/SawitPro/generated"
+	"github.com/AthanatiusC/SawitPro/i18n"
+	"github.com/AthanatiusC/SawitPro/repository"
+	"github.com/AthanatiusC/SawitPro/validation"
+	"github.com/labstack/echo/v4"
+)
+
+/*
+Middleware attaching the request id and client ip to the request context, register with echo Use.
+Repository writes record them in their audit event, Authenticate fills in the actor.
+A request id sent by a proxy is kept, otherwise one is generated, it is echoed in X-Request-ID
+*/
+func (s *Server) AuditContext(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		requestId := truncate(ctx.Request().Header.Get(echo.HeaderXRequestID), 64)
+		if requestId == "" {
+			requestId = generateRequestId()
+		}
+		ctx.Response().Header().Set(echo.HeaderXRequestID, requestId)
+
+		request := ctx.Request()
+		ctx.SetRequest(request.WithContext(repository.WithAuditMetadata(request.Context(), &repository.AuditMetadata{
+			RequestId: requestId,
+			Ip:        clientIP(ctx),
+		})))
+
+		return next(ctx)
+	}
+}
+
+// Random request id, empty in the unlikely case the system has no randomness left
+func generateRequestId() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(id)
+}
+
+// (GET /admin/audit-events) List audit events endpoint, admin only, returns writes to users newest first
+func (s *Server) ListAuditEvents(ctx echo.Context, params generated.ListAuditEventsParams) error {
+	_, err := s.AuthorizeAdmin(ctx.Request().Context(), params.Authorization)
+	if err != nil {
+		return err
+	}
+
+	var errors validation.Errors
+	limit, limitErr := pageLimit(params.Limit)
+	if limitErr != nil {
+		errors = append(errors, limitErr)
+	}
+
+	input := repository.ListAuditEventsInput{
+		CreatedAfter:  params.From,
+		CreatedBefore: params.To,
+	}
+	if params.UserId != nil {
+		if *params.UserId < 1 {
+			errors.Add("user_id", i18n.MustBeUserId)
+		}
+		input.TargetId = *params.UserId
+	}
+	if params.From != nil && params.To != nil && !params.To.After(*params.From) {
+		errors.Add("to", i18n.MustBeAfter, "from")
+	}
+	if params.Cursor != nil {
+		input.BeforeId, err = decodeIdCursor(*params.Cursor)
+		if err != nil {
+			errors.Add("cursor", i18n.InvalidCursor)
+		}
+	}
+	if len(errors) != 0 {
+		return validationProblem(http.StatusBadRequest, errors...)
+	}
+
+	// Fetch one extra row to know whether another page exists
+	input.Limit = limit + 1
+	events, err := s.Repository.ListAuditEvents(ctx.Request().Context(), input)
+	if err != nil {
+		return err
+	}
+
+	response := generated.AuditEventListResponse{AuditEvents: []generated.AuditEvent{}}
+	if len(events) > limit {
+		events = events[:limit]
+		nextCursor := encodeIdCursor(events[limit-1].Id)
+		response.NextCursor = &nextCursor
+	}
+
+	for _, event := range events {
+		response.AuditEvents = append(response.AuditEvents, generated.AuditEvent{
+			Id:        event.Id,
+			ActorId:   event.ActorId,
+			UserId:    event.TargetId,
+			Action:    event.Action,
+			Before:    auditValues(event.Before),
+			After:     auditValues(event.After),
+			RequestId: event.RequestId,
+			Ip:        event.Ip,
+			CreatedAt: event.CreatedAt,
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, response)
+}
+
+// Decode the audited fields of an event, nil when the event has none
+func auditValues(raw json.RawMessage) *map[string]interface{} {
+	if raw == nil {
+		return nil
+	}
+
+	var values map[string]interface{}
+	if err := json.Unmarshal(raw, &values); err != nil {
+		return nil
+	}
+	return &values
+}
@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/AthanatiusC/SawitPro/generated"
+	"github.com/AthanatiusC/SawitPro/repository"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+TestAuditContext Criteria:
+- Request id sent by the client is kept, otherwise one is generated
+- Writes made after authentication are attributed to the caller
+*/
+func TestAuditContext(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	e := echo.New()
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	h := NewServer(NewServerOptions{Repository: repo})
+
+	user := repository.User{Id: 1, Status: repository.UserStatusActive}
+	repo.EXPECT().GetUserById(gomock.Any(), user.Id).Return(user, nil)
+	repo.EXPECT().DeleteUserById(gomock.Any(), user.Id).
+		DoAndReturn(func(ctx context.Context, _ int) (repository.User, error) {
+			metadata := repository.AuditMetadataFromContext(ctx)
+			if assert.NotNil(t, metadata) {
+				assert.Equal(t, user.Id, metadata.ActorId)
+				assert.Equal(t, "request-1", metadata.RequestId)
+				assert.Equal(t, "192.0.2.1", metadata.Ip)
+			}
+			deletedAt := time.Now()
+			user.DeletedAt = &deletedAt
+			return user, nil
+		})
+
+	token, err := h.GenerateJWT(JWTClaims{UserId: user.Id})
+	if err != nil {
+		t.Error(err)
+	}
+	token = fmt.Sprintf("Bearer %s", token)
+
+	req := httptest.NewRequest(http.MethodDelete, "/user", nil)
+	req.Header.Set(echo.HeaderXRequestID, "request-1")
+	req.RemoteAddr = "192.0.2.1:1234"
+	rec := httptest.NewRecorder()
+	handler := h.AuditContext(func(ctx echo.Context) error {
+		return h.DeleteUser(ctx, generated.DeleteUserParams{Authorization: &token})
+	})
+	assert.NoError(t, serve(h, e.NewContext(req, rec), handler))
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "request-1", rec.Header().Get(echo.HeaderXRequestID))
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	rec = httptest.NewRecorder()
+	handler = h.AuditContext(func(ctx echo.Context) error {
+		metadata := repository.AuditMetadataFromContext(ctx.Request().Context())
+		if assert.NotNil(t, metadata) {
+			assert.Zero(t, metadata.ActorId)
+			assert.Len(t, metadata.RequestId, 32)
+		}
+		return ctx.NoContent(http.StatusNoContent)
+	})
+	assert.NoError(t, serve(h, e.NewContext(req, rec), handler))
+	assert.Len(t, rec.Header().Get(echo.HeaderXRequestID), 32)
+}
+
+/*
+TestListAuditEvents Criteria:
+- Admin only
+- User and time range filters are passed to the repository
+- Next cursor resumes after the last event of the page
+- Empty time range is refused
+*/
+func TestListAuditEvents(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	e := echo.New()
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	h := NewServer(NewServerOptions{Repository: repo})
+
+	admin := repository.User{Id: 1, Status: repository.UserStatusActive, Role: repository.RoleAdmin}
+	user := repository.User{Id: 2, Status: repository.UserStatusActive, Role: repository.RoleUser}
+	repo.EXPECT().GetUserById(gomock.Any(), admin.Id).Return(admin, nil).AnyTimes()
+	repo.EXPECT().GetUserById(gomock.Any(), user.Id).Return(user, nil)
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+	page := []repository.AuditEvent{
+		{Id: 9, ActorId: &admin.Id, TargetId: user.Id, Action: repository.AuditActionUserStatusChange, Before: []byte(`{"status":"active"}`), After: []byte(`{"status":"suspended"}`), RequestId: "request-2"},
+		{Id: 7, TargetId: user.Id, Action: repository.AuditActionUserCreate, After: []byte(`{"name":"user","password":"[REDACTED]"}`), RequestId: "request-1"},
+	}
+	gomock.InOrder(
+		repo.EXPECT().ListAuditEvents(gomock.Any(), repository.ListAuditEventsInput{TargetId: user.Id, CreatedAfter: &from, CreatedBefore: &to, Limit: 2}).
+			Return(page, nil),
+		repo.EXPECT().ListAuditEvents(gomock.Any(), repository.ListAuditEventsInput{TargetId: user.Id, CreatedAfter: &from, CreatedBefore: &to, Limit: 2, BeforeId: 9}).
+			Return(page[1:], nil),
+	)
+
+	list := func(id int, params generated.ListAuditEventsParams) *httptest.ResponseRecorder {
+		token, err := h.GenerateJWT(JWTClaims{UserId: id})
+		if err != nil {
+			t.Error(err)
+		}
+		token = fmt.Sprintf("Bearer %s", token)
+		params.Authorization = &token
+
+		req := httptest.NewRequest(http.MethodGet, "/admin/audit-events", nil)
+		rec := httptest.NewRecorder()
+		assert.NoError(t, serve(h, e.NewContext(req, rec), func(ctx echo.Context) error { return h.ListAuditEvents(ctx, params) }))
+		return rec
+	}
+
+	limit := 1
+	params := generated.ListAuditEventsParams{UserId: &user.Id, From: &from, To: &to, Limit: &limit}
+	rec := list(admin.Id, params)
+	if assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String()) {
+		assert.Contains(t, rec.Body.String(), `"before":{"status":"active"}`)
+		assert.Contains(t, rec.Body.String(), `"next_cursor":"`+encodeIdCursor(9)+`"`)
+	}
+
+	cursor := encodeIdCursor(9)
+	params.Cursor = &cursor
+	rec = list(admin.Id, params)
+	if assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String()) {
+		assert.Contains(t, rec.Body.String(), `"actor_id":null`)
+		assert.Contains(t, rec.Body.String(), `"before":null`)
+		assert.Contains(t, rec.Body.String(), `"password":"[REDACTED]"`)
+		assert.Contains(t, rec.Body.String(), `"next_cursor":null`)
+	}
+
+	rec = list(admin.Id, generated.ListAuditEventsParams{From: &to, To: &from})
+	assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+
+	rec = list(user.Id, generated.ListAuditEventsParams{})
+	assert.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+}
@@ -0,0 +1,229 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/AthanatiusC/SawitPro/i18n"
+	"github.com/AthanatiusC/SawitPro/repository"
+)
+
+var (
+	// Returned by Authenticate when the credential must be refused
+	ErrUnauthorized = errors.New("unauthorized")
+	// Returned by Authorize when the credential lacks the required scope
+	ErrInsufficientScope = errors.New("insufficient scope")
+	// Returned when the caller is authenticated but not allowed to use the endpoint
+	ErrForbidden = errors.New("forbidden")
+)
+
+// Principal is the caller resolved from a request credential
+type Principal struct {
+	User     repository.User
+	Claims   JWTClaims
+	ApiKeyId int             // set when authenticated with a personal api key instead of a JWT
+	Actor    repository.User // admin acting as User, set when the token was issued by impersonation
+}
+
+// Check whether an admin is acting as the user
+func (p Principal) IsImpersonated() bool {
+	return p.Claims.ActorId != 0
+}
+
+// Check whether the principal credential was granted the scope
+func (p Principal) HasScope(scope string) bool {
+	return containsString(p.Claims.Scope, scope)
+}
+
+// Roles held by the principal, reported by introspection
+func (p Principal) Roles() []string {
+	return []string{p.User.Role}
+}
+
+/*
+Resolve the caller of an Authorization header value, every authenticated endpoint and the
+introspection endpoint go through the same checks:
+1. Token signature and expiry are valid, or the api key is known, not revoked and not expired
+2. User still exists and is active, suspended and disabled users are refused
+3. Token version matches users.token_version, bumping it revokes every issued token
+4. Session the token was issued with, if any, is not revoked
+*/
+func (s *Server) Authenticate(ctx context.Context, authorization *string) (principal Principal, err error) {
+	if authorization == nil {
+		return principal, ErrUnauthorized
+	}
+
+	auth, err := getToken(*authorization)
+	if err != nil {
+		return principal, ErrUnauthorized
+	}
+
+	principal, err = s.authenticateToken(ctx, auth)
+	if err != nil {
+		return
+	}
+
+	// Writes made for the request are audited as made by the caller, an admin impersonating acts as themselves
+	if metadata := repository.AuditMetadataFromContext(ctx); metadata != nil {
+		metadata.ActorId = principal.User.Id
+		if principal.IsImpersonated() {
+			metadata.ActorId = principal.Actor.Id
+		}
+	}
+
+	// Messages follow the language chosen by the user over Accept-Language
+	if principal.User.Locale != nil {
+		if locale, ok := i18n.Parse(*principal.User.Locale); ok {
+			i18n.SetLocale(ctx, locale)
+		}
+	}
+
+	return
+}
+
+// Authenticate the caller and require the credential to be granted scope
+func (s *Server) Authorize(ctx context.Context, authorization *string, scope string) (principal Principal, err error) {
+	principal, err = s.Authenticate(ctx, authorization)
+	if err != nil {
+		return
+	}
+
+	if !principal.HasScope(scope) {
+		return principal, ErrInsufficientScope
+	}
+
+	return
+}
+
+// Authorize the caller and require the user signed in with their own login token, for operations a
+// leaked api key or an admin impersonating the user must never perform
+func (s *Server) AuthorizeOwner(ctx context.Context, authorization *string, scope string) (principal Principal, err error) {
+	principal, err = s.Authorize(ctx, authorization, scope)
+	if err != nil {
+		return
+	}
+
+	if principal.ApiKeyId != 0 || principal.IsImpersonated() {
+		return principal, ErrForbidden
+	}
+
+	return
+}
+
+// Resolve the caller of a raw access token, see Authenticate
+func (s *Server) authenticateToken(ctx context.Context, raw string) (principal Principal, err error) {
+	if strings.HasPrefix(raw, apiKeyPrefix) {
+		return s.authenticateApiKey(ctx, raw)
+	}
+
+	token := s.parseJWT(raw)
+	if token == nil || !token.Valid {
+		return principal, ErrUnauthorized
+	}
+
+	principal.Claims, err = s.ParseJWTClaims(token)
+	if err != nil {
+		return principal, ErrUnauthorized
+	}
+
+	principal.User, err = s.Repository.GetUserById(ctx, principal.Claims.UserId)
+	if err == sql.ErrNoRows {
+		return principal, ErrUnauthorized
+	} else if err != nil {
+		return
+	}
+
+	if principal.User.Status != repository.UserStatusActive || principal.User.TokenVersion != principal.Claims.Version {
+		return principal, ErrUnauthorized
+	}
+
+	if principal.Claims.SessionId != 0 {
+		session, err := s.Repository.GetSessionById(ctx, principal.Claims.SessionId)
+		if err == sql.ErrNoRows {
+			return principal, ErrUnauthorized
+		} else if err != nil {
+			return principal, err
+		}
+
+		if session.RevokedAt != nil || session.UserId != principal.User.Id {
+			return principal, ErrUnauthorized
+		}
+
+		// Last seen is informational, a failed update must not refuse the request
+		_ = s.Repository.TouchSession(ctx, session.Id)
+	}
+
+	// Impersonation ends as soon as the actor is no longer an active admin
+	if principal.IsImpersonated() {
+		principal.Actor, err = s.Repository.GetUserById(ctx, principal.Claims.ActorId)
+		if err == sql.ErrNoRows {
+			return principal, ErrUnauthorized
+		} else if err != nil {
+			return
+		}
+
+		if principal.Actor.Role != repository.RoleAdmin || principal.Actor.Status != repository.UserStatusActive {
+			return principal, ErrUnauthorized
+		}
+	}
+
+	return
+}
+
+// Authenticate the caller and require an admin signed in with a login token, api keys and
+// impersonated tokens never grant admin access
+func (s *Server) AuthorizeAdmin(ctx context.Context, authorization *string) (principal Principal, err error) {
+	principal, err = s.Authenticate(ctx, authorization)
+	if err != nil {
+		return
+	}
+
+	if principal.User.Role != repository.RoleAdmin || principal.ApiKeyId != 0 || principal.IsImpersonated() {
+		return principal, ErrForbidden
+	}
+
+	return
+}
+
+// Resolve the caller of a personal api key, see Authenticate
+func (s *Server) authenticateApiKey(ctx context.Context, raw string) (principal Principal, err error) {
+	apiKey, err := s.Repository.GetApiKeyByHash(ctx, hashApiKey(raw))
+	if err == sql.ErrNoRows {
+		return principal, ErrUnauthorized
+	} else if err != nil {
+		return
+	}
+
+	if apiKey.RevokedAt != nil || (apiKey.ExpiresAt != nil && apiKey.ExpiresAt.Before(time.Now())) {
+		return principal, ErrUnauthorized
+	}
+
+	principal.User, err = s.Repository.GetUserById(ctx, apiKey.UserId)
+	if err == sql.ErrNoRows {
+		return principal, ErrUnauthorized
+	} else if err != nil {
+		return
+	}
+
+	if principal.User.Status != repository.UserStatusActive {
+		return principal, ErrUnauthorized
+	}
+
+	principal.ApiKeyId = apiKey.Id
+	principal.Claims = JWTClaims{
+		UserId:  principal.User.Id,
+		Version: principal.User.TokenVersion,
+		Scope:   apiKey.Scopes,
+	}
+	if apiKey.ExpiresAt != nil {
+		principal.Claims.ExpiresAt = *apiKey.ExpiresAt
+	}
+
+	// Usage tracking is informational, a failed update must not refuse the request
+	_ = s.Repository.TouchApiKey(ctx, apiKey.Id)
+
+	return
+}
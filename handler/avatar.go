@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg" // registers the decoders of the accepted types
+	_ "image/png"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/AthanatiusC/SawitPro/generated"
+	"github.com/AthanatiusC/SawitPro/i18n"
+	"github.com/AthanatiusC/SawitPro/imaging"
+	"github.com/AthanatiusC/SawitPro/validation"
+	"github.com/labstack/echo/v4"
+)
+
+// Limits of an uploaded avatar, a phone photo fits, a decoded 4096 pixel square is 64 MB
+const (
+	avatarMaxBytes     = 5 << 20
+	avatarMinDimension = 128
+	avatarMaxDimension = 4096
+)
+
+// Sizes an avatar is stored at, the first is the photo and the others its thumbnails
+var avatarSizes = []int{512, 128, 48}
+
+// Types an avatar is accepted as, sniffed from its content rather than trusted from the client
+var avatarTypes = map[string]bool{"image/jpeg": true, "image/png": true}
+
+// (PUT /user/avatar) Update avatar endpoint, stores the center square of an uploaded photo at every avatar size
+// and replaces the previous avatar
+func (s *Server) UpdateAvatar(ctx echo.Context, params generated.UpdateAvatarParams) error {
+	principal, err := s.Authorize(ctx.Request().Context(), params.Authorization, ScopeProfileWrite)
+	if err != nil {
+		return err
+	}
+	user := principal.User
+
+	// The form is read whole before the file is, the limit leaves room for its other parts
+	ctx.Request().Body = http.MaxBytesReader(ctx.Response(), ctx.Request().Body, avatarMaxBytes+64<<10)
+	file, err := ctx.FormFile("avatar")
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) || err == nil && file.Size > avatarMaxBytes {
+		return validationProblem(http.StatusRequestEntityTooLarge, validation.NewError("avatar", i18n.FileTooLarge, avatarMaxBytes))
+	} else if err == http.ErrMissingFile {
+		return validationProblem(http.StatusBadRequest, validation.NewError("avatar", i18n.Required, "avatar"))
+	} else if err != nil {
+		return newProblem(http.StatusBadRequest, i18n.InvalidRequest)
+	}
+
+	reader, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	src, err := decodeAvatar(content)
+	if err != nil {
+		return err
+	}
+
+	prefix, err := s.putAvatar(ctx, user.Id, src)
+	if err != nil {
+		return err
+	}
+
+	user.Avatar = &prefix
+	if err := s.saveUser(ctx, principal.User, user, nil); err != nil {
+		s.deleteAvatar(ctx, prefix)
+		return err
+	}
+	if principal.User.Avatar != nil {
+		s.deleteAvatar(ctx, *principal.User.Avatar)
+	}
+	return nil
+}
+
+// (DELETE /user/avatar) Delete avatar endpoint, removes the avatar and its thumbnails
+func (s *Server) DeleteAvatar(ctx echo.Context, params generated.DeleteAvatarParams) error {
+	principal, err := s.Authorize(ctx.Request().Context(), params.Authorization, ScopeProfileWrite)
+	if err != nil {
+		return err
+	}
+	user := principal.User
+
+	user.Avatar = nil
+	if err := s.saveUser(ctx, principal.User, user, nil); err != nil {
+		return err
+	}
+	if principal.User.Avatar != nil {
+		s.deleteAvatar(ctx, *principal.User.Avatar)
+	}
+	return nil
+}
+
+/*
+Decode an uploaded avatar, failures are returned as validation problems.
+The dimensions are read from the header first so oversized images are refused before they are decoded
+*/
+func decodeAvatar(content []byte) (image.Image, error) {
+	if !avatarTypes[http.DetectContentType(content)] {
+		return nil, validationProblem(http.StatusBadRequest, validation.NewError("avatar", i18n.ImageType, "jpeg or png"))
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return nil, validationProblem(http.StatusBadRequest, validation.NewError("avatar", i18n.ImageType, "jpeg or png"))
+	}
+	if config.Width < avatarMinDimension || config.Height < avatarMinDimension ||
+		config.Width > avatarMaxDimension || config.Height > avatarMaxDimension {
+		return nil, validationProblem(http.StatusBadRequest, validation.NewError("avatar", i18n.ImageDimensions, avatarMinDimension, avatarMaxDimension))
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, validationProblem(http.StatusBadRequest, validation.NewError("avatar", i18n.ImageType, "jpeg or png"))
+	}
+	return src, nil
+}
+
+// Store src at every avatar size under a new prefix, so the previous avatar is served until the profile points here
+func (s *Server) putAvatar(ctx echo.Context, userId int, src image.Image) (prefix string, err error) {
+	random := make([]byte, 8)
+	if _, err = rand.Read(random); err != nil {
+		return
+	}
+	prefix = fmt.Sprintf("avatars/%d/%s", userId, hex.EncodeToString(random))
+
+	for _, size := range avatarSizes {
+		var buf bytes.Buffer
+		if err = imaging.EncodeJPEG(&buf, imaging.Thumbnail(src, size)); err != nil {
+			break
+		}
+		if err = s.Blobs.Put(ctx.Request().Context(), avatarKey(prefix, size), "image/jpeg", &buf); err != nil {
+			break
+		}
+	}
+	if err != nil {
+		s.deleteAvatar(ctx, prefix)
+		return "", err
+	}
+	return
+}
+
+// Delete the blobs of an avatar, failures are logged, an orphaned blob is only wasted space
+func (s *Server) deleteAvatar(ctx echo.Context, prefix string) {
+	if err := s.Blobs.DeletePrefix(ctx.Request().Context(), prefix); err != nil {
+		ctx.Logger().Errorf("failed to delete avatar %s: %v", prefix, err)
+	}
+}
+
+// URLs of the avatar stored under prefix
+func (s *Server) avatarResponse(prefix string) *generated.Avatar {
+	avatar := &generated.Avatar{
+		Url:        s.Blobs.URL(avatarKey(prefix, avatarSizes[0])),
+		Thumbnails: make(map[string]string, len(avatarSizes)-1),
+	}
+	for _, size := range avatarSizes[1:] {
+		avatar.Thumbnails[strconv.Itoa(size)] = s.Blobs.URL(avatarKey(prefix, size))
+	}
+	return avatar
+}
+
+func avatarKey(prefix string, size int) string {
+	return fmt.Sprintf("%s/%d.jpg", prefix, size)
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/AthanatiusC/SawitPro/generated"
+	"github.com/AthanatiusC/SawitPro/repository"
+	"github.com/AthanatiusC/SawitPro/storage"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+TestUpdateAvatar Criteria:
+- A valid photo is stored at every avatar size and its URLs are returned with the profile
+- The previous avatar is deleted once replaced
+- Files that are not jpeg or png, or too small, are refused before anything is stored
+*/
+func TestUpdateAvatar(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	e := echo.New()
+	dir := t.TempDir()
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	h := NewServer(NewServerOptions{Repository: repo, Blobs: storage.NewLocalStore(dir, "/blobs")})
+
+	previous := "avatars/1/old"
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, previous), 0o755))
+	user := repository.User{Id: 1, Name: "user", Phone: "+6280000000000", Status: repository.UserStatusActive, Avatar: &previous, Version: 2}
+	repo.EXPECT().GetUserById(gomock.Any(), user.Id).Return(user, nil).Times(3)
+
+	var prefix string
+	repo.EXPECT().UpdateUserById(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ interface{}, input repository.UpdateUserInput) (repository.User, error) {
+			prefix = *input.Avatar
+			return repository.User{Id: 1, Name: "user", Phone: user.Phone, Avatar: input.Avatar, Version: 3}, nil
+		})
+
+	token, err := h.GenerateJWT(JWTClaims{UserId: user.Id})
+	if err != nil {
+		t.Error(err)
+	}
+	token = fmt.Sprintf("Bearer %s", token)
+
+	upload := func(content []byte) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		part, _ := form.CreateFormFile("avatar", "avatar.png")
+		part.Write(content)
+		form.Close()
+
+		req := httptest.NewRequest(http.MethodPut, "/user/avatar", &body)
+		req.Header.Set(echo.HeaderContentType, form.FormDataContentType())
+		rec := httptest.NewRecorder()
+		assert.NoError(t, serve(h, e.NewContext(req, rec), func(ctx echo.Context) error {
+			return h.UpdateAvatar(ctx, generated.UpdateAvatarParams{Authorization: &token})
+		}))
+		return rec
+	}
+	encodePNG := func(width, height int) []byte {
+		var buf bytes.Buffer
+		assert.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))))
+		return buf.Bytes()
+	}
+
+	rec := upload(encodePNG(600, 300))
+	if assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String()) {
+		assert.Contains(t, rec.Body.String(), fmt.Sprintf(`"url":"/blobs/%s/512.jpg"`, prefix))
+		assert.Contains(t, rec.Body.String(), fmt.Sprintf(`"48":"/blobs/%s/48.jpg"`, prefix))
+		for _, size := range avatarSizes {
+			assert.FileExists(t, filepath.Join(dir, prefix, fmt.Sprintf("%d.jpg", size)))
+		}
+		assert.NoDirExists(t, filepath.Join(dir, previous))
+	}
+
+	rec = upload([]byte("GIF89a not an avatar"))
+	if assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String()) {
+		assert.Contains(t, rec.Body.String(), "avatar : must be a jpeg or png image")
+	}
+
+	rec = upload(encodePNG(64, 64))
+	if assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String()) {
+		assert.Contains(t, rec.Body.String(), "avatar : must be 128 to 4096 pixels wide and high")
+	}
+}
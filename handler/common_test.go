@@ -1,13 +1,30 @@
 package handler
 
 import (
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 
+	"github.com/AthanatiusC/SawitPro/generated"
+	"github.com/AthanatiusC/SawitPro/i18n"
+	"github.com/AthanatiusC/SawitPro/phonenumber"
 	"github.com/AthanatiusC/SawitPro/repository"
 	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
 )
 
+// Run a handler like echo does, an error it returns is written by the error handler of the server
+func serve(h *Server, ctx echo.Context, handler echo.HandlerFunc) error {
+	if err := handler(ctx); err != nil {
+		return h.problemResponse(ctx, err)
+	}
+	return nil
+}
+
 type TestCaseRequest struct {
 	FullName    string `json:"full_name"`
 	Password    string `json:"password"`
@@ -36,9 +53,99 @@ func TestValidateUser(t *testing.T) {
 	h := NewServer(opts)
 
 	for _, tc := range testCases {
-		errors := h.ValidateUser(tc)
-		if len(errors.Messages) == 0 {
-			t.Errorf("Unexpected Condition: \n expected: %s\nactual  : %s", tc, errors.Messages)
+		errors := h.Users.Validate(tc)
+		if len(errors) == 0 {
+			t.Errorf("Unexpected Condition: \n expected: %s\nactual  : %s", tc, errors)
 		}
 	}
 }
+
+/*
+TestNormalizePhoneNumber Criteria:
+- National and international formats of the allowed regions are normalized to E.164
+- Numbers of other regions or with a length invalid for their region are refused
+*/
+func TestNormalizePhoneNumber(t *testing.T) {
+	phoneNumbers, err := phonenumber.NewParser("ID", "MY", "PG")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewServer(NewServerOptions{PhoneNumbers: phoneNumbers})
+
+	valid := map[string]string{
+		"+6281234567890":     "+6281234567890",
+		"6281234567890":      "+6281234567890",
+		"081234567890":       "+6281234567890",
+		"0812-3456-7890":     "+6281234567890",
+		"+62 0812 3456 7890": "+6281234567890",
+		"006281234567890":    "+6281234567890",
+		"+60 12-345 6789":    "+60123456789",
+		"+675 7123 4567":     "+67571234567",
+	}
+	for input, expected := range valid {
+		errors := h.Users.Validate(struct {
+			PhoneNumber string `json:"phone_number"`
+		}{input})
+		assert.Empty(t, errors, input)
+		assert.Equal(t, expected, h.NormalizePhoneNumber(input), input)
+	}
+
+	invalid := []string{
+		"+6581234567",      // Singapore is not allowed
+		"+60 12 345",       // too short for Malaysia
+		"+675 7123 45678",  // too long for Papua New Guinea
+		"+628123456789012", // too long for Indonesia
+		"+62 (812) x 3456", // letters
+	}
+	for _, input := range invalid {
+		errors := h.Users.Validate(struct {
+			PhoneNumber string `json:"phone_number"`
+		}{input})
+		assert.NotEmpty(t, errors, input)
+	}
+}
+
+/*
+TestValidationErrors Criteria:
+- Errors are returned in the order of the request fields with their code and named params
+- Messages keep the "field : message" format with the failures of a field joined
+*/
+func TestValidationErrors(t *testing.T) {
+	h := NewServer(NewServerOptions{})
+
+	errors, messages := localizeErrors(h.Users.Validate(TestCaseRequest{FullName: "Jo", Password: "password", PhoneNumber: ""}), i18n.English)
+	assert.Equal(t, []generated.ValidationError{
+		{Field: "full_name", Code: "length_between", Message: "must be more than 3 and less than 60 characters long", Params: map[string]interface{}{"min": 3, "max": 60}},
+		{Field: "password", Code: "password_special", Message: "at least 1 special character", Params: map[string]interface{}{}},
+		{Field: "password", Code: "password_capital", Message: "at least 1 capital character", Params: map[string]interface{}{}},
+		{Field: "password", Code: "password_number", Message: "at least 1 number", Params: map[string]interface{}{}},
+		{Field: "phone_number", Code: "required", Message: "phone_number is required", Params: map[string]interface{}{"field": "phone_number"}},
+	}, errors)
+	assert.Equal(t, []string{
+		"full_name : must be more than 3 and less than 60 characters long",
+		"password : at least 1 special character, at least 1 capital character, at least 1 number",
+		"phone_number : phone_number is required",
+	}, messages)
+}
+
+/*
+TestClientIP Criteria:
+- X-Forwarded-For is ignored unless the IPExtractor trusts the proxy
+- Addresses are cut to the 45 characters of the ip columns
+*/
+func TestClientIP(t *testing.T) {
+	e := echo.New()
+	e.IPExtractor = echo.ExtractIPFromXFFHeader(echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false))
+
+	req := httptest.NewRequest(http.MethodPost, "/login", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set(echo.HeaderXForwardedFor, "198.51.100.7")
+	assert.Equal(t, "192.0.2.1", clientIP(e.NewContext(req, httptest.NewRecorder())))
+
+	e.IPExtractor = echo.ExtractIPFromXFFHeader(echo.TrustIPRange(&net.IPNet{IP: net.IPv4(192, 0, 2, 0), Mask: net.CIDRMask(24, 32)}))
+	assert.Equal(t, "198.51.100.7", clientIP(e.NewContext(req, httptest.NewRecorder())))
+
+	e.IPExtractor = nil
+	req.Header.Set(echo.HeaderXForwardedFor, strings.Repeat("f", 60))
+	assert.Len(t, clientIP(e.NewContext(req, httptest.NewRecorder())), 45)
+}
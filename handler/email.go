@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/AthanatiusC/SawitPro/generated"
+	"github.com/AthanatiusC/SawitPro/i18n"
+	"github.com/AthanatiusC/SawitPro/notification"
+	"github.com/AthanatiusC/SawitPro/repository"
+	"github.com/labstack/echo/v4"
+)
+
+// Page opened by email verification links when none is configured
+const defaultEmailVerificationURL = "http://localhost:3000/verify-email"
+
+// Verification links are long lived since email is slow to arrive, resent links invalidate the previous one
+const (
+	emailVerificationTTL         = 24 * time.Hour
+	emailVerificationResendDelay = time.Minute
+)
+
+// (PUT /user/email) Update email endpoint, sends a verification link to the email, it becomes the email
+// of the user, usable to sign in, once the link is followed
+func (s *Server) UpdateEmail(ctx echo.Context, params generated.UpdateEmailParams) error {
+	principal, err := s.AuthorizeOwner(ctx.Request().Context(), params.Authorization, ScopeProfileWrite)
+	if err != nil {
+		return err
+	}
+
+	var request generated.UpdateEmailJSONRequestBody
+	if err := ctx.Bind(&request); err != nil {
+		return err
+	}
+
+	email := NormalizeEmail(string(request.Email))
+	errors := s.Users.Validate(struct {
+		Email string `json:"email"`
+	}{email})
+	if len(errors) != 0 {
+		return validationProblem(http.StatusBadRequest, errors...)
+	}
+
+	if principal.User.Email != nil && *principal.User.Email == email {
+		return newProblem(http.StatusBadRequest, i18n.EmailAlreadyVerified)
+	}
+
+	existingUser, err := s.Repository.GetUserByEmail(ctx.Request().Context(), email)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	} else if existingUser.Id != 0 {
+		return newProblem(http.StatusBadRequest, i18n.EmailRegistered)
+	}
+
+	pending, err := s.Repository.GetPendingEmailVerification(ctx.Request().Context(), principal.User.Id)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	} else if err == nil && time.Until(pending.ExpiresAt) > emailVerificationTTL-emailVerificationResendDelay {
+		return newProblem(http.StatusTooManyRequests, i18n.LinkTooSoon)
+	}
+
+	token, err := generateEmailVerificationToken()
+	if err != nil {
+		return err
+	}
+
+	verification, err := s.Repository.CreateEmailVerification(ctx.Request().Context(), repository.CreateEmailVerificationInput{
+		UserId:    principal.User.Id,
+		Email:     email,
+		TokenHash: hashEmailVerificationToken(token),
+		ExpiresAt: time.Now().Add(emailVerificationTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	err = s.Mailer.SendMail(ctx.Request().Context(), notification.Mail{
+		To:      email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf("Hi %s,\n\nOpen the link below to use this email to sign in. The link expires in %d hours.\n\n%s\n\nIf you did not ask for this, ignore this email.",
+			principal.User.Name, int(emailVerificationTTL.Hours()), s.emailVerificationLink(token)),
+	})
+	if err != nil {
+		// A link that never left cannot be followed, drop it so the user can ask again right away
+		if cancelErr := s.Repository.CancelEmailVerification(ctx.Request().Context(), principal.User.Id); cancelErr != nil {
+			ctx.Logger().Errorf("failed to cancel email verification: %v", cancelErr)
+		}
+		return err
+	}
+
+	return ctx.JSON(http.StatusAccepted, generated.EmailVerificationResponse{
+		Email:     verification.Email,
+		ExpiresAt: verification.ExpiresAt,
+	})
+}
+
+// (POST /user/email/verify) Verify email endpoint, the token of the emailed link proves the user holds the email,
+// so no other credential is needed
+func (s *Server) VerifyEmail(ctx echo.Context) error {
+	var request generated.VerifyEmailJSONRequestBody
+	if err := ctx.Bind(&request); err != nil {
+		return err
+	}
+
+	verification, err := s.Repository.GetEmailVerificationByTokenHash(ctx.Request().Context(), hashEmailVerificationToken(request.Token))
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	} else if err == sql.ErrNoRows || time.Now().After(verification.ExpiresAt) {
+		return newProblem(http.StatusBadRequest, i18n.LinkInvalid)
+	}
+
+	// Authenticated by the token instead of a credential, the user verifies their own email
+	if metadata := repository.AuditMetadataFromContext(ctx.Request().Context()); metadata != nil {
+		metadata.ActorId = verification.UserId
+	}
+
+	_, err = s.Repository.CompleteEmailVerification(ctx.Request().Context(), verification.Id)
+	if err == sql.ErrNoRows {
+		return newProblem(http.StatusBadRequest, i18n.LinkInvalid)
+	} else if err == repository.ErrConflict {
+		return newProblem(http.StatusBadRequest, i18n.EmailRegistered)
+	} else if err != nil {
+		return err
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// (DELETE /user/email) Remove email endpoint, the user can only sign in with their phone number afterwards
+func (s *Server) RemoveEmail(ctx echo.Context, params generated.RemoveEmailParams) error {
+	principal, err := s.AuthorizeOwner(ctx.Request().Context(), params.Authorization, ScopeProfileWrite)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.Repository.RemoveUserEmail(ctx.Request().Context(), principal.User.Id)
+	if err == sql.ErrNoRows {
+		return newProblem(http.StatusNotFound, i18n.UserHasNoEmail)
+	} else if err != nil {
+		return err
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// Generate a random email verification token, 32 bytes of entropy encoded url safe
+func generateEmailVerificationToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+// Hash an email verification token for storage and lookup
+func hashEmailVerificationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Link of the verification page carrying token
+func (s *Server) emailVerificationLink(token string) string {
+	separator := "?"
+	if strings.Contains(s.EmailVerificationURL, "?") {
+		separator = "&"
+	}
+	return s.EmailVerificationURL + separator + "token=" + url.QueryEscape(token)
+}
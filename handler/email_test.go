@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AthanatiusC/SawitPro/generated"
+	"github.com/AthanatiusC/SawitPro/notification"
+	"github.com/AthanatiusC/SawitPro/repository"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// Keeps every email instead of sending it
+type recordingMailer struct {
+	mails []notification.Mail
+}
+
+func (m *recordingMailer) SendMail(ctx context.Context, mail notification.Mail) error {
+	m.mails = append(m.mails, mail)
+	return nil
+}
+
+/*
+TestUpdateEmail Criteria:
+- Email is lower cased and a verification link is mailed to it
+- Following the link verifies the email
+- Invalid, padded and already registered emails are refused
+*/
+func TestUpdateEmail(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	e := echo.New()
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	mailer := &recordingMailer{}
+	h := NewServer(NewServerOptions{Repository: repo, Mailer: mailer, EmailVerificationURL: "https://app.example.com/verify"})
+
+	user := repository.User{Id: 1, Name: "user", Status: repository.UserStatusActive}
+	repo.EXPECT().GetUserById(gomock.Any(), user.Id).Return(user, nil).Times(4)
+	repo.EXPECT().GetUserByEmail(gomock.Any(), "budi@example.com").Return(repository.User{}, sql.ErrNoRows)
+	repo.EXPECT().GetUserByEmail(gomock.Any(), "taken@example.com").Return(repository.User{Id: 2}, nil)
+	repo.EXPECT().GetPendingEmailVerification(gomock.Any(), user.Id).Return(repository.EmailVerification{}, sql.ErrNoRows)
+
+	var tokenHash string
+	repo.EXPECT().CreateEmailVerification(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, input repository.CreateEmailVerificationInput) (repository.EmailVerification, error) {
+			assert.Equal(t, "budi@example.com", input.Email)
+			tokenHash = input.TokenHash
+			return repository.EmailVerification{Id: 4, UserId: user.Id, Email: input.Email, TokenHash: input.TokenHash, ExpiresAt: input.ExpiresAt}, nil
+		})
+
+	token, err := h.GenerateJWT(JWTClaims{UserId: user.Id})
+	if err != nil {
+		t.Error(err)
+	}
+	token = fmt.Sprintf("Bearer %s", token)
+
+	update := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/user/email", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		assert.NoError(t, serve(h, e.NewContext(req, rec), func(ctx echo.Context) error {
+			return h.UpdateEmail(ctx, generated.UpdateEmailParams{Authorization: &token})
+		}))
+		return rec
+	}
+
+	rec := update(`{"email":"Budi@Example.com"}`)
+	if !assert.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String()) || !assert.Len(t, mailer.mails, 1) {
+		return
+	}
+	assert.Equal(t, "budi@example.com", mailer.mails[0].To)
+
+	// The token only travels in the link
+	start := strings.Index(mailer.mails[0].Body, "https://app.example.com/verify?token=")
+	if !assert.NotEqual(t, -1, start) {
+		return
+	}
+	link, err := url.Parse(strings.Fields(mailer.mails[0].Body[start:])[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	linkToken := link.Query().Get("token")
+	assert.Equal(t, tokenHash, hashEmailVerificationToken(linkToken))
+
+	repo.EXPECT().GetEmailVerificationByTokenHash(gomock.Any(), tokenHash).
+		Return(repository.EmailVerification{Id: 4, UserId: user.Id, Email: "budi@example.com", ExpiresAt: time.Now().Add(time.Hour)}, nil)
+	repo.EXPECT().GetEmailVerificationByTokenHash(gomock.Any(), hashEmailVerificationToken("unknown")).
+		Return(repository.EmailVerification{}, sql.ErrNoRows)
+	repo.EXPECT().CompleteEmailVerification(gomock.Any(), 4).Return(repository.User{Id: user.Id}, nil)
+
+	verify := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/user/email/verify", strings.NewReader(fmt.Sprintf(`{"token":"%s"}`, token)))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		assert.NoError(t, serve(h, e.NewContext(req, rec), h.VerifyEmail))
+		return rec
+	}
+
+	rec = verify(linkToken)
+	assert.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+
+	rec = verify("unknown")
+	assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+
+	rec = update(`{"email":"Budi <budi@example.com>"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+
+	rec = update(`{"email":" budi@example.com "}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+
+	rec = update(`{"email":"taken@example.com"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+}
+
+/*
+TestLoginWithEmail Criteria:
+- Verified email signs in like a phone number, matched case insensitively
+- Unknown email is refused without telling which credential is wrong
+- Email and phone number cannot be sent together
+*/
+func TestLoginWithEmail(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	e := echo.New()
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	h := NewServer(NewServerOptions{Repository: repo})
+
+	email := "budi@example.com"
+	user := repository.User{Id: 1, Email: &email, Password: "$2a$06$bt380.sYY0HEAa1tz2eyfOOQDHarjgiABmv.ZJTXzKdXMU.hQFAyi", Status: repository.UserStatusActive}
+	repo.EXPECT().GetUserByEmail(gomock.Any(), email).Return(user, nil)
+	repo.EXPECT().GetUserByEmail(gomock.Any(), "unknown@example.com").Return(repository.User{}, sql.ErrNoRows)
+	repo.EXPECT().GetLoginDeviceStatus(gomock.Any(), user.Id, gomock.Any()).Return(repository.LoginDeviceStatus{}, nil)
+	repo.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Return(repository.Session{Id: 1}, nil)
+	gomock.InOrder(
+		repo.EXPECT().CreateLoginAttempt(gomock.Any(), repository.CreateLoginAttemptInput{UserId: &user.Id, Success: true, Ip: "192.0.2.1", DeviceFingerprint: deviceFingerprint("", "unknown device")}).Return(nil),
+		repo.EXPECT().CreateLoginAttempt(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, attempt repository.CreateLoginAttemptInput) error {
+				assert.Equal(t, repository.LoginReasonUnknownEmail, attempt.Reason)
+				return nil
+			}),
+		repo.EXPECT().CreateLoginAttempt(gomock.Any(), gomock.Any()).Return(nil),
+	)
+
+	login := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.RemoteAddr = "192.0.2.1:1234"
+		rec := httptest.NewRecorder()
+		assert.NoError(t, serve(h, e.NewContext(req, rec), h.Login))
+		return rec
+	}
+
+	rec := login(`{"email":"Budi@Example.com","password":"Userpassw0rd!"}`)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = login(`{"email":"unknown@example.com","password":"Userpassw0rd!"}`)
+	if assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String()) {
+		assert.Contains(t, rec.Body.String(), "incorrect password or email")
+	}
+
+	rec = login(`{"email":"budi@example.com","phone_number":"+6280000000000","password":"Userpassw0rd!"}`)
+	if assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String()) {
+		assert.Contains(t, rec.Body.String(), "phone_number : cannot be sent with email")
+	}
+}
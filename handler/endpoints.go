@@ -3,80 +3,85 @@ package handler
 import (
 	"database/sql"
 	"net/http"
-	"strconv"
+	"time"
 
 	"github.com/AthanatiusC/SawitPro/generated"
+	"github.com/AthanatiusC/SawitPro/i18n"
 	"github.com/AthanatiusC/SawitPro/repository"
+	"github.com/AthanatiusC/SawitPro/validation"
 	"github.com/labstack/echo/v4"
 	"golang.org/x/crypto/bcrypt"
 )
 
 // (GET /user) Get user endpoint, returns user detail by user-id jwt claims
 func (s *Server) GetUser(ctx echo.Context, params generated.GetUserParams) error {
-	if params.Authorization == nil {
-		return ctx.String(http.StatusForbidden, "unauthorized")
-	}
-
-	token := s.ValidateJWT(*params.Authorization)
-	if !token.Valid {
-		return ctx.String(http.StatusForbidden, "unauthorized")
-	}
-
-	idClaims, err := s.GetJWTClaims(token, "id")
+	principal, err := s.Authorize(ctx.Request().Context(), params.Authorization, ScopeProfileRead)
 	if err != nil {
-		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{Message: "something went wrong"})
+		return err
 	}
+	user := principal.User
 
-	id, err := strconv.Atoi(idClaims)
-	if err != nil {
-		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{Message: "something went wrong"})
-	}
+	response := s.userProfile(user)
 
-	user, err := s.Repository.GetUserById(ctx.Request().Context(), id)
-	if err != nil {
-		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{Message: "something went wrong"})
+	change, err := s.Repository.GetPendingPhoneChange(ctx.Request().Context(), user.Id)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	} else if err == nil && time.Now().Before(change.ExpiresAt) {
+		response.PendingPhoneNumber = &change.Phone
 	}
 
-	return ctx.JSON(http.StatusOK, generated.User{
-		FullName:    user.Name,
-		PhoneNumber: user.Phone,
-	})
+	ctx.Response().Header().Set("ETag", userETag(user.Version))
+	return ctx.JSON(http.StatusOK, response)
 }
 
 // (POST /user) Register user endpoint, register new user with valid phone and password
 func (s *Server) Register(ctx echo.Context) error {
 	var request generated.RegisterJSONRequestBody
 	if err := ctx.Bind(&request); err != nil {
-		return ctx.JSON(http.StatusBadRequest, err)
+		return err
 	}
 
-	errors := s.ValidateUser(request)
-	if len(errors.Messages) != 0 {
-		return ctx.JSON(http.StatusBadRequest, errors)
+	errors := s.Users.Validate(request)
+	var values generated.Attributes
+	if request.Attributes != nil {
+		values = *request.Attributes
+	}
+	attributes, attributeErrors, err := s.checkAttributes(ctx.Request().Context(), values)
+	if err != nil {
+		return err
+	}
+	errors = append(errors, attributeErrors...)
+	if len(errors) != 0 {
+		return validationProblem(http.StatusBadRequest, errors...)
 	}
 
 	// cost 6 = 64 Rounds(2^6=64) process time<~250ms
 	password, err := bcrypt.GenerateFromPassword([]byte(request.Password), 6)
 	if err != nil {
-		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{Message: "something went wrong"})
+		return err
 	}
 
 	// avoid id increment because of duplicate violation
-	phoneNumber := CleanPhoneNumber(request.PhoneNumber)
+	phoneNumber := s.NormalizePhoneNumber(request.PhoneNumber)
 	user, err := s.Repository.GetUserByPhoneNumber(ctx.Request().Context(), phoneNumber)
 	if err != nil && err != sql.ErrNoRows {
-		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{Message: "something went wrong"})
+		return err
 	} else if user.Id != 0 {
-		return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{Message: "phone number is already registered"})
+		return newProblem(http.StatusBadRequest, i18n.PhoneNumberRegistered)
 	}
 
 	result, err := s.Repository.CreateUser(ctx.Request().Context(), repository.CreateUserInput{
-		Name:     request.FullName,
-		Phone:    phoneNumber,
-		Password: string(password),
+		Name:       request.FullName,
+		Phone:      phoneNumber,
+		Password:   string(password),
+		Attributes: attributes,
 	})
-	if err != nil {
-		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{Message: "something went wrong"})
+	if err == repository.ErrConflict { // held by an account pending deletion
+		return newProblem(http.StatusBadRequest, i18n.PhoneNumberRegistered)
+	} else if takenErr := attributeTaken(err); takenErr != nil {
+		return validationProblem(http.StatusBadRequest, takenErr)
+	} else if err != nil {
+		return err
 	}
 
 	return ctx.JSON(http.StatusOK, generated.RegisterResponse{
@@ -86,46 +91,53 @@ func (s *Server) Register(ctx echo.Context) error {
 
 // (PUT /user) Update user endpoint, edit user data request with valid authentication and request body
 func (s *Server) UpdateUser(ctx echo.Context, params generated.UpdateUserParams) error {
-	if params.Authorization == nil {
-		return ctx.String(http.StatusForbidden, "unauthorized")
+	principal, err := s.Authorize(ctx.Request().Context(), params.Authorization, ScopeProfileWrite)
+	if err != nil {
+		return err
 	}
+	user := principal.User
 
-	token := s.ValidateJWT(*params.Authorization)
-	if !token.Valid {
-		return ctx.String(http.StatusForbidden, "unauthorized")
+	// Refused before anything else, the client must read the profile again anyway
+	if params.IfMatch != nil && !ifMatch(*params.IfMatch, userETag(user.Version)) {
+		return newProblem(http.StatusPreconditionFailed, i18n.UserModified)
 	}
 
-	idClaims, err := s.GetJWTClaims(token, "id")
-	if err != nil {
-		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{Message: "something went wrong"})
+	var request generated.UpdateUserJSONRequestBody
+	if err := ctx.Bind(&request); err != nil {
+		return err
 	}
 
-	id, err := strconv.Atoi(idClaims)
-	if err != nil {
-		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{Message: "something went wrong"})
+	if request.FullName == "" && request.PhoneNumber == "" && request.Locale == nil && request.Timezone == nil {
+		return newProblem(http.StatusBadRequest, i18n.EmptyRequest)
 	}
 
-	user, err := s.Repository.GetUserById(ctx.Request().Context(), id)
-	if err != nil {
-		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{Message: "something went wrong"})
+	// Fields left empty are kept, the read only fields of the profile are ignored
+	errors := s.Users.Only("full_name", "phone_number", "timezone").Optional().Validate(request)
+	if request.Locale != nil {
+		preference, err := localePreference(*request.Locale)
+		if err != nil {
+			errors = append(errors, err)
+		}
+		user.Locale = &preference
 	}
-
-	var request generated.UpdateUserJSONRequestBody
-	if err := ctx.Bind(&request); err != nil {
-		return ctx.JSON(http.StatusBadRequest, err)
+	if len(errors) != 0 {
+		return validationProblem(http.StatusBadRequest, errors...)
 	}
 
-	if request.FullName == "" && request.PhoneNumber == "" {
-		return ctx.JSON(http.StatusBadRequest, "request cannot be empty")
+	// An empty time zone removes it
+	if request.Timezone != nil && *request.Timezone == "" {
+		user.Timezone = nil
+	} else if request.Timezone != nil {
+		user.Timezone = request.Timezone
 	}
 
 	if request.PhoneNumber != "" {
-		phoneNumber := CleanPhoneNumber(request.PhoneNumber)
+		phoneNumber := s.NormalizePhoneNumber(request.PhoneNumber)
 		existingUser, err := s.Repository.GetUserByPhoneNumber(ctx.Request().Context(), phoneNumber)
 		if err != nil && err != sql.ErrNoRows {
-			return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{Message: "something went wrong"})
+			return err
 		} else if existingUser.Id != 0 && phoneNumber != user.Phone {
-			return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{Message: "phone number is already registered"})
+			return newProblem(http.StatusBadRequest, i18n.PhoneNumberRegistered)
 		}
 		user.Phone = phoneNumber
 	}
@@ -134,47 +146,186 @@ func (s *Server) UpdateUser(ctx echo.Context, params generated.UpdateUserParams)
 		user.Name = request.FullName
 	}
 
-	result, err := s.Repository.UpdateUserById(ctx.Request().Context(), repository.UpdateUserInput{
-		Id:    user.Id,
-		Name:  user.Name,
-		Phone: user.Phone,
-	})
-	if err != nil {
-		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{Message: err.Error()})
+	return s.saveUser(ctx, principal.User, user, params.IfMatch)
+}
+
+/*
+Write the changes of user over current and respond with the profile, shared by PUT and PATCH /user.
+The name, locale, time zone and avatar are written only if nobody updated the user since it was read, whether or not If-Match was sent.
+Attributes are kept as they are, only admins change them.
+A new phone number is never written here, it starts a change verified with a code sent to the number
+*/
+func (s *Server) saveUser(ctx echo.Context, current repository.User, user repository.User, ifMatch *string) error {
+	result := current
+	if user.Name != current.Name || !sameString(user.Locale, current.Locale) || !sameString(user.Timezone, current.Timezone) ||
+		!sameString(user.Avatar, current.Avatar) {
+		var err error
+		result, err = s.Repository.UpdateUserById(ctx.Request().Context(), repository.UpdateUserInput{
+			Id:         current.Id,
+			Name:       user.Name,
+			Phone:      current.Phone,
+			Locale:     user.Locale,
+			Timezone:   user.Timezone,
+			Avatar:     user.Avatar,
+			Attributes: current.Attributes,
+			Version:    current.Version,
+		})
+		if err == repository.ErrVersionMismatch && ifMatch != nil {
+			return newProblem(http.StatusPreconditionFailed, i18n.UserModified)
+		} else if err == repository.ErrVersionMismatch {
+			return newProblem(http.StatusConflict, i18n.UserModifiedRetry)
+		} else if err != nil {
+			return err
+		}
 	}
 
-	return ctx.JSON(http.StatusOK, generated.User{
-		FullName:    result.Name,
-		PhoneNumber: result.Phone,
-	})
+	response := s.userProfile(result)
+
+	if user.Phone != current.Phone {
+		change, err := s.startPhoneChange(ctx, current, user.Phone)
+		if err == errPhoneChangeTooSoon {
+			return newProblem(http.StatusTooManyRequests, i18n.CodeTooSoon)
+		} else if err != nil {
+			return err
+		}
+		response.PendingPhoneNumber = &change.Phone
+	}
+
+	ctx.Response().Header().Set("ETag", userETag(result.Version))
+	return ctx.JSON(http.StatusOK, response)
+}
+
+// Profile of user as api.yml returns it, without the pending phone number
+func (s *Server) userProfile(user repository.User) generated.User {
+	profile := generated.User{
+		FullName:    user.Name,
+		PhoneNumber: user.Phone,
+		Email:       user.Email,
+		Locale:      user.Locale,
+		Timezone:    user.Timezone,
+		Attributes:  attributesResponse(user.Attributes),
+	}
+	if user.Avatar != nil {
+		profile.Avatar = s.avatarResponse(*user.Avatar)
+	}
+	return profile
 }
 
 // (POST /login) User authentication endpoint, returns valid JWT token to user
 func (s *Server) Login(ctx echo.Context) error {
 	var request generated.LoginJSONRequestBody
 	if err := ctx.Bind(&request); err != nil {
-		return ctx.JSON(http.StatusBadRequest, err)
+		return err
 	}
 
-	errors := s.ValidateUser(request)
-	if len(errors.Messages) != 0 {
-		return ctx.JSON(http.StatusBadRequest, errors)
+	deviceName := truncate(ctx.Request().UserAgent(), 60)
+	if request.DeviceName != nil && *request.DeviceName != "" {
+		deviceName = truncate(*request.DeviceName, 60)
+	} else if deviceName == "" {
+		deviceName = "unknown device"
 	}
 
-	phoneNumber := CleanPhoneNumber(request.PhoneNumber)
-	user, err := s.Repository.GetUserByPhoneNumber(ctx.Request().Context(), phoneNumber)
-	if err != nil && err != sql.ErrNoRows {
-		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{Message: "something went wrong"})
+	// Every attempt is recorded whatever the outcome, see recordLoginAttempt
+	attempt := repository.CreateLoginAttemptInput{
+		Ip:                clientIP(ctx),
+		UserAgent:         truncate(ctx.Request().UserAgent(), 255),
+		DeviceFingerprint: deviceFingerprint(ctx.Request().UserAgent(), deviceName),
+	}
+
+	// Signing in with an email or a phone number, an email can only be used once verified
+	var user repository.User
+	var errors validation.Errors
+	var err error
+	incorrectCredentials, unknownReason := i18n.IncorrectPhoneCredentials, repository.LoginReasonUnknownPhone
+	if request.Email != nil {
+		incorrectCredentials, unknownReason = i18n.IncorrectEmailCredentials, repository.LoginReasonUnknownEmail
+		email := NormalizeEmail(string(*request.Email))
+		errors = s.Users.Validate(struct {
+			Email    string `json:"email"`
+			Password string `json:"password"`
+		}{email, request.Password})
+		if request.PhoneNumber != nil {
+			errors.Add("phone_number", i18n.CannotBeSentWith, "email")
+		}
+		if len(errors) == 0 {
+			user, err = s.Repository.GetUserByEmail(ctx.Request().Context(), email)
+		}
+	} else {
+		var phoneNumber string
+		if request.PhoneNumber != nil {
+			phoneNumber = *request.PhoneNumber
+		}
+		attempt.Phone = truncate(s.NormalizePhoneNumber(phoneNumber), 16)
+		errors = s.Users.Validate(struct {
+			PhoneNumber string `json:"phone_number"`
+			Password    string `json:"password"`
+		}{phoneNumber, request.Password})
+		if len(errors) == 0 {
+			user, err = s.Repository.GetUserByPhoneNumber(ctx.Request().Context(), s.NormalizePhoneNumber(phoneNumber))
+		}
+	}
+
+	if len(errors) != 0 {
+		attempt.Reason = repository.LoginReasonInvalidRequest
+		s.recordLoginAttempt(ctx, attempt)
+		return validationProblem(http.StatusBadRequest, errors...)
+	} else if err == sql.ErrNoRows {
+		attempt.Reason = unknownReason
+		s.recordLoginAttempt(ctx, attempt)
+		return newProblem(http.StatusBadRequest, incorrectCredentials)
+	} else if err != nil {
+		return err
 	}
+	attempt.UserId = &user.Id
 
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(request.Password))
 	if err != nil {
-		return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{Message: "incorrect password or phone number"})
+		attempt.Reason = repository.LoginReasonInvalidPassword
+		s.recordLoginAttempt(ctx, attempt)
+		return newProblem(http.StatusBadRequest, incorrectCredentials)
+	}
+
+	// Only told once the password is proven, so the status of an account does not leak
+	if user.Status != repository.UserStatusActive {
+		attempt.Reason = repository.LoginReasonInactiveUser
+		s.recordLoginAttempt(ctx, attempt)
+		return newProblem(http.StatusForbidden, accountStatusCode(user.Status))
 	}
 
-	token, err := s.GenerateJWT(user.Id)
+	// Checked before recording this attempt, otherwise the device is always known
+	deviceStatus, err := s.Repository.GetLoginDeviceStatus(ctx.Request().Context(), user.Id, attempt.DeviceFingerprint)
 	if err != nil {
-		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{Message: "something went wrong"})
+		return err
+	}
+
+	expiresAt := time.Now().Add(time.Hour * 24)
+	session, err := s.Repository.CreateSession(ctx.Request().Context(), repository.CreateSessionInput{
+		UserId:     user.Id,
+		DeviceName: deviceName,
+		UserAgent:  truncate(ctx.Request().UserAgent(), 255),
+		Ip:         clientIP(ctx),
+		ExpiresAt:  expiresAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	token, err := s.GenerateJWT(JWTClaims{
+		UserId:    user.Id,
+		Version:   user.TokenVersion,
+		SessionId: session.Id,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	attempt.Success = true
+	s.recordLoginAttempt(ctx, attempt)
+
+	// The very first login of an account is not an alert
+	if deviceStatus.HasLoggedIn && !deviceStatus.DeviceSeen {
+		s.notifyNewDevice(ctx, user, deviceName)
 	}
 
 	return ctx.JSON(http.StatusOK, generated.LoginResponse{
@@ -182,3 +333,11 @@ func (s *Server) Login(ctx echo.Context) error {
 		Token: token,
 	})
 }
+
+// Code of the message refusing to sign in a user who is not active
+func accountStatusCode(status string) i18n.Code {
+	if status == repository.UserStatusSuspended {
+		return i18n.AccountSuspended
+	}
+	return i18n.AccountDisabled
+}
@@ -1,13 +1,14 @@
 package handler
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"math/rand"
 	"net/http"
 	"net/http/httptest"
 	"os"
-	"regexp"
 	"strings"
 	"testing"
 	"time"
@@ -65,19 +66,20 @@ func TestGetUser(t *testing.T) {
 	h := NewServer(opts)
 
 	var userId int = 1 // default user
-	repo.EXPECT().GetUserById(gomock.Any(), userId).Return(repository.User{Id: 0, Name: "user"}, nil)
+	repo.EXPECT().GetUserById(gomock.Any(), userId).Return(repository.User{Id: 0, Name: "user", Status: repository.UserStatusActive}, nil)
+	repo.EXPECT().GetPendingPhoneChange(gomock.Any(), 0).Return(repository.PhoneChange{}, sql.ErrNoRows)
 
 	req := httptest.NewRequest(http.MethodGet, "/user", nil)
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 
-	token, err := h.GenerateJWT(userId)
+	token, err := h.GenerateJWT(JWTClaims{UserId: userId})
 	if err != nil {
 		t.Error(err)
 	}
 	token = fmt.Sprintf("Bearer %s", token)
 
 	rec := httptest.NewRecorder()
-	if assert.NoError(t, h.GetUser(e.NewContext(req, rec), generated.GetUserParams{Authorization: &token})) {
+	if assert.NoError(t, serve(h, e.NewContext(req, rec), func(ctx echo.Context) error { return h.GetUser(ctx, generated.GetUserParams{Authorization: &token}) })) {
 		assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
 	}
 
@@ -109,7 +111,7 @@ func TestRegister(t *testing.T) {
 	}
 
 	request.PhoneNumber = fmt.Sprintf(request.PhoneNumber, rand.Intn(9), rand.Intn(9))
-	validRPN := regexp.MustCompile(`[^a-zA-Z0-9 ]+`).ReplaceAllString(request.PhoneNumber, "")
+	validRPN := h.NormalizePhoneNumber(request.PhoneNumber)
 	user := repository.User{
 		Name:      request.FullName,
 		Phone:     validRPN,
@@ -118,6 +120,7 @@ func TestRegister(t *testing.T) {
 		CreatedAt: time.Now(),
 	}
 
+	repo.EXPECT().ListAttributeDefinitions(gomock.Any()).Return(nil, nil)
 	repo.EXPECT().GetUserByPhoneNumber(gomock.Any(), user.Phone).Return(repository.User{}, nil)
 	repo.EXPECT().CreateUser(gomock.Any(), ValidateCreateUser(repository.CreateUserInput{ //  Custom validator for Bcrypt
 		Name:     user.Name,
@@ -134,16 +137,16 @@ func TestRegister(t *testing.T) {
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 
 	rec := httptest.NewRecorder()
-	if assert.NoError(t, h.Register(e.NewContext(req, rec))) {
+	if assert.NoError(t, serve(h, e.NewContext(req, rec), h.Register)) {
 		assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String(), string(jsonRequest))
 	}
 }
 
 /*
-TestRegister Criteria:
+TestUpdateUser Criteria:
 - Valid User Request
 - Assert no double phone number
-- Repository returns updated value
+- Name is updated right away, the phone number only once verified
 - Assert no error on call
 */
 func TestUpdateUser(t *testing.T) {
@@ -152,8 +155,10 @@ func TestUpdateUser(t *testing.T) {
 
 	e := echo.New()
 	repo := repository.NewMockRepositoryInterface(ctrl)
+	otp := &otpRecorder{}
 	opts := NewServerOptions{
 		Repository: repo,
+		OTPSender:  otp,
 	}
 	h := NewServer(opts)
 
@@ -162,21 +167,28 @@ func TestUpdateUser(t *testing.T) {
 		PhoneNumber: fmt.Sprintf("+628%d%d00000000", rand.Intn(9), rand.Intn(9)),
 	}
 
-	response := repository.User{
-		Id:    1,
-		Name:  request.FullName,
-		Phone: request.PhoneNumber,
+	user := repository.User{
+		Id:     1,
+		Name:   "old name",
+		Phone:  "+6280000000000",
+		Status: repository.UserStatusActive,
 	}
 
-	repo.EXPECT().GetUserByPhoneNumber(gomock.Any(), CleanPhoneNumber(request.PhoneNumber)).Return(repository.User{}, nil)
-	repo.EXPECT().GetUserById(gomock.Any(), response.Id).Return(response, nil)
+	repo.EXPECT().GetUserByPhoneNumber(gomock.Any(), h.NormalizePhoneNumber(request.PhoneNumber)).Return(repository.User{}, nil)
+	repo.EXPECT().GetUserById(gomock.Any(), user.Id).Return(user, nil)
 	repo.EXPECT().UpdateUserById(gomock.Any(), repository.UpdateUserInput{
 		Id:    1,
 		Name:  request.FullName,
-		Phone: CleanPhoneNumber(request.PhoneNumber),
-	}).Return(response, nil)
-
-	token, err := h.GenerateJWT(response.Id)
+		Phone: user.Phone,
+	}).Return(repository.User{Id: 1, Name: request.FullName, Phone: user.Phone, Version: 1}, nil)
+	repo.EXPECT().GetLatestPhoneChange(gomock.Any(), user.Id).Return(repository.PhoneChange{}, sql.ErrNoRows)
+	repo.EXPECT().CreatePhoneChange(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, input repository.CreatePhoneChangeInput) (repository.PhoneChange, error) {
+			assert.Equal(t, h.NormalizePhoneNumber(request.PhoneNumber), input.Phone)
+			return repository.PhoneChange{Id: 1, UserId: user.Id, Phone: input.Phone, CodeHash: input.CodeHash, ExpiresAt: input.ExpiresAt}, nil
+		})
+
+	token, err := h.GenerateJWT(JWTClaims{UserId: user.Id})
 	if err != nil {
 		t.Error(err)
 	}
@@ -191,16 +203,71 @@ func TestUpdateUser(t *testing.T) {
 
 	token = fmt.Sprintf("Bearer %s", token)
 	rec := httptest.NewRecorder()
-	if assert.NoError(t, h.UpdateUser(e.NewContext(req, rec), generated.UpdateUserParams{Authorization: &token})) {
+	if assert.NoError(t, serve(h, e.NewContext(req, rec), func(ctx echo.Context) error {
+		return h.UpdateUser(ctx, generated.UpdateUserParams{Authorization: &token})
+	})) {
 		assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Contains(t, rec.Body.String(), `"phone_number":"+6280000000000"`)
+		assert.Contains(t, rec.Body.String(), fmt.Sprintf(`"pending_phone_number":"%s"`, h.NormalizePhoneNumber(request.PhoneNumber)))
+		assert.Equal(t, h.NormalizePhoneNumber(request.PhoneNumber), otp.phone)
+		assert.Len(t, otp.code, phoneChangeCodeDigits)
 	}
 }
 
+/*
+TestUpdateUserIfMatch Criteria:
+- Get user returns the version as ETag
+- Stale If-Match is refused before updating
+- Concurrent update between read and write is refused
+*/
+func TestUpdateUserIfMatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	e := echo.New()
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	h := NewServer(NewServerOptions{Repository: repo})
+
+	user := repository.User{Id: 1, Name: "user", Phone: "+6280000000000", Status: repository.UserStatusActive, Version: 3}
+	repo.EXPECT().GetUserById(gomock.Any(), user.Id).Return(user, nil).Times(3)
+	repo.EXPECT().GetPendingPhoneChange(gomock.Any(), user.Id).Return(repository.PhoneChange{}, sql.ErrNoRows)
+	repo.EXPECT().UpdateUserById(gomock.Any(), repository.UpdateUserInput{Id: user.Id, Name: "new name", Phone: user.Phone, Version: 3}).
+		Return(repository.User{}, repository.ErrVersionMismatch)
+
+	token, err := h.GenerateJWT(JWTClaims{UserId: user.Id})
+	if err != nil {
+		t.Error(err)
+	}
+	token = fmt.Sprintf("Bearer %s", token)
+
+	rec := httptest.NewRecorder()
+	if assert.NoError(t, serve(h, e.NewContext(httptest.NewRequest(http.MethodGet, "/user", nil), rec), func(ctx echo.Context) error { return h.GetUser(ctx, generated.GetUserParams{Authorization: &token}) })) {
+		assert.Equal(t, `"3"`, rec.Header().Get("ETag"))
+	}
+
+	update := func(etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/user", strings.NewReader(`{"full_name":"new name"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		assert.NoError(t, serve(h, e.NewContext(req, rec), func(ctx echo.Context) error {
+			return h.UpdateUser(ctx, generated.UpdateUserParams{Authorization: &token, IfMatch: &etag})
+		}))
+		return rec
+	}
+
+	rec = update(`"2"`)
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code, rec.Body.String())
+
+	rec = update(`"3"`)
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code, rec.Body.String())
+}
+
 /*
 TestRegister Criteria:
 - Valid User Request
 - Assert phone number and password combination match
 - Assert bcrypt password valid
+- Assert session created
 - Assert no error on call
 */
 func TestLogin(t *testing.T) {
@@ -214,12 +281,16 @@ func TestLogin(t *testing.T) {
 	}
 	h := NewServer(opts)
 
+	phoneNumber := "+6280000000000"
 	request := generated.LoginJSONRequestBody{
-		PhoneNumber: "+6280000000000",
+		PhoneNumber: &phoneNumber,
 		Password:    "Userpassw0rd!",
 	}
 
-	repo.EXPECT().GetUserByPhoneNumber(gomock.Any(), CleanPhoneNumber(request.PhoneNumber)).Return(repository.User{Password: "$2a$06$bt380.sYY0HEAa1tz2eyfOOQDHarjgiABmv.ZJTXzKdXMU.hQFAyi"}, nil)
+	repo.EXPECT().GetUserByPhoneNumber(gomock.Any(), h.NormalizePhoneNumber(phoneNumber)).Return(repository.User{Password: "$2a$06$bt380.sYY0HEAa1tz2eyfOOQDHarjgiABmv.ZJTXzKdXMU.hQFAyi", Status: repository.UserStatusActive}, nil)
+	repo.EXPECT().GetLoginDeviceStatus(gomock.Any(), gomock.Any(), gomock.Any()).Return(repository.LoginDeviceStatus{}, nil)
+	repo.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Return(repository.Session{Id: 1}, nil)
+	repo.EXPECT().CreateLoginAttempt(gomock.Any(), gomock.Any()).Return(nil)
 
 	jsonRequest, err := json.Marshal(request)
 	if err != nil {
@@ -230,7 +301,7 @@ func TestLogin(t *testing.T) {
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 
 	rec := httptest.NewRecorder()
-	if assert.NoError(t, h.Login(e.NewContext(req, rec))) {
+	if assert.NoError(t, serve(h, e.NewContext(req, rec), h.Login)) {
 		assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
 	}
 }
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/AthanatiusC/SawitPro/generated"
+	"github.com/AthanatiusC/SawitPro/i18n"
+	"github.com/AthanatiusC/SawitPro/repository"
+	"github.com/AthanatiusC/SawitPro/validation"
+	"github.com/labstack/echo/v4"
+)
+
+// Content type of the archive served for each export format
+var dataExportContentTypes = map[string]string{
+	repository.DataExportFormatJSON: echo.MIMEApplicationJSON,
+	repository.DataExportFormatZIP:  "application/zip",
+}
+
+// (POST /user/exports) Request data export endpoint, queues a job collecting everything held about the user.
+// Exports contain personal data, so they are limited to the user signed in with a password, see AuthorizeOwner
+func (s *Server) CreateDataExport(ctx echo.Context, params generated.CreateDataExportParams) error {
+	principal, err := s.AuthorizeOwner(ctx.Request().Context(), params.Authorization, ScopeProfileRead)
+	if err != nil {
+		return err
+	}
+
+	var request generated.CreateDataExportJSONRequestBody
+	if err := ctx.Bind(&request); err != nil {
+		return err
+	}
+
+	format := repository.DataExportFormatJSON
+	if request.Format != nil {
+		format = *request.Format
+	}
+	if _, ok := dataExportContentTypes[format]; !ok {
+		return validationProblem(http.StatusBadRequest, validation.NewError("format", i18n.OneOf, "json, zip"))
+	}
+
+	export, err := s.Repository.CreateDataExport(ctx.Request().Context(), repository.CreateDataExportInput{
+		UserId: principal.User.Id,
+		Format: format,
+	})
+	if err == repository.ErrConflict {
+		return newProblem(http.StatusConflict, i18n.DataExportInProgress)
+	} else if err != nil {
+		return err
+	}
+
+	return ctx.JSON(http.StatusAccepted, dataExportResponse(export))
+}
+
+// (GET /user/exports/{id}) Get data export endpoint, returns the status of an export of the user
+func (s *Server) GetDataExport(ctx echo.Context, id int, params generated.GetDataExportParams) error {
+	principal, err := s.AuthorizeOwner(ctx.Request().Context(), params.Authorization, ScopeProfileRead)
+	if err != nil {
+		return err
+	}
+
+	export, err := s.Repository.GetDataExportById(ctx.Request().Context(), principal.User.Id, id)
+	if err == sql.ErrNoRows {
+		return newProblem(http.StatusNotFound, i18n.DataExportNotFound)
+	} else if err != nil {
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, dataExportResponse(export))
+}
+
+// (GET /user/exports/{id}/download) Download data export endpoint, serves the archive of a completed export until it expires
+func (s *Server) DownloadDataExport(ctx echo.Context, id int, params generated.DownloadDataExportParams) error {
+	principal, err := s.AuthorizeOwner(ctx.Request().Context(), params.Authorization, ScopeProfileRead)
+	if err != nil {
+		return err
+	}
+
+	export, err := s.Repository.GetDataExportById(ctx.Request().Context(), principal.User.Id, id)
+	if err == sql.ErrNoRows {
+		return newProblem(http.StatusNotFound, i18n.DataExportNotFound)
+	} else if err != nil {
+		return err
+	}
+
+	archive, err := s.Repository.GetDataExportArchive(ctx.Request().Context(), principal.User.Id, id)
+	if err == sql.ErrNoRows {
+		return newProblem(http.StatusNotFound, i18n.DataExportNotAvailable)
+	} else if err != nil {
+		return err
+	}
+
+	filename := fmt.Sprintf("data-export-%d.%s", export.Id, export.Format)
+	ctx.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+	ctx.Response().Header().Set("Cache-Control", "no-store")
+	return ctx.Blob(http.StatusOK, dataExportContentTypes[export.Format], archive)
+}
+
+func dataExportResponse(export repository.DataExport) generated.DataExport {
+	response := generated.DataExport{
+		Id:          export.Id,
+		Format:      export.Format,
+		Status:      export.Status,
+		ExpiresAt:   export.ExpiresAt,
+		CompletedAt: export.CompletedAt,
+		CreatedAt:   export.CreatedAt,
+	}
+	if export.Error != "" {
+		response.Error = &export.Error
+	}
+	return response
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AthanatiusC/SawitPro/generated"
+	"github.com/AthanatiusC/SawitPro/repository"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+TestCreateDataExport Criteria:
+- Export is queued as json when no format is given
+- Unknown format is refused
+- A second export while one is in progress is a conflict
+*/
+func TestCreateDataExport(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	e := echo.New()
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	h := NewServer(NewServerOptions{Repository: repo})
+
+	user := repository.User{Id: 1, Status: repository.UserStatusActive}
+	repo.EXPECT().GetUserById(gomock.Any(), user.Id).Return(user, nil).Times(3)
+	gomock.InOrder(
+		repo.EXPECT().CreateDataExport(gomock.Any(), repository.CreateDataExportInput{UserId: user.Id, Format: repository.DataExportFormatJSON}).
+			Return(repository.DataExport{Id: 1, UserId: user.Id, Format: repository.DataExportFormatJSON, Status: repository.DataExportStatusPending}, nil),
+		repo.EXPECT().CreateDataExport(gomock.Any(), repository.CreateDataExportInput{UserId: user.Id, Format: repository.DataExportFormatZIP}).
+			Return(repository.DataExport{}, repository.ErrConflict),
+	)
+
+	token, err := h.GenerateJWT(JWTClaims{UserId: user.Id})
+	if err != nil {
+		t.Error(err)
+	}
+	token = fmt.Sprintf("Bearer %s", token)
+
+	create := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/user/exports", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		assert.NoError(t, serve(h, e.NewContext(req, rec), func(ctx echo.Context) error {
+			return h.CreateDataExport(ctx, generated.CreateDataExportParams{Authorization: &token})
+		}))
+		return rec
+	}
+
+	rec := create(`{}`)
+	assert.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"status":"pending"`)
+
+	rec = create(`{"format":"xml"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+
+	rec = create(`{"format":"zip"}`)
+	assert.Equal(t, http.StatusConflict, rec.Code, rec.Body.String())
+}
+
+/*
+TestDownloadDataExport Criteria:
+- Completed export is served as an attachment
+- Expired export is not available
+*/
+func TestDownloadDataExport(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	e := echo.New()
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	h := NewServer(NewServerOptions{Repository: repo})
+
+	user := repository.User{Id: 1, Status: repository.UserStatusActive}
+	expiresAt := time.Now().Add(time.Hour)
+	repo.EXPECT().GetUserById(gomock.Any(), user.Id).Return(user, nil).Times(2)
+	repo.EXPECT().GetDataExportById(gomock.Any(), user.Id, 1).
+		Return(repository.DataExport{Id: 1, Format: repository.DataExportFormatZIP, Status: repository.DataExportStatusCompleted, ExpiresAt: &expiresAt}, nil)
+	repo.EXPECT().GetDataExportArchive(gomock.Any(), user.Id, 1).Return([]byte("archive"), nil)
+	repo.EXPECT().GetDataExportById(gomock.Any(), user.Id, 2).
+		Return(repository.DataExport{Id: 2, Format: repository.DataExportFormatJSON, Status: repository.DataExportStatusExpired}, nil)
+	repo.EXPECT().GetDataExportArchive(gomock.Any(), user.Id, 2).Return(nil, sql.ErrNoRows)
+
+	token, err := h.GenerateJWT(JWTClaims{UserId: user.Id})
+	if err != nil {
+		t.Error(err)
+	}
+	token = fmt.Sprintf("Bearer %s", token)
+
+	download := func(id int) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/user/exports/%d/download", id), nil)
+		rec := httptest.NewRecorder()
+		assert.NoError(t, serve(h, e.NewContext(req, rec), func(ctx echo.Context) error {
+			return h.DownloadDataExport(ctx, id, generated.DownloadDataExportParams{Authorization: &token})
+		}))
+		return rec
+	}
+
+	rec := download(1)
+	if assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String()) {
+		assert.Equal(t, "application/zip", rec.Header().Get(echo.HeaderContentType))
+		assert.Equal(t, `attachment; filename="data-export-1.zip"`, rec.Header().Get(echo.HeaderContentDisposition))
+		assert.Equal(t, "archive", rec.Body.String())
+	}
+
+	rec = download(2)
+	assert.Equal(t, http.StatusNotFound, rec.Code, rec.Body.String())
+}
@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/AthanatiusC/SawitPro/generated"
+	"github.com/AthanatiusC/SawitPro/i18n"
+	"github.com/AthanatiusC/SawitPro/repository"
+	"github.com/AthanatiusC/SawitPro/validation"
+	"github.com/labstack/echo/v4"
+)
+
+// Lifetime of impersonation tokens, kept short since they are only meant for support sessions
+const impersonationTTL = 15 * time.Minute
+
+// Scopes granted to impersonation tokens, support sees what the user sees but cannot change it
+var impersonationScopes = []string{ScopeProfileRead}
+
+// (POST /admin/users/{id}/impersonate) Impersonate user endpoint, returns a short lived read only token for another user
+func (s *Server) ImpersonateUser(ctx echo.Context, id int, params generated.ImpersonateUserParams) error {
+	principal, err := s.AuthorizeAdmin(ctx.Request().Context(), params.Authorization)
+	if err != nil {
+		return err
+	}
+
+	var request generated.ImpersonateUserJSONRequestBody
+	if err := ctx.Bind(&request); err != nil {
+		return err
+	}
+
+	if request.Reason == "" {
+		return validationProblem(http.StatusBadRequest, validation.NewError("reason", i18n.Required, "reason"))
+	} else if len(request.Reason) > 255 {
+		return validationProblem(http.StatusBadRequest, validation.NewError("reason", i18n.MaxLength, 255))
+	}
+
+	if id == principal.User.Id {
+		return newProblem(http.StatusBadRequest, i18n.CannotImpersonateSelf)
+	}
+
+	target, err := s.Repository.GetUserById(ctx.Request().Context(), id)
+	if err == sql.ErrNoRows {
+		return newProblem(http.StatusNotFound, i18n.UserNotFound)
+	} else if err != nil {
+		return err
+	}
+
+	// Admin tokens are never issued through impersonation
+	if target.Role == repository.RoleAdmin {
+		return newProblem(http.StatusForbidden, i18n.AdminNotImpersonable)
+	}
+
+	expiresAt := time.Now().Add(impersonationTTL)
+	token, err := s.GenerateJWT(JWTClaims{
+		UserId:    target.Id,
+		Version:   target.TokenVersion,
+		Scope:     impersonationScopes,
+		ExpiresAt: expiresAt,
+		ActorId:   principal.User.Id,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Record before handing out the token, an impersonation that cannot be audited must not start
+	err = s.Repository.CreateImpersonationEvent(ctx.Request().Context(), repository.CreateImpersonationEventInput{
+		ActorId:   principal.User.Id,
+		TargetId:  target.Id,
+		Action:    repository.ImpersonationActionStart,
+		Reason:    request.Reason,
+		Ip:        clientIP(ctx),
+		UserAgent: truncate(ctx.Request().UserAgent(), 255),
+	})
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, generated.ImpersonationResponse{
+		UserId:    target.Id,
+		Token:     token,
+		ExpiresAt: expiresAt,
+	})
+}
+
+/*
+Middleware recording every request made with an impersonation token, register with echo Use.
+The token is only decoded here, handlers still authenticate it, so refused requests are recorded too.
+*/
+func (s *Server) AuditImpersonation(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		err := next(ctx)
+
+		claims, ok := s.impersonationClaims(ctx.Request().Header.Get(echo.HeaderAuthorization))
+		if !ok {
+			return err
+		}
+
+		// Errors returned by handlers are written later by HTTPErrorHandler
+		status := ctx.Response().Status
+		if err != nil {
+			status = toProblem(err).Status
+		}
+
+		auditErr := s.Repository.CreateImpersonationEvent(ctx.Request().Context(), repository.CreateImpersonationEventInput{
+			ActorId:   claims.ActorId,
+			TargetId:  claims.UserId,
+			Action:    repository.ImpersonationActionRequest,
+			Method:    ctx.Request().Method,
+			Path:      truncate(ctx.Request().URL.Path, 255),
+			Status:    status,
+			Ip:        clientIP(ctx),
+			UserAgent: truncate(ctx.Request().UserAgent(), 255),
+		})
+		if auditErr != nil {
+			ctx.Logger().Errorf("failed to record impersonated request: %v", auditErr)
+		}
+
+		return err
+	}
+}
+
+// Decode the claims of an impersonation token, ok is false for any other credential
+func (s *Server) impersonationClaims(authorization string) (claims JWTClaims, ok bool) {
+	auth, err := getToken(authorization)
+	if err != nil {
+		return
+	}
+
+	token := s.parseJWT(auth)
+	if token == nil || !token.Valid {
+		return
+	}
+
+	claims, err = s.ParseJWTClaims(token)
+	if err != nil || claims.ActorId == 0 {
+		return
+	}
+
+	return claims, true
+}
+
+// Cut value to at most n bytes to fit its column
+func truncate(value string, n int) string {
+	if len(value) > n {
+		return value[:n]
+	}
+	return value
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/AthanatiusC/SawitPro/generated"
+	"github.com/AthanatiusC/SawitPro/repository"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+TestImpersonateUser Criteria:
+- Admin receives a token for the target carrying the act claim
+- Impersonation start is recorded with the reason
+- Impersonated token can read but not update the profile, and the request is recorded
+- Non admin is refused
+*/
+func TestImpersonateUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	e := echo.New()
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	h := NewServer(NewServerOptions{Repository: repo})
+
+	admin := repository.User{Id: 1, Status: repository.UserStatusActive, Role: repository.RoleAdmin}
+	target := repository.User{Id: 2, Status: repository.UserStatusActive, Role: repository.RoleUser, TokenVersion: 3}
+	repo.EXPECT().GetUserById(gomock.Any(), admin.Id).Return(admin, nil).AnyTimes()
+	repo.EXPECT().GetUserById(gomock.Any(), target.Id).Return(target, nil).AnyTimes()
+	repo.EXPECT().CreateImpersonationEvent(gomock.Any(), gomock.Any()).DoAndReturn(func(_ interface{}, input repository.CreateImpersonationEventInput) error {
+		assert.Equal(t, repository.ImpersonationActionStart, input.Action)
+		assert.Equal(t, "ticket 42", input.Reason)
+		return nil
+	})
+
+	impersonate := func(actor repository.User) *httptest.ResponseRecorder {
+		token, err := h.GenerateJWT(JWTClaims{UserId: actor.Id})
+		if err != nil {
+			t.Error(err)
+		}
+		token = fmt.Sprintf("Bearer %s", token)
+
+		req := httptest.NewRequest(http.MethodPost, "/admin/users/2/impersonate", strings.NewReader(`{"reason":"ticket 42"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		assert.NoError(t, serve(h, e.NewContext(req, rec), func(ctx echo.Context) error {
+			return h.ImpersonateUser(ctx, target.Id, generated.ImpersonateUserParams{Authorization: &token})
+		}))
+		return rec
+	}
+
+	rec := impersonate(admin)
+	if !assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String()) {
+		return
+	}
+	var response generated.ImpersonationResponse
+	json.Unmarshal(rec.Body.Bytes(), &response)
+
+	auth := "Bearer " + response.Token
+	principal, err := h.Authenticate(context.Background(), &auth)
+	if assert.NoError(t, err) {
+		assert.Equal(t, target.Id, principal.User.Id)
+		assert.Equal(t, admin.Id, principal.Claims.ActorId)
+	}
+
+	// Update is refused and still recorded by the middleware
+	repo.EXPECT().CreateImpersonationEvent(gomock.Any(), gomock.Any()).DoAndReturn(func(_ interface{}, input repository.CreateImpersonationEventInput) error {
+		assert.Equal(t, repository.ImpersonationActionRequest, input.Action)
+		assert.Equal(t, http.MethodPut, input.Method)
+		assert.Equal(t, http.StatusForbidden, input.Status)
+		return nil
+	})
+	req := httptest.NewRequest(http.MethodPut, "/user", strings.NewReader(`{"full_name":"changed"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(echo.HeaderAuthorization, auth)
+	rec = httptest.NewRecorder()
+	handler := h.AuditImpersonation(func(ctx echo.Context) error {
+		auth := ctx.Request().Header.Get(echo.HeaderAuthorization)
+		return h.UpdateUser(ctx, generated.UpdateUserParams{Authorization: &auth})
+	})
+	if assert.NoError(t, serve(h, e.NewContext(req, rec), handler)) {
+		assert.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+	}
+
+	rec = impersonate(repository.User{Id: target.Id})
+	assert.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+}
@@ -0,0 +1,299 @@
+package handler
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/AthanatiusC/SawitPro/generated"
+	"github.com/AthanatiusC/SawitPro/i18n"
+	"github.com/AthanatiusC/SawitPro/repository"
+	"github.com/AthanatiusC/SawitPro/validation"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Limits of a single import, a planting season onboards a few hundred workers
+const (
+	importBatchSize = 100
+	importMaxRows   = 5000
+	importMaxBytes  = 5 << 20
+)
+
+// Statuses of an import row
+const (
+	importRowCreated = "created"
+	importRowValid   = "valid" // passed every check of a dry run
+	importRowFailed  = "failed"
+)
+
+// Columns an import csv must have, columns named attributes.<name> set custom attributes and other columns are ignored
+var importColumns = []string{"full_name", "phone_number", "password"}
+
+// Prefix of the csv columns holding custom attributes
+const importAttributePrefix = "attributes."
+
+var errImportTooLarge = validation.NewError("file", i18n.ImportTooLarge, importMaxBytes, importMaxRows)
+
+// Row of an import file, err is set when the row could not be parsed
+type importRow struct {
+	request    generated.RegisterJSONBody
+	cells      map[string]string // attributes of a csv row by name, as written, empty cells are left out
+	attributes repository.Attributes
+	err        *validation.Error
+}
+
+// (POST /admin/users/import) Import users endpoint, admin only, registers every row of a csv or ndjson file.
+// Rows go through the same validation as Register and are created in batches, one transaction per batch
+func (s *Server) ImportUsers(ctx echo.Context, params generated.ImportUsersParams) error {
+	_, err := s.AuthorizeAdmin(ctx.Request().Context(), params.Authorization)
+	if err != nil {
+		return err
+	}
+	dryRun := params.DryRun != nil && *params.DryRun
+	locale := requestLocale(ctx)
+
+	body := http.MaxBytesReader(ctx.Response(), ctx.Request().Body, importMaxBytes)
+	mediaType, _, _ := mime.ParseMediaType(ctx.Request().Header.Get(echo.HeaderContentType))
+	var rows []importRow
+	switch mediaType {
+	case "text/csv":
+		rows, err = readImportCSV(body)
+	case "application/x-ndjson", "application/ndjson":
+		rows, err = readImportNDJSON(body)
+	default:
+		return newProblem(http.StatusUnsupportedMediaType, i18n.UnsupportedMediaType, "text/csv or application/x-ndjson")
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	var fieldErr *validation.Error
+	if err == errImportTooLarge || errors.As(err, &maxBytesErr) {
+		return validationProblem(http.StatusRequestEntityTooLarge, errImportTooLarge)
+	} else if errors.As(err, &fieldErr) {
+		return validationProblem(http.StatusBadRequest, fieldErr)
+	} else if err != nil {
+		return newProblem(http.StatusBadRequest, i18n.InvalidRequest)
+	}
+
+	// Loaded once, every row is checked against the same definitions
+	definitions, err := s.attributeDefinitions(ctx.Request().Context())
+	if err != nil {
+		return err
+	}
+
+	response := generated.ImportUsersResponse{DryRun: dryRun, Rows: make([]generated.ImportRowResult, len(rows))}
+	var batch []int // indexes of the rows to create
+	phones := make(map[string]int)
+	for i, row := range rows {
+		result := &response.Rows[i]
+		result.Row = i + 1
+		result.Status = importRowFailed
+		result.PhoneNumber = s.NormalizePhoneNumber(row.request.PhoneNumber)
+
+		if row.err != nil {
+			result.Messages = rowMessages(locale, row.err)
+			continue
+		}
+
+		attributes, attributeErrors := validateAttributes(definitions, importAttributes(definitions, row))
+		errors := append(s.Users.Validate(row.request), attributeErrors...)
+		if len(errors) != 0 {
+			result.Messages = rowMessages(locale, errors...)
+			continue
+		}
+		rows[i].attributes = attributes
+
+		if first, ok := phones[result.PhoneNumber]; ok {
+			result.Messages = rowMessages(locale, validation.NewError("phone_number", i18n.DuplicateRow, first))
+			continue
+		}
+		phones[result.PhoneNumber] = result.Row
+
+		batch = append(batch, i)
+		if len(batch) == importBatchSize {
+			s.importBatch(ctx, rows, batch, dryRun, &response)
+			batch = nil
+		}
+	}
+	if len(batch) != 0 {
+		s.importBatch(ctx, rows, batch, dryRun, &response)
+	}
+
+	for _, result := range response.Rows {
+		if result.Status == importRowFailed {
+			response.Failed++
+		} else {
+			response.Succeeded++
+		}
+	}
+
+	return ctx.JSON(http.StatusOK, response)
+}
+
+// Create the validated rows at indexes in one transaction and record the outcome on their results
+func (s *Server) importBatch(ctx echo.Context, rows []importRow, indexes []int, dryRun bool, response *generated.ImportUsersResponse) {
+	locale := requestLocale(ctx)
+	inputs := make([]repository.CreateUserInput, len(indexes))
+	for i, index := range indexes {
+		inputs[i] = repository.CreateUserInput{
+			Name:       rows[index].request.FullName,
+			Phone:      response.Rows[index].PhoneNumber,
+			Attributes: rows[index].attributes,
+		}
+		if dryRun {
+			continue
+		}
+
+		// cost 6 = 64 Rounds(2^6=64) same as Register
+		password, err := bcrypt.GenerateFromPassword([]byte(rows[index].request.Password), 6)
+		if err != nil {
+			failImportBatch(response, indexes, i18n.Message(locale, i18n.InternalError))
+			return
+		}
+		inputs[i].Password = string(password)
+	}
+
+	results, err := s.Repository.CreateUsers(ctx.Request().Context(), inputs, dryRun)
+	if err != nil {
+		ctx.Logger().Errorf("failed to import users: %v", err)
+		failImportBatch(response, indexes, i18n.Message(locale, i18n.RowNotCreated))
+		return
+	}
+
+	for i, index := range indexes {
+		result := &response.Rows[index]
+		switch {
+		case results[i].Conflict:
+			result.Messages = rowMessages(locale, validation.NewError("phone_number", i18n.PhoneNumberRegistered))
+		case results[i].AttributeTaken != "":
+			result.Messages = rowMessages(locale, attributeTaken(&repository.AttributeTakenError{Name: results[i].AttributeTaken}))
+		case dryRun:
+			result.Status = importRowValid
+		default:
+			result.Status = importRowCreated
+			result.Id = &results[i].Id
+		}
+	}
+}
+
+// Attributes of a row before they are checked, csv cells are parsed into the type of their definition.
+// Cells that do not parse are kept as written for validateAttributes to report
+func importAttributes(definitions map[string]repository.AttributeDefinition, row importRow) map[string]interface{} {
+	if row.cells == nil {
+		if row.request.Attributes == nil {
+			return nil
+		}
+		return *row.request.Attributes
+	}
+
+	values := make(map[string]interface{}, len(row.cells))
+	for name, cell := range row.cells {
+		values[name] = cell
+		if definition, defined := definitions[name]; defined {
+			if value, err := parseAttribute(definition, cell); err == nil {
+				values[name] = value
+			}
+		}
+	}
+	return values
+}
+
+// Messages of the failures of a row, rows only report "field : message" strings
+func rowMessages(locale i18n.Locale, errs ...*validation.Error) *[]string {
+	_, messages := localizeErrors(errs, locale)
+	return &messages
+}
+
+func failImportBatch(response *generated.ImportUsersResponse, indexes []int, message string) {
+	for _, index := range indexes {
+		response.Rows[index].Messages = &[]string{message}
+	}
+}
+
+// Read a csv with a header row naming at least the importColumns
+func readImportCSV(body io.Reader) (rows []importRow, err error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1 // checked per row so a short row only fails itself
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, validation.NewError("file", i18n.HeaderRequired)
+	} else if err != nil {
+		return nil, validation.NewError("file", i18n.InvalidFile, "csv")
+	}
+
+	columns := make(map[string]int)
+	attributeColumns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		columns[name] = i
+		if attribute := strings.TrimPrefix(name, importAttributePrefix); attribute != name {
+			attributeColumns[attribute] = i
+		}
+	}
+	for _, name := range importColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, validation.NewError("file", i18n.ColumnRequired, name)
+		}
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		} else if err != nil {
+			return nil, validation.NewError("file", i18n.InvalidFile, "csv")
+		}
+		if len(rows) == importMaxRows {
+			return nil, errImportTooLarge
+		}
+
+		var row importRow
+		if len(record) != len(header) {
+			row.err = validation.NewError("row", i18n.ColumnCount, len(header), len(record))
+		} else {
+			row.request = generated.RegisterJSONBody{
+				FullName:    unescapeCSVCell(record[columns["full_name"]]),
+				PhoneNumber: unescapeCSVCell(record[columns["phone_number"]]),
+				Password:    record[columns["password"]],
+			}
+			row.cells = make(map[string]string)
+			for name, i := range attributeColumns {
+				if cell := unescapeCSVCell(strings.TrimSpace(record[i])); cell != "" {
+					row.cells[name] = cell
+				}
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+// Read one json object per line, blank lines are skipped
+func readImportNDJSON(body io.Reader) (rows []importRow, err error) {
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if len(rows) == importMaxRows {
+			return nil, errImportTooLarge
+		}
+
+		var row importRow
+		if err := json.Unmarshal([]byte(line), &row.request); err != nil {
+			row.err = validation.NewError("row", i18n.MustBeObject)
+		}
+		rows = append(rows, row)
+	}
+	if scanner.Err() != nil {
+		return nil, validation.NewError("file", i18n.InvalidFile, "ndjson")
+	}
+	return rows, nil
+}
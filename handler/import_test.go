@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/AthanatiusC/SawitPro/generated"
+	"github.com/AthanatiusC/SawitPro/repository"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+/*
+TestImportUsers Criteria:
+- Admin only
+- Valid csv rows are created with a hashed password, cells quoted by the user export are read back unquoted
+- Invalid, duplicated and already registered rows fail with a message
+- Attributes of csv columns and ndjson objects are checked like Register, a taken unique value only fails its row
+- Dry run of ndjson creates nothing
+*/
+func TestImportUsers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	e := echo.New()
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	h := NewServer(NewServerOptions{Repository: repo})
+
+	admin := repository.User{Id: 1, Status: repository.UserStatusActive, Role: repository.RoleAdmin}
+	repo.EXPECT().GetUserById(gomock.Any(), admin.Id).Return(admin, nil).Times(2)
+	repo.EXPECT().ListAttributeDefinitions(gomock.Any()).Return([]repository.AttributeDefinition{
+		{Name: "estate_code", Type: repository.AttributeTypeString, Required: true, Unique: true},
+		{Name: "harvester", Type: repository.AttributeTypeBoolean},
+	}, nil).Times(2)
+	gomock.InOrder(
+		repo.EXPECT().CreateUsers(gomock.Any(), gomock.Len(3), false).
+			DoAndReturn(func(_ context.Context, inputs []repository.CreateUserInput, _ bool) ([]repository.CreateUsersResult, error) {
+				assert.Equal(t, "Budi Santoso", inputs[0].Name)
+				assert.Equal(t, "+6281234567890", inputs[0].Phone)
+				assert.Equal(t, repository.Attributes{"estate_code": "KLT-02", "harvester": true}, inputs[0].Attributes)
+				assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(inputs[0].Password), []byte("Userpassw0rd!")))
+				return []repository.CreateUsersResult{{Id: 10}, {Conflict: true}, {AttributeTaken: "estate_code"}}, nil
+			}),
+		repo.EXPECT().CreateUsers(gomock.Any(), []repository.CreateUserInput{{
+			Name:       "Siti Aminah",
+			Phone:      "+6281234567891",
+			Attributes: repository.Attributes{"estate_code": "KLT-09"},
+		}}, true).
+			Return([]repository.CreateUsersResult{{}}, nil),
+	)
+
+	token, err := h.GenerateJWT(JWTClaims{UserId: admin.Id})
+	if err != nil {
+		t.Error(err)
+	}
+	token = fmt.Sprintf("Bearer %s", token)
+
+	upload := func(contentType string, body string, dryRun bool) generated.ImportUsersResponse {
+		req := httptest.NewRequest(http.MethodPost, "/admin/users/import", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, contentType)
+		rec := httptest.NewRecorder()
+		assert.NoError(t, serve(h, e.NewContext(req, rec), func(ctx echo.Context) error {
+			return h.ImportUsers(ctx, generated.ImportUsersParams{DryRun: &dryRun, Authorization: &token})
+		}))
+		assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var response generated.ImportUsersResponse
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		return response
+	}
+
+	response := upload("text/csv", strings.Join([]string{
+		"full_name,phone_number,password,attributes.estate_code,attributes.harvester",
+		"Budi Santoso,'+6281234567890,Userpassw0rd!,KLT-02,true",
+		"Budi,+6281234567891,weak,KLT-03,",
+		"Budi Kedua,+6281234567890,Userpassw0rd!,KLT-04,",
+		"Agus Salim,+6281234567892,Userpassw0rd!,KLT-05,",
+		"Rudi Hartono,+6281234567893,Userpassw0rd!,,yes please",
+		"Joko Susilo,+6281234567894,Userpassw0rd!,KLT-02,false",
+	}, "\n"), false)
+	assert.Equal(t, 1, response.Succeeded)
+	assert.Equal(t, 5, response.Failed)
+	if assert.Len(t, response.Rows, 6) {
+		assert.Equal(t, importRowCreated, response.Rows[0].Status)
+		assert.Equal(t, 10, *response.Rows[0].Id)
+		assert.Equal(t, importRowFailed, response.Rows[1].Status)
+		assert.Equal(t, []string{"phone_number : duplicate of row 1"}, *response.Rows[2].Messages)
+		assert.Equal(t, []string{"phone_number : phone number is already registered"}, *response.Rows[3].Messages)
+		assert.Equal(t, []string{"attributes.estate_code : attributes.estate_code is required", "attributes.harvester : must be a boolean"}, *response.Rows[4].Messages)
+		assert.Equal(t, []string{"attributes.estate_code : is already used by another user"}, *response.Rows[5].Messages)
+	}
+
+	response = upload("application/x-ndjson", `{"full_name":"Siti Aminah","phone_number":"+6281234567891","password":"Userpassw0rd!","attributes":{"estate_code":"KLT-09"}}`+"\n\nnot json\n", true)
+	assert.True(t, response.DryRun)
+	if assert.Len(t, response.Rows, 2) {
+		assert.Equal(t, importRowValid, response.Rows[0].Status)
+		assert.Nil(t, response.Rows[0].Id)
+		assert.Equal(t, importRowFailed, response.Rows[1].Status)
+	}
+}
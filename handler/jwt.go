@@ -3,12 +3,32 @@ package handler
 import (
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
 	jwt "github.com/golang-jwt/jwt/v5"
 )
 
+// Scopes granted to access tokens, stored space delimited in the "scope" claim
+const (
+	ScopeProfileRead  = "profile:read"
+	ScopeProfileWrite = "profile:write"
+)
+
+// Scopes granted to tokens issued by login
+var defaultScopes = []string{ScopeProfileRead, ScopeProfileWrite}
+
+// Claims carried by access tokens issued by this service
+type JWTClaims struct {
+	UserId    int
+	Version   int // must match users.token_version, see Authenticate
+	Scope     []string
+	ExpiresAt time.Time
+	ActorId   int // admin acting as UserId, carried in the "act" claim (RFC 8693), 0 when not impersonated
+	SessionId int // session created by login, 0 for tokens not tied to a session
+}
+
 // Isolate token from string, returns valid token out of auth bearer
 func getToken(auth string) (string, error) {
 	// Bearer {{$token}}, split into 2 index, get second index in this case 1st index
@@ -27,7 +47,12 @@ func (s *Server) ValidateJWT(authParam string) (token *jwt.Token) {
 		return
 	}
 
-	token, err = jwt.Parse(auth, func(token *jwt.Token) (interface{}, error) { // JWT Parse require func(interface{},error) as its argument
+	return s.parseJWT(auth)
+}
+
+// Parse and verify a raw JWT string, returns nil when the token is malformed
+func (s *Server) parseJWT(raw string) (token *jwt.Token) {
+	token, err := jwt.Parse(raw, func(token *jwt.Token) (interface{}, error) { // JWT Parse require func(interface{},error) as its argument
 		if _, OK := token.Method.(*jwt.SigningMethodHMAC); !OK {
 			return nil, errors.New("bad signed method received")
 		}
@@ -46,15 +71,88 @@ func (s *Server) GetJWTClaims(token *jwt.Token, key string) (string, error) {
 	return claims, nil
 }
 
+// Read every claim issued by GenerateJWT, returns error when a required claim is malformed
+func (s *Server) ParseJWTClaims(token *jwt.Token) (claims JWTClaims, err error) {
+	mapClaims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return claims, errors.New("invalid claims")
+	}
+
+	id, ok := mapClaims["id"].(string)
+	if !ok {
+		return claims, errors.New("invalid id claim")
+	}
+	claims.UserId, err = strconv.Atoi(id)
+	if err != nil {
+		return
+	}
+
+	// Numeric claims are decoded as float64, missing version means tokens issued before versioning
+	if version, ok := mapClaims["ver"].(float64); ok {
+		claims.Version = int(version)
+	}
+
+	if scope, ok := mapClaims["scope"].(string); ok {
+		claims.Scope = strings.Fields(scope)
+	} else {
+		claims.Scope = defaultScopes
+	}
+
+	if sid, ok := mapClaims["sid"].(string); ok {
+		claims.SessionId, err = strconv.Atoi(sid)
+		if err != nil {
+			return
+		}
+	}
+
+	// act is {"sub": "<admin id>"}, nested act claims are not issued by this service
+	if act, ok := mapClaims["act"].(map[string]interface{}); ok {
+		actorId, ok := act["sub"].(string)
+		if !ok {
+			return claims, errors.New("invalid act claim")
+		}
+		claims.ActorId, err = strconv.Atoi(actorId)
+		if err != nil {
+			return
+		}
+	}
+
+	exp, err := mapClaims.GetExpirationTime()
+	if err != nil {
+		return
+	}
+	if exp != nil {
+		claims.ExpiresAt = exp.Time
+	}
+
+	return
+}
+
 // Generate JWT using envar secrets, returns valid jwt token and error
-func (s *Server) GenerateJWT(id int) (token string, err error) {
-	exp := time.Now().Add(time.Hour * 24).Unix() // Common exp time
-	claims := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"id":  fmt.Sprint(id), // to ensure string convert when get claims
-		"exp": exp,
-	})
+func (s *Server) GenerateJWT(claims JWTClaims) (token string, err error) {
+	if claims.ExpiresAt.IsZero() {
+		claims.ExpiresAt = time.Now().Add(time.Hour * 24) // Common exp time
+	}
+	if claims.Scope == nil {
+		claims.Scope = defaultScopes
+	}
+
+	mapClaims := jwt.MapClaims{
+		"id":    fmt.Sprint(claims.UserId), // to ensure string convert when get claims
+		"ver":   claims.Version,
+		"scope": strings.Join(claims.Scope, " "),
+		"exp":   claims.ExpiresAt.Unix(),
+	}
+	if claims.SessionId != 0 {
+		mapClaims["sid"] = fmt.Sprint(claims.SessionId)
+	}
+	if claims.ActorId != 0 {
+		mapClaims["act"] = map[string]string{"sub": fmt.Sprint(claims.ActorId)}
+	}
+
+	jwtClaims := jwt.NewWithClaims(jwt.SigningMethodHS256, mapClaims)
 
-	token, err = claims.SignedString([]byte(s.JWTSecret))
+	token, err = jwtClaims.SignedString([]byte(s.JWTSecret))
 	if err != nil {
 		return
 	}
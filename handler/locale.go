@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"strings"
+
+	"github.com/AthanatiusC/SawitPro/i18n"
+	"github.com/AthanatiusC/SawitPro/validation"
+	"github.com/labstack/echo/v4"
+)
+
+/*
+Middleware attaching the locale of the request to its context, register with echo Use.
+The locale is negotiated from Accept-Language, Authenticate switches it to the preference of the
+user when they have one. The locale finally used is sent in Content-Language
+*/
+func (s *Server) Localize(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		locale := i18n.Negotiate(ctx.Request().Header.Get("Accept-Language"))
+		ctx.Response().Header().Add(echo.HeaderVary, "Accept-Language")
+		ctx.Response().Before(func() {
+			ctx.Response().Header().Set("Content-Language", string(locale))
+		})
+
+		request := ctx.Request()
+		ctx.SetRequest(request.WithContext(i18n.WithLocale(request.Context(), &locale)))
+
+		return next(ctx)
+	}
+}
+
+// Locale of the response to a request, negotiated from Accept-Language when Localize did not run
+func requestLocale(ctx echo.Context) i18n.Locale {
+	if locale, ok := i18n.FromContext(ctx.Request().Context()); ok {
+		return locale
+	}
+	return i18n.Negotiate(ctx.Request().Header.Get("Accept-Language"))
+}
+
+// Validate a locale preference sent by the user, it is stored as the supported locale it names e.g id for id-ID
+func localePreference(value string) (preference string, err *validation.Error) {
+	parsed, ok := i18n.Parse(value)
+	if !ok {
+		supported := make([]string, len(i18n.Locales))
+		for i, supportedLocale := range i18n.Locales {
+			supported[i] = string(supportedLocale)
+		}
+		return "", validation.NewError("locale", i18n.OneOf, strings.Join(supported, ", "))
+	}
+	return string(parsed), nil
+}
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/AthanatiusC/SawitPro/generated"
+	"github.com/AthanatiusC/SawitPro/repository"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+TestLocalize Criteria:
+- Messages are in the language negotiated from Accept-Language, English by default
+- Locale preference of the user wins over Accept-Language
+- Content-Language tells the locale used and errors keep their stable code
+*/
+func TestLocalize(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	e := echo.New()
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	h := NewServer(NewServerOptions{Repository: repo})
+	repo.EXPECT().ListAttributeDefinitions(gomock.Any()).Return(nil, nil).AnyTimes()
+
+	register := func(acceptLanguage string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(`{"full_name":"","password":"","phone_number":""}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set("Accept-Language", acceptLanguage)
+		rec := httptest.NewRecorder()
+		assert.NoError(t, serve(h, e.NewContext(req, rec), h.Localize(h.Register)))
+		return rec
+	}
+
+	rec := register("id-ID,id;q=0.9,en;q=0.8")
+	if assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String()) {
+		assert.Contains(t, rec.Body.String(), "wajib diisi")
+		assert.Equal(t, "id", rec.Header().Get("Content-Language"))
+		assert.Equal(t, "Accept-Language", rec.Header().Get(echo.HeaderVary))
+	}
+
+	rec = register("fr, id;q=0")
+	if assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String()) {
+		assert.Contains(t, rec.Body.String(), "is required")
+		assert.Equal(t, "en", rec.Header().Get("Content-Language"))
+	}
+
+	preference := "id"
+	user := repository.User{Id: 1, Name: "user", Locale: &preference, Status: repository.UserStatusActive}
+	repo.EXPECT().GetUserById(gomock.Any(), user.Id).Return(user, nil)
+
+	token, err := h.GenerateJWT(JWTClaims{UserId: user.Id})
+	if err != nil {
+		t.Error(err)
+	}
+	token = fmt.Sprintf("Bearer %s", token)
+
+	req := httptest.NewRequest(http.MethodPut, "/user", strings.NewReader(`{}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set("Accept-Language", "en")
+	rec = httptest.NewRecorder()
+	update := func(ctx echo.Context) error {
+		return h.UpdateUser(ctx, generated.UpdateUserParams{Authorization: &token})
+	}
+	assert.NoError(t, serve(h, e.NewContext(req, rec), h.Localize(update)))
+	if assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String()) {
+		assert.Contains(t, rec.Body.String(), `"detail":"permintaan tidak boleh kosong"`)
+		assert.Equal(t, "id", rec.Header().Get("Content-Language"))
+	}
+}
@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/AthanatiusC/SawitPro/generated"
+	"github.com/AthanatiusC/SawitPro/i18n"
+	"github.com/AthanatiusC/SawitPro/notification"
+	"github.com/AthanatiusC/SawitPro/repository"
+	"github.com/AthanatiusC/SawitPro/validation"
+	"github.com/labstack/echo/v4"
+)
+
+// Page size limits of list endpoints
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// (GET /user/login-history) Login history endpoint, returns successful and failed login attempts of the user newest first
+func (s *Server) ListLoginHistory(ctx echo.Context, params generated.ListLoginHistoryParams) error {
+	principal, err := s.Authorize(ctx.Request().Context(), params.Authorization, ScopeProfileRead)
+	if err != nil {
+		return err
+	}
+
+	limit, limitErr := pageLimit(params.Limit)
+	if limitErr != nil {
+		return validationProblem(http.StatusBadRequest, limitErr)
+	}
+
+	var beforeId int
+	if params.Cursor != nil {
+		beforeId, err = decodeIdCursor(*params.Cursor)
+		if err != nil {
+			return validationProblem(http.StatusBadRequest, validation.NewError("cursor", i18n.InvalidCursor))
+		}
+	}
+
+	// Fetch one extra row to know whether another page exists
+	attempts, err := s.Repository.ListLoginAttemptsByUserId(ctx.Request().Context(), repository.ListLoginAttemptsInput{
+		UserId:   principal.User.Id,
+		Limit:    limit + 1,
+		BeforeId: beforeId,
+	})
+	if err != nil {
+		return err
+	}
+
+	response := generated.LoginHistoryResponse{LoginAttempts: []generated.LoginAttempt{}}
+	if len(attempts) > limit {
+		attempts = attempts[:limit]
+		nextCursor := encodeIdCursor(attempts[limit-1].Id)
+		response.NextCursor = &nextCursor
+	}
+
+	for _, attempt := range attempts {
+		response.LoginAttempts = append(response.LoginAttempts, generated.LoginAttempt{
+			Success:   attempt.Success,
+			Reason:    attempt.Reason,
+			Ip:        attempt.Ip,
+			UserAgent: attempt.UserAgent,
+			CreatedAt: attempt.CreatedAt,
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, response)
+}
+
+// Record a login attempt, failing to record is logged and never changes the login outcome
+func (s *Server) recordLoginAttempt(ctx echo.Context, attempt repository.CreateLoginAttemptInput) {
+	if err := s.Repository.CreateLoginAttempt(ctx.Request().Context(), attempt); err != nil {
+		ctx.Logger().Errorf("failed to record login attempt: %v", err)
+	}
+}
+
+// Alert the user of a login from a device never seen before, failing to notify is only logged
+func (s *Server) notifyNewDevice(ctx echo.Context, user repository.User, deviceName string) {
+	err := s.Notifier.Notify(ctx.Request().Context(), notification.Message{
+		UserId:  user.Id,
+		Phone:   user.Phone,
+		Subject: "New sign-in to your account",
+		Body:    fmt.Sprintf("Your account was signed in from a new device (%s, IP %s). If this was not you, revoke the session and change your password.", deviceName, clientIP(ctx)),
+	})
+	if err != nil {
+		ctx.Logger().Errorf("failed to notify new device: %v", err)
+	}
+}
+
+// Identify the client of a login, ip is left out since mobile clients change networks often
+func deviceFingerprint(userAgent, deviceName string) string {
+	sum := sha256.Sum256([]byte(userAgent + "\n" + deviceName))
+	return hex.EncodeToString(sum[:])
+}
+
+// Resolve the requested page size, defaults when omitted
+func pageLimit(limit *int) (int, *validation.Error) {
+	if limit == nil {
+		return defaultPageLimit, nil
+	}
+	if *limit < 1 || *limit > maxPageLimit {
+		return 0, validation.NewError("limit", i18n.Between, 1, maxPageLimit)
+	}
+	return *limit, nil
+}
+
+// Cursors are opaque to clients, they only wrap the id of the last row of a page
+func encodeIdCursor(id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(id)))
+}
+
+func decodeIdCursor(cursor string) (int, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := strconv.Atoi(string(decoded))
+	if err != nil || id < 1 {
+		return 0, errors.New("invalid cursor")
+	}
+	return id, nil
+}
@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/AthanatiusC/SawitPro/generated"
+	"github.com/AthanatiusC/SawitPro/notification"
+	"github.com/AthanatiusC/SawitPro/repository"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// Notifier keeping every message for assertions
+type recordingNotifier struct {
+	messages []notification.Message
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, message notification.Message) error {
+	n.messages = append(n.messages, message)
+	return nil
+}
+
+/*
+TestLoginNewDevice Criteria:
+- Failed attempt is recorded with its reason
+- Successful attempt from an unseen device notifies the user
+- Attempts and notifications carry the ip of the connection, a forged X-Forwarded-For is ignored
+*/
+func TestLoginNewDevice(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	e := echo.New()
+	e.IPExtractor = echo.ExtractIPDirect()
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	notifier := &recordingNotifier{}
+	h := NewServer(NewServerOptions{Repository: repo, Notifier: notifier})
+
+	user := repository.User{Id: 1, Status: repository.UserStatusActive, Phone: "+6280000000000", Password: "$2a$06$bt380.sYY0HEAa1tz2eyfOOQDHarjgiABmv.ZJTXzKdXMU.hQFAyi"}
+	repo.EXPECT().GetUserByPhoneNumber(gomock.Any(), user.Phone).Return(user, nil).Times(2)
+	repo.EXPECT().GetLoginDeviceStatus(gomock.Any(), user.Id, gomock.Any()).Return(repository.LoginDeviceStatus{HasLoggedIn: true}, nil)
+	repo.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Return(repository.Session{Id: 1}, nil)
+
+	var attempts []repository.CreateLoginAttemptInput
+	repo.EXPECT().CreateLoginAttempt(gomock.Any(), gomock.Any()).DoAndReturn(func(_ interface{}, input repository.CreateLoginAttemptInput) error {
+		attempts = append(attempts, input)
+		return nil
+	}).Times(2)
+
+	login := func(password string) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"phone_number":"+6280000000000","password":"%s","device_name":"field tablet"}`, password)
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderXForwardedFor, "198.51.100.7")
+		req.RemoteAddr = "192.0.2.1:1234"
+		rec := httptest.NewRecorder()
+		assert.NoError(t, serve(h, e.NewContext(req, rec), h.Login))
+		return rec
+	}
+
+	rec := login("Wrongpassw0rd!")
+	assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+
+	rec = login("Userpassw0rd!")
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	if assert.Len(t, attempts, 2) {
+		assert.False(t, attempts[0].Success)
+		assert.Equal(t, repository.LoginReasonInvalidPassword, attempts[0].Reason)
+		assert.True(t, attempts[1].Success)
+		assert.Equal(t, attempts[0].DeviceFingerprint, attempts[1].DeviceFingerprint)
+		assert.Equal(t, "192.0.2.1", attempts[1].Ip)
+	}
+	if assert.Len(t, notifier.messages, 1) {
+		assert.Contains(t, notifier.messages[0].Body, "field tablet")
+		assert.Contains(t, notifier.messages[0].Body, "IP 192.0.2.1")
+	}
+}
+
+/*
+TestListLoginHistory Criteria:
+- Page is cut at limit and returns a cursor
+- Cursor resumes after the last attempt of the page
+*/
+func TestListLoginHistory(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	e := echo.New()
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	h := NewServer(NewServerOptions{Repository: repo})
+
+	user := repository.User{Id: 1, Status: repository.UserStatusActive}
+	repo.EXPECT().GetUserById(gomock.Any(), user.Id).Return(user, nil).Times(2)
+	repo.EXPECT().ListLoginAttemptsByUserId(gomock.Any(), repository.ListLoginAttemptsInput{UserId: user.Id, Limit: 3}).
+		Return([]repository.LoginAttempt{{Id: 9}, {Id: 8}, {Id: 7}}, nil)
+	repo.EXPECT().ListLoginAttemptsByUserId(gomock.Any(), repository.ListLoginAttemptsInput{UserId: user.Id, Limit: 3, BeforeId: 8}).
+		Return([]repository.LoginAttempt{{Id: 7}}, nil)
+
+	token, err := h.GenerateJWT(JWTClaims{UserId: user.Id})
+	if err != nil {
+		t.Error(err)
+	}
+	token = fmt.Sprintf("Bearer %s", token)
+
+	listLoginHistory := func(cursor *string) generated.LoginHistoryResponse {
+		limit := 2
+		req := httptest.NewRequest(http.MethodGet, "/user/login-history", nil)
+		rec := httptest.NewRecorder()
+		assert.NoError(t, serve(h, e.NewContext(req, rec), func(ctx echo.Context) error {
+			return h.ListLoginHistory(ctx, generated.ListLoginHistoryParams{Limit: &limit, Cursor: cursor, Authorization: &token})
+		}))
+		assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var response generated.LoginHistoryResponse
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		return response
+	}
+
+	response := listLoginHistory(nil)
+	assert.Len(t, response.LoginAttempts, 2)
+	if assert.NotNil(t, response.NextCursor) {
+		response = listLoginHistory(response.NextCursor)
+		assert.Len(t, response.LoginAttempts, 1)
+		assert.Nil(t, response.NextCursor)
+	}
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/AthanatiusC/SawitPro/generated"
+	"github.com/AthanatiusC/SawitPro/repository"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+TestIntrospectToken Criteria:
+- Valid client credentials
+- Valid JWT with matching token version reports active with subject and roles
+- Outdated token version reports inactive
+- Invalid client credentials are refused
+*/
+func TestIntrospectToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	e := echo.New()
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	opts := NewServerOptions{
+		Repository:           repo,
+		IntrospectionClients: map[string]string{"gateway": "secret"},
+	}
+	h := NewServer(opts)
+
+	user := repository.User{Id: 1, Status: repository.UserStatusActive, Role: repository.RoleUser, TokenVersion: 2}
+	repo.EXPECT().GetUserById(gomock.Any(), user.Id).Return(user, nil).Times(2)
+
+	introspect := func(token, clientSecret string) (*httptest.ResponseRecorder, generated.IntrospectionResponse) {
+		form := url.Values{"token": {token}}
+		req := httptest.NewRequest(http.MethodPost, "/oauth/introspect", strings.NewReader(form.Encode()))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+		req.SetBasicAuth("gateway", clientSecret)
+
+		rec := httptest.NewRecorder()
+		assert.NoError(t, serve(h, e.NewContext(req, rec), h.IntrospectToken))
+
+		var response generated.IntrospectionResponse
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		return rec, response
+	}
+
+	token, err := h.GenerateJWT(JWTClaims{UserId: user.Id, Version: user.TokenVersion})
+	if err != nil {
+		t.Error(err)
+	}
+	rec, response := introspect(token, "secret")
+	if assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String()) && assert.True(t, response.Active) {
+		assert.Equal(t, "1", *response.Sub)
+		assert.Equal(t, []string{repository.RoleUser}, *response.Roles)
+	}
+
+	revoked, err := h.GenerateJWT(JWTClaims{UserId: user.Id, Version: user.TokenVersion - 1})
+	if err != nil {
+		t.Error(err)
+	}
+	rec, response = introspect(revoked, "secret")
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.False(t, response.Active)
+
+	rec, _ = introspect(token, "wrong")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, rec.Body.String())
+}
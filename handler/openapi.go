@@ -0,0 +1,289 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/AthanatiusC/SawitPro/i18n"
+	"github.com/AthanatiusC/SawitPro/validation"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/legacy"
+	"github.com/labstack/echo/v4"
+)
+
+// Largest body checked against api.yml, csv, ndjson and multipart bodies are left to their handler which limits them
+const contractMaxBytes = 1 << 20
+
+func init() {
+	// Profile patches are json, kin-openapi only decodes the media types it registered
+	openapi3filter.RegisterBodyDecoder(mergePatchMediaType, openapi3filter.RegisteredBodyDecoder(echo.MIMEApplicationJSON))
+
+	// Emails are checked like handlers check them, kin-openapi ignores formats it does not define
+	openapi3.DefineStringFormatCallback("email", func(value string) error {
+		if errs := validation.Optional("email", validation.Email()).Check(value); len(errs) != 0 {
+			return errs[0]
+		}
+		return nil
+	})
+}
+
+// Router of the operations of api.yml, its servers are ignored so requests match whatever host serves them
+func newContractRouter(spec *openapi3.T) (routers.Router, error) {
+	spec.Servers = nil
+	return legacy.NewRouter(spec)
+}
+
+/*
+Validate requests against api.yml before they reach handlers, register after Localize so failures are localized.
+  - parameters and json bodies are checked: required fields, types, formats, lengths, patterns and enums
+  - failures are refused like handlers refuse them, 400 listing the invalid fields in the order of api.yml,
+    415 for a content type the operation does not accept
+  - routes api.yml does not declare are left to echo
+  - handlers still apply the rules api.yml cannot express, e.g phone regions and the password policy
+
+With ValidateResponses, responses are checked too and violations are logged, the response is sent unchanged
+*/
+func (s *Server) ValidateContract(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		route, pathParams, err := s.contract.FindRoute(ctx.Request())
+		if err != nil {
+			return next(ctx)
+		}
+
+		input := &openapi3filter.RequestValidationInput{
+			Request:    ctx.Request(),
+			PathParams: pathParams,
+			Route:      route,
+			Options: &openapi3filter.Options{
+				MultiError:         true,
+				AuthenticationFunc: openapi3filter.NoopAuthenticationFunc, // handlers authenticate
+			},
+		}
+		if body := route.Operation.RequestBody; body != nil && body.Value != nil {
+			contentType := ctx.Request().Header.Get(echo.HeaderContentType)
+			if contentType != "" && body.Value.Content.Get(contentType) == nil {
+				return newProblem(http.StatusUnsupportedMediaType, i18n.UnsupportedMediaType, mediaTypes(body.Value.Content))
+			}
+			if streamedMediaType(contentType) {
+				input.Options.ExcludeRequestBody = true
+			} else if ctx.Request().Body != nil {
+				ctx.Request().Body = http.MaxBytesReader(ctx.Response(), ctx.Request().Body, contractMaxBytes)
+			}
+		}
+		if err := openapi3filter.ValidateRequest(ctx.Request().Context(), input); err != nil {
+			return requestProblem(err)
+		}
+
+		if !s.ValidateResponses {
+			return next(ctx)
+		}
+		return s.validateResponse(ctx, next, input)
+	}
+}
+
+// Run next and log where its response violates api.yml
+func (s *Server) validateResponse(ctx echo.Context, next echo.HandlerFunc, request *openapi3filter.RequestValidationInput) error {
+	recorder := &responseRecorder{ResponseWriter: ctx.Response().Writer}
+	ctx.Response().Writer = recorder
+	defer func() { ctx.Response().Writer = recorder.ResponseWriter }()
+
+	// Errors are written here instead of by echo so problem responses are checked too
+	if err := next(ctx); err != nil {
+		ctx.Error(err)
+	}
+
+	input := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: request,
+		Status:                 ctx.Response().Status,
+		Header:                 ctx.Response().Header(),
+		Options: &openapi3filter.Options{
+			MultiError:            true,
+			IncludeResponseStatus: true,
+			ExcludeResponseBody: recorder.truncated || ctx.Request().Method == http.MethodHead ||
+				streamedMediaType(ctx.Response().Header().Get(echo.HeaderContentType)),
+		},
+	}
+	input.SetBodyBytes(recorder.body.Bytes())
+	if err := openapi3filter.ValidateResponse(ctx.Request().Context(), input); err != nil {
+		ctx.Logger().Errorf("%s %s response violates api.yml: %v", ctx.Request().Method, ctx.Request().URL.Path, err)
+	}
+	return nil
+}
+
+// Copy of a response written through, kept to check it against api.yml
+type responseRecorder struct {
+	http.ResponseWriter
+	body      bytes.Buffer
+	truncated bool // larger than contractMaxBytes, the body is not checked
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.body.Len()+len(b) > contractMaxBytes {
+		r.truncated = true
+	} else {
+		r.body.Write(b)
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *responseRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Media types whose bodies are streamed to or by their handler instead of being checked against api.yml
+func streamedMediaType(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/csv", "application/x-ndjson", "application/zip", echo.MIMEMultipartForm:
+		return true
+	default:
+		return false
+	}
+}
+
+// Media types an operation accepts, sorted
+func mediaTypes(content openapi3.Content) string {
+	types := make([]string, 0, len(content))
+	for mediaType := range content {
+		types = append(types, mediaType)
+	}
+	sort.Strings(types)
+	return strings.Join(types, ", ")
+}
+
+// Problem of a request refused by openapi3filter.ValidateRequest
+func requestProblem(err error) error {
+	var errs validation.Errors
+	checked := make(map[string]bool)
+	for _, err := range unwrapMultiError(err) {
+		var requestErr *openapi3filter.RequestError
+		if !errors.As(err, &requestErr) {
+			return err
+		}
+
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case requestErr.RequestBody != nil && errors.Is(requestErr.Err, openapi3filter.ErrInvalidRequired):
+			return newProblem(http.StatusBadRequest, i18n.EmptyRequest)
+		case requestErr.RequestBody != nil && requestErr.Err == nil:
+			// The only failure without a cause is a content type the operation does not accept
+			return newProblem(http.StatusUnsupportedMediaType, i18n.UnsupportedMediaType, mediaTypes(requestErr.RequestBody.Content))
+		case errors.As(requestErr.Err, &maxBytesErr):
+			return newProblem(http.StatusRequestEntityTooLarge, i18n.RequestTooLarge, contractMaxBytes)
+		}
+
+		var field string
+		if requestErr.Parameter != nil {
+			field = requestErr.Parameter.Name
+			if errors.Is(requestErr.Err, openapi3filter.ErrInvalidRequired) {
+				errs.Add(field, i18n.Required, field)
+				continue
+			}
+		}
+
+		schemaErrs := schemaErrors(requestErr.Err)
+		if len(schemaErrs) == 0 && requestErr.Parameter != nil && requestErr.Parameter.Schema != nil {
+			// Parameters that cannot be parsed into the type of their schema
+			errs.Add(field, i18n.InvalidType, requestErr.Parameter.Schema.Value.Type)
+		} else if len(schemaErrs) == 0 {
+			return newProblem(http.StatusBadRequest, i18n.InvalidRequest)
+		}
+		for _, schemaErr := range schemaErrs {
+			errs = append(errs, schemaFieldErrors(field, schemaErr, checked)...)
+		}
+	}
+	return validationProblem(http.StatusBadRequest, errs...)
+}
+
+// Name of the property kin-openapi reports as not allowed
+var unsupportedProperty = regexp.MustCompile(`^property (".*") is unsupported$`)
+
+/*
+Failures of a value refused by api.yml, named by their path in the body or parameter.
+String constraints are explained by the rules FromOpenAPI derives so they are reported like handlers report them,
+once per field, checked keeps the fields already explained
+*/
+func schemaFieldErrors(parameter string, err *openapi3.SchemaError, checked map[string]bool) (errs validation.Errors) {
+	field := fieldPath(append([]string{parameter}, err.JSONPointer()...)...)
+	switch err.SchemaField {
+	case "required":
+		errs.Add(field, i18n.Required, field)
+	case "properties":
+		if match := unsupportedProperty.FindStringSubmatch(err.Reason); match != nil {
+			name, _ := strconv.Unquote(match[1])
+			errs.Add(fieldPath(field, name), i18n.UnknownField)
+		}
+	case "type", "nullable":
+		errs.Add(field, i18n.InvalidType, err.Schema.Type)
+	case "minLength", "maxLength", "pattern", "enum", "format":
+		value, isString := err.Value.(string)
+		if isString && checked[field] {
+			break
+		} else if isString {
+			checked[field] = true
+			errs = validation.Optional(field, validation.StringRules(err.Schema)...).Check(value)
+		}
+
+		switch {
+		case len(errs) != 0:
+		case err.SchemaField == "format":
+			errs.Add(field, i18n.InvalidFormat, err.Schema.Format)
+		case err.SchemaField == "enum":
+			values := make([]string, len(err.Schema.Enum))
+			for i, value := range err.Schema.Enum {
+				values[i] = fmt.Sprint(value)
+			}
+			errs.Add(field, i18n.OneOf, strings.Join(values, ", "))
+		default:
+			errs.Add(field, i18n.InvalidValue)
+		}
+	default:
+		errs.Add(field, i18n.InvalidValue)
+	}
+	return
+}
+
+// Schema errors of a cause returned by kin-openapi, in the order they were found
+func schemaErrors(err error) (errs []*openapi3.SchemaError) {
+	for _, err := range unwrapMultiError(err) {
+		var schemaErr *openapi3.SchemaError
+		if errors.As(err, &schemaErr) {
+			errs = append(errs, schemaErr)
+		}
+	}
+	return
+}
+
+// Errors of nested openapi3.MultiError flattened, causes of other errors are kept wrapped
+func unwrapMultiError(err error) (errs []error) {
+	multiErr, ok := err.(openapi3.MultiError)
+	if !ok {
+		return []error{err}
+	}
+	for _, err := range multiErr {
+		errs = append(errs, unwrapMultiError(err)...)
+	}
+	return
+}
+
+// Dotted path of a value, empty parts are skipped
+func fieldPath(parts ...string) string {
+	path := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part != "" {
+			path = append(path, part)
+		}
+	}
+	return strings.Join(path, ".")
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/AthanatiusC/SawitPro/generated"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+TestValidateContract Criteria:
+- Requests that do not match api.yml are refused before the handler with every invalid field
+- Content types the operation does not accept are refused with 415
+- Valid requests and routes api.yml does not declare reach the handler
+- With ValidateResponses, responses violating api.yml are logged and sent unchanged
+*/
+func TestValidateContract(t *testing.T) {
+	e := echo.New()
+	h := NewServer(NewServerOptions{})
+	e.HTTPErrorHandler = h.HTTPErrorHandler
+
+	var reached bool
+	serveContract := func(method, target, contentType, body string, handler echo.HandlerFunc) *httptest.ResponseRecorder {
+		reached = false
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set(echo.HeaderContentType, contentType)
+		}
+		rec := httptest.NewRecorder()
+		ctx := e.NewContext(req, rec)
+		if err := h.ValidateContract(func(ctx echo.Context) error {
+			reached = true
+			return handler(ctx)
+		})(ctx); err != nil {
+			e.HTTPErrorHandler(err, ctx)
+		}
+		return rec
+	}
+	noContent := func(ctx echo.Context) error { return ctx.NoContent(http.StatusNoContent) }
+
+	rec := serveContract(http.MethodPost, "/user", echo.MIMEApplicationJSON, `{"full_name":"Jo","password":1,"role":"admin"}`, noContent)
+	assert.False(t, reached)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	body := rec.Body.String()
+	assert.Contains(t, body, `"code":"required","field":"phone_number"`)
+	assert.Contains(t, body, `"code":"length_between","field":"full_name"`)
+	assert.Contains(t, body, `"code":"invalid_type","field":"password"`)
+
+	rec = serveContract(http.MethodPut, "/user/email", echo.MIMEApplicationJSON, `{"email":"John <john@example.com>"}`, noContent)
+	assert.False(t, reached)
+	assert.Contains(t, rec.Body.String(), `"code":"invalid_email","field":"email"`)
+
+	rec = serveContract(http.MethodGet, "/user/login-history?limit=abc", "", "", noContent)
+	assert.False(t, reached)
+	assert.Contains(t, rec.Body.String(), `"code":"invalid_type","field":"limit"`)
+
+	rec = serveContract(http.MethodPost, "/user", echo.MIMETextPlain, `full_name=John`, noContent)
+	assert.False(t, reached)
+	assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+
+	rec = serveContract(http.MethodPost, "/user", echo.MIMEApplicationJSON, `{"full_name":"John Doe","password":"Passw0rd!","phone_number":"081234567890"}`, noContent)
+	assert.True(t, reached)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	serveContract(http.MethodGet, "/unknown", "", "", noContent)
+	assert.True(t, reached)
+
+	var logs bytes.Buffer
+	e.Logger.SetOutput(&logs)
+	h.ValidateResponses = true
+	rec = serveContract(http.MethodGet, "/user", "", "", func(ctx echo.Context) error {
+		return ctx.JSON(http.StatusOK, generated.User{PhoneNumber: "+6281234567890"})
+	})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"phone_number":"+6281234567890"`)
+	assert.Contains(t, logs.String(), "GET /user response violates api.yml")
+
+	logs.Reset()
+	serveContract(http.MethodGet, "/user", "", "", func(ctx echo.Context) error {
+		return ctx.JSON(http.StatusOK, generated.User{FullName: "John Doe", PhoneNumber: "+6281234567890"})
+	})
+	assert.Empty(t, logs.String())
+}
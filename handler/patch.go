@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+
+	"github.com/AthanatiusC/SawitPro/generated"
+	"github.com/AthanatiusC/SawitPro/i18n"
+	"github.com/AthanatiusC/SawitPro/validation"
+	"github.com/labstack/echo/v4"
+)
+
+// Media type of JSON Merge Patch documents (RFC 7396)
+const mergePatchMediaType = "application/merge-patch+json"
+
+// Largest merge patch accepted, a profile patch is a few hundred bytes
+const maxMergePatchBytes = 64 << 10
+
+// (PATCH /user) Patch user endpoint, partially updates the profile with a JSON Merge Patch (RFC 7396).
+// Members left out are unchanged and null removes a member, which only locale and timezone allow
+func (s *Server) PatchUser(ctx echo.Context, params generated.PatchUserParams) error {
+	principal, err := s.Authorize(ctx.Request().Context(), params.Authorization, ScopeProfileWrite)
+	if err != nil {
+		return err
+	}
+	user := principal.User
+
+	if params.IfMatch != nil && !ifMatch(*params.IfMatch, userETag(user.Version)) {
+		return newProblem(http.StatusPreconditionFailed, i18n.UserModified)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(ctx.Request().Header.Get(echo.HeaderContentType))
+	if mediaType != mergePatchMediaType {
+		return newProblem(http.StatusUnsupportedMediaType, i18n.UnsupportedMediaType, mergePatchMediaType)
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(ctx.Response(), ctx.Request().Body, maxMergePatchBytes))
+	if err != nil {
+		return newProblem(http.StatusRequestEntityTooLarge, i18n.RequestTooLarge, maxMergePatchBytes)
+	}
+
+	// A patch that is not an object would replace the whole profile
+	var patch map[string]json.RawMessage
+	if err := json.Unmarshal(body, &patch); err != nil || patch == nil {
+		return validationProblem(http.StatusBadRequest, validation.NewError("request", i18n.MustBeObject))
+	}
+
+	fields := make([]string, 0, len(patch))
+	for field := range patch {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	var errors validation.Errors
+	values := make(map[string]string)
+	for _, field := range fields {
+		value, fieldErrors := s.validatePatchField(field, patch[field])
+		errors = append(errors, fieldErrors...)
+		values[field] = value
+	}
+	if len(errors) != 0 {
+		return validationProblem(http.StatusBadRequest, errors...)
+	}
+
+	if fullName, ok := values["full_name"]; ok {
+		user.Name = fullName
+	}
+
+	// Without a preference messages follow Accept-Language again
+	if preference, ok := values["locale"]; ok && preference == "" {
+		user.Locale = nil
+	} else if ok {
+		user.Locale = &preference
+	}
+
+	// Without a time zone clients show times in their own
+	if timezone, ok := values["timezone"]; ok && timezone == "" {
+		user.Timezone = nil
+	} else if ok {
+		user.Timezone = &timezone
+	}
+
+	if phoneNumber, ok := values["phone_number"]; ok && s.NormalizePhoneNumber(phoneNumber) != user.Phone {
+		phoneNumber = s.NormalizePhoneNumber(phoneNumber)
+		existingUser, err := s.Repository.GetUserByPhoneNumber(ctx.Request().Context(), phoneNumber)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		} else if existingUser.Id != 0 {
+			return newProblem(http.StatusBadRequest, i18n.PhoneNumberRegistered)
+		}
+		user.Phone = phoneNumber
+	}
+
+	return s.saveUser(ctx, principal.User, user, params.IfMatch)
+}
+
+// Decode a member of a profile merge patch and validate it with the registration rules,
+// a removed locale or time zone is returned as an empty value
+func (s *Server) validatePatchField(field string, raw json.RawMessage) (value string, errors validation.Errors) {
+	if field != "full_name" && field != "phone_number" && field != "locale" && field != "timezone" {
+		return "", validation.Errors{validation.NewError(field, i18n.UnknownField)}
+	}
+	if string(raw) == "null" && (field == "locale" || field == "timezone") {
+		return "", nil
+	} else if string(raw) == "null" {
+		return "", validation.Errors{validation.NewError(field, i18n.CannotBeRemoved)}
+	}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", validation.Errors{validation.NewError(field, i18n.MustBeString)}
+	}
+
+	if field == "locale" {
+		preference, err := localePreference(value)
+		if err != nil {
+			return "", validation.Errors{err}
+		}
+		return preference, nil
+	}
+
+	// Users.Validate only checks the fields of the struct it is given
+	switch field {
+	case "full_name":
+		return value, s.Users.Validate(struct {
+			FullName string `json:"full_name"`
+		}{value})
+	case "timezone":
+		return value, s.Users.Validate(struct {
+			Timezone string `json:"timezone"`
+		}{value})
+	}
+	return value, s.Users.Validate(struct {
+		PhoneNumber string `json:"phone_number"`
+	}{value})
+}
@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/AthanatiusC/SawitPro/generated"
+	"github.com/AthanatiusC/SawitPro/repository"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+TestPatchUser Criteria:
+- Members left out are unchanged
+- null, unknown members and invalid values are refused field by field
+- Content type must be application/merge-patch+json
+*/
+func TestPatchUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	e := echo.New()
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	h := NewServer(NewServerOptions{Repository: repo})
+
+	user := repository.User{Id: 1, Name: "user", Phone: "+6280000000000", Status: repository.UserStatusActive, Version: 2}
+	repo.EXPECT().GetUserById(gomock.Any(), user.Id).Return(user, nil).Times(4)
+	repo.EXPECT().UpdateUserById(gomock.Any(), repository.UpdateUserInput{Id: user.Id, Name: "new name", Phone: user.Phone, Version: 2}).
+		Return(repository.User{Name: "new name", Phone: user.Phone, Version: 3}, nil)
+
+	token, err := h.GenerateJWT(JWTClaims{UserId: user.Id})
+	if err != nil {
+		t.Error(err)
+	}
+	token = fmt.Sprintf("Bearer %s", token)
+
+	patch := func(contentType string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, "/user", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, contentType)
+		rec := httptest.NewRecorder()
+		assert.NoError(t, serve(h, e.NewContext(req, rec), func(ctx echo.Context) error {
+			return h.PatchUser(ctx, generated.PatchUserParams{Authorization: &token})
+		}))
+		return rec
+	}
+
+	rec := patch(mergePatchMediaType, `{"full_name":"new name"}`)
+	if assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String()) {
+		assert.Equal(t, `"3"`, rec.Header().Get("ETag"))
+		assert.Contains(t, rec.Body.String(), `"phone_number":"+6280000000000"`)
+	}
+
+	rec = patch(mergePatchMediaType, `{"full_name":null,"phone_number":"12","role":"admin"}`)
+	if assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String()) {
+		assert.Contains(t, rec.Body.String(), "full_name : cannot be removed")
+		assert.Contains(t, rec.Body.String(), "phone_number : must have 8 to 12 digits after +62")
+		assert.Contains(t, rec.Body.String(), "role : unknown field")
+	}
+
+	rec = patch(mergePatchMediaType, `"new name"`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+
+	rec = patch(echo.MIMEApplicationJSON, `{"full_name":"new name"}`)
+	assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code, rec.Body.String())
+}
+
+/*
+TestPatchUserTimezone Criteria:
+- An IANA time zone is stored and returned with the profile
+- null removes the time zone
+- Unknown time zones are refused
+*/
+func TestPatchUserTimezone(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	e := echo.New()
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	h := NewServer(NewServerOptions{Repository: repo})
+
+	jakarta := "Asia/Jakarta"
+	user := repository.User{Id: 1, Name: "user", Phone: "+6280000000000", Status: repository.UserStatusActive, Version: 2}
+	repo.EXPECT().GetUserById(gomock.Any(), user.Id).Return(user, nil).Times(2)
+	repo.EXPECT().UpdateUserById(gomock.Any(), repository.UpdateUserInput{Id: user.Id, Name: user.Name, Phone: user.Phone, Timezone: &jakarta, Version: 2}).
+		Return(repository.User{Name: user.Name, Phone: user.Phone, Timezone: &jakarta, Version: 3}, nil)
+
+	withTimezone := user
+	withTimezone.Timezone = &jakarta
+	repo.EXPECT().GetUserById(gomock.Any(), user.Id).Return(withTimezone, nil)
+	repo.EXPECT().UpdateUserById(gomock.Any(), repository.UpdateUserInput{Id: user.Id, Name: user.Name, Phone: user.Phone, Version: 2}).
+		Return(repository.User{Name: user.Name, Phone: user.Phone, Version: 3}, nil)
+
+	token, err := h.GenerateJWT(JWTClaims{UserId: user.Id})
+	if err != nil {
+		t.Error(err)
+	}
+	token = fmt.Sprintf("Bearer %s", token)
+
+	patch := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, "/user", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, mergePatchMediaType)
+		rec := httptest.NewRecorder()
+		assert.NoError(t, serve(h, e.NewContext(req, rec), func(ctx echo.Context) error {
+			return h.PatchUser(ctx, generated.PatchUserParams{Authorization: &token})
+		}))
+		return rec
+	}
+
+	rec := patch(`{"timezone":"Asia/Jakarta"}`)
+	if assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String()) {
+		assert.Contains(t, rec.Body.String(), `"timezone":"Asia/Jakarta"`)
+	}
+
+	rec = patch(`{"timezone":"Mars/Olympus"}`)
+	if assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String()) {
+		assert.Contains(t, rec.Body.String(), "timezone : must be an IANA time zone e.g Asia/Jakarta")
+	}
+
+	rec = patch(`{"timezone":null}`)
+	if assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String()) {
+		assert.NotContains(t, rec.Body.String(), "timezone")
+	}
+}
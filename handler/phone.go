@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/AthanatiusC/SawitPro/generated"
+	"github.com/AthanatiusC/SawitPro/i18n"
+	"github.com/AthanatiusC/SawitPro/notification"
+	"github.com/AthanatiusC/SawitPro/repository"
+	"github.com/labstack/echo/v4"
+)
+
+// Phone change codes are 6 digits, short lived and void after a few attempts
+const (
+	phoneChangeCodeDigits  = 6
+	phoneChangeTTL         = 10 * time.Minute
+	phoneChangeMaxAttempts = 5
+	phoneChangeResendDelay = time.Minute // least time between two codes sent for the same user
+)
+
+// Returned by startPhoneChange when a code was sent less than phoneChangeResendDelay ago
+var errPhoneChangeTooSoon = errors.New("phone change requested too soon")
+
+// (POST /user/phone-change/verify) Verify phone change endpoint, switches the phone number to the pending one
+// once the code sent to it is submitted, then tells the previous number about the change
+func (s *Server) VerifyPhoneChange(ctx echo.Context, params generated.VerifyPhoneChangeParams) error {
+	principal, err := s.AuthorizeOwner(ctx.Request().Context(), params.Authorization, ScopeProfileWrite)
+	if err != nil {
+		return err
+	}
+
+	var request generated.VerifyPhoneChangeJSONRequestBody
+	if err := ctx.Bind(&request); err != nil {
+		return err
+	}
+
+	change, err := s.Repository.GetPendingPhoneChange(ctx.Request().Context(), principal.User.Id)
+	if err == sql.ErrNoRows {
+		return newProblem(http.StatusNotFound, i18n.PhoneChangeNotPending)
+	} else if err != nil {
+		return err
+	}
+
+	if time.Now().After(change.ExpiresAt) {
+		return newProblem(http.StatusBadRequest, i18n.CodeExpired)
+	}
+
+	// The attempt is counted before the code is compared, so concurrent guesses cannot get past the limit
+	_, err = s.Repository.IncrementPhoneChangeAttempts(ctx.Request().Context(), change.Id, phoneChangeMaxAttempts)
+	if err == sql.ErrNoRows {
+		return newProblem(http.StatusBadRequest, i18n.CodeExpired)
+	} else if err != nil {
+		return err
+	}
+
+	if !hmac.Equal([]byte(s.hashPhoneChangeCode(change.UserId, change.Phone, request.Code)), []byte(change.CodeHash)) {
+		return newProblem(http.StatusBadRequest, i18n.IncorrectCode)
+	}
+
+	user, err := s.Repository.CompletePhoneChange(ctx.Request().Context(), change.Id)
+	if err == sql.ErrNoRows { // verified or cancelled by a concurrent request
+		return newProblem(http.StatusNotFound, i18n.PhoneChangeNotPending)
+	} else if err == repository.ErrConflict {
+		return newProblem(http.StatusBadRequest, i18n.PhoneNumberRegistered)
+	} else if err != nil {
+		return err
+	}
+
+	// The previous holder must learn about the change in case the session was hijacked, failing to notify is only logged
+	err = s.Notifier.Notify(ctx.Request().Context(), notification.Message{
+		UserId:  user.Id,
+		Phone:   principal.User.Phone,
+		Subject: "Your phone number was changed",
+		Body:    fmt.Sprintf("The phone number of your account was changed to one ending in %s. If this was not you, contact support immediately.", lastDigits(user.Phone, 4)),
+	})
+	if err != nil {
+		ctx.Logger().Errorf("failed to notify phone change: %v", err)
+	}
+
+	ctx.Response().Header().Set("ETag", userETag(user.Version))
+	return ctx.JSON(http.StatusOK, s.userProfile(user))
+}
+
+// (DELETE /user/phone-change) Cancel phone change endpoint, cancels the pending phone number change
+func (s *Server) CancelPhoneChange(ctx echo.Context, params generated.CancelPhoneChangeParams) error {
+	principal, err := s.Authorize(ctx.Request().Context(), params.Authorization, ScopeProfileWrite)
+	if err != nil {
+		return err
+	}
+
+	err = s.Repository.CancelPhoneChange(ctx.Request().Context(), principal.User.Id)
+	if err == sql.ErrNoRows {
+		return newProblem(http.StatusNotFound, i18n.PhoneChangeNotPending)
+	} else if err != nil {
+		return err
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+/*
+Record a pending change of the phone number of user and send a code to the new number.
+The number only changes once the code is submitted to VerifyPhoneChange, so a stolen session
+cannot move the account to another number
+*/
+func (s *Server) startPhoneChange(ctx echo.Context, user repository.User, phoneNumber string) (change repository.PhoneChange, err error) {
+	// The latest change counts whether pending, verified or cancelled, cancelling does not skip the delay
+	latest, err := s.Repository.GetLatestPhoneChange(ctx.Request().Context(), user.Id)
+	if err != nil && err != sql.ErrNoRows {
+		return
+	} else if err == nil && time.Until(latest.ExpiresAt) > phoneChangeTTL-phoneChangeResendDelay {
+		return change, errPhoneChangeTooSoon
+	}
+
+	code, err := generatePhoneChangeCode()
+	if err != nil {
+		return
+	}
+
+	change, err = s.Repository.CreatePhoneChange(ctx.Request().Context(), repository.CreatePhoneChangeInput{
+		UserId:    user.Id,
+		Phone:     phoneNumber,
+		CodeHash:  s.hashPhoneChangeCode(user.Id, phoneNumber, code),
+		ExpiresAt: time.Now().Add(phoneChangeTTL),
+	})
+	if err != nil {
+		return
+	}
+
+	// A change whose code never left cannot be verified, drop it so the user can ask again right away
+	if err = s.OTPSender.SendOTP(ctx.Request().Context(), phoneNumber, code); err != nil {
+		if deleteErr := s.Repository.DeletePhoneChange(ctx.Request().Context(), change.Id); deleteErr != nil {
+			ctx.Logger().Errorf("failed to delete phone change: %v", deleteErr)
+		}
+		return
+	}
+
+	return change, nil
+}
+
+// Generate a random numeric code of phoneChangeCodeDigits digits, leading zeros included
+func generatePhoneChangeCode() (string, error) {
+	max := big.NewInt(1)
+	for i := 0; i < phoneChangeCodeDigits; i++ {
+		max.Mul(max, big.NewInt(10))
+	}
+
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", phoneChangeCodeDigits, n.Int64()), nil
+}
+
+// Hash a phone change code for storage, keyed with the server secret since a 6 digit code is trivial to brute force
+// from a plain hash, and bound to the user and number so a code cannot be replayed for another change
+func (s *Server) hashPhoneChangeCode(userId int, phoneNumber string, code string) string {
+	mac := hmac.New(sha256.New, []byte(s.JWTSecret))
+	mac.Write([]byte(strconv.Itoa(userId) + "\n" + phoneNumber + "\n" + code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Last n digits of a phone number, enough for the user to recognise it without disclosing it
+func lastDigits(phone string, n int) string {
+	if len(phone) > n {
+		return phone[len(phone)-n:]
+	}
+	return phone
+}
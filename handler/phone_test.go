@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AthanatiusC/SawitPro/generated"
+	"github.com/AthanatiusC/SawitPro/repository"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// Keeps the last code sent instead of sending it
+type otpRecorder struct {
+	phone string
+	code  string
+}
+
+func (s *otpRecorder) SendOTP(ctx context.Context, phone string, code string) error {
+	s.phone, s.code = phone, code
+	return nil
+}
+
+/*
+TestVerifyPhoneChange Criteria:
+- Another code cannot be requested right after one was sent
+- Wrong code is counted and refused
+- Right code is refused once the attempts are used up
+- Right code switches the number and notifies the previous one
+- Expired change cannot be verified
+*/
+func TestVerifyPhoneChange(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	e := echo.New()
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	notifier := &recordingNotifier{}
+	h := NewServer(NewServerOptions{Repository: repo, Notifier: notifier, Secret: "secret"})
+
+	user := repository.User{Id: 1, Name: "user", Phone: "+6280000000000", Status: repository.UserStatusActive, Version: 1}
+	change := repository.PhoneChange{
+		Id:        3,
+		UserId:    user.Id,
+		Phone:     "+6281234567890",
+		CodeHash:  h.hashPhoneChangeCode(user.Id, "+6281234567890", "123456"),
+		ExpiresAt: time.Now().Add(phoneChangeTTL),
+	}
+	expired := change
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+
+	repo.EXPECT().GetUserById(gomock.Any(), user.Id).Return(user, nil).Times(5)
+	repo.EXPECT().GetUserByPhoneNumber(gomock.Any(), change.Phone).Return(repository.User{}, sql.ErrNoRows)
+	repo.EXPECT().GetLatestPhoneChange(gomock.Any(), user.Id).Return(change, nil)
+	gomock.InOrder(
+		repo.EXPECT().GetPendingPhoneChange(gomock.Any(), user.Id).Return(change, nil).Times(3),
+		repo.EXPECT().GetPendingPhoneChange(gomock.Any(), user.Id).Return(expired, nil),
+	)
+	gomock.InOrder(
+		repo.EXPECT().IncrementPhoneChangeAttempts(gomock.Any(), change.Id, phoneChangeMaxAttempts).Return(1, nil),
+		repo.EXPECT().IncrementPhoneChangeAttempts(gomock.Any(), change.Id, phoneChangeMaxAttempts).Return(0, sql.ErrNoRows),
+		repo.EXPECT().IncrementPhoneChangeAttempts(gomock.Any(), change.Id, phoneChangeMaxAttempts).Return(2, nil),
+	)
+	repo.EXPECT().CompletePhoneChange(gomock.Any(), change.Id).
+		Return(repository.User{Id: user.Id, Name: user.Name, Phone: change.Phone, Version: 2}, nil)
+
+	token, err := h.GenerateJWT(JWTClaims{UserId: user.Id})
+	if err != nil {
+		t.Error(err)
+	}
+	token = fmt.Sprintf("Bearer %s", token)
+
+	req := httptest.NewRequest(http.MethodPut, "/user", strings.NewReader(`{"phone_number":"+6281234567890"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	assert.NoError(t, serve(h, e.NewContext(req, rec), func(ctx echo.Context) error {
+		return h.UpdateUser(ctx, generated.UpdateUserParams{Authorization: &token})
+	}))
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code, rec.Body.String())
+
+	verify := func(code string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/user/phone-change/verify", strings.NewReader(fmt.Sprintf(`{"code":"%s"}`, code)))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		assert.NoError(t, serve(h, e.NewContext(req, rec), func(ctx echo.Context) error {
+			return h.VerifyPhoneChange(ctx, generated.VerifyPhoneChangeParams{Authorization: &token})
+		}))
+		return rec
+	}
+
+	rec = verify("654321")
+	assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"code":"incorrect_code"`)
+
+	rec = verify("123456")
+	assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"code":"code_expired"`)
+
+	rec = verify("123456")
+	if assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String()) {
+		assert.Contains(t, rec.Body.String(), `"phone_number":"+6281234567890"`)
+		assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+		if assert.Len(t, notifier.messages, 1) {
+			assert.Equal(t, user.Phone, notifier.messages[0].Phone)
+			assert.Contains(t, notifier.messages[0].Body, "7890")
+		}
+	}
+
+	rec = verify("123456")
+	assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+}
+
+// Fails every send, as when the SMS gateway is down
+type failingOTPSender struct{}
+
+func (failingOTPSender) SendOTP(ctx context.Context, phone string, code string) error {
+	return errors.New("gateway unavailable")
+}
+
+/*
+TestPhoneChangeResendDelay Criteria:
+- Cancelling a change keeps it, another code cannot be requested until the delay since it is over
+- A change whose code could not be sent is deleted so it does not delay the next request
+*/
+func TestPhoneChangeResendDelay(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	e := echo.New()
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	h := NewServer(NewServerOptions{Repository: repo, OTPSender: failingOTPSender{}, Secret: "secret"})
+
+	user := repository.User{Id: 1, Name: "user", Phone: "+6280000000000", Status: repository.UserStatusActive, Version: 1}
+	repo.EXPECT().GetUserById(gomock.Any(), user.Id).Return(user, nil).AnyTimes()
+	repo.EXPECT().GetUserByPhoneNumber(gomock.Any(), "+6281234567890").Return(repository.User{}, sql.ErrNoRows).AnyTimes()
+
+	now := time.Now()
+	cancelled := repository.PhoneChange{Id: 3, UserId: user.Id, Phone: "+6281234567890", ExpiresAt: now.Add(phoneChangeTTL), CancelledAt: &now}
+	earlier := cancelled
+	earlier.ExpiresAt = now.Add(phoneChangeTTL - 2*phoneChangeResendDelay)
+	gomock.InOrder(
+		repo.EXPECT().CancelPhoneChange(gomock.Any(), user.Id).Return(nil),
+		repo.EXPECT().GetLatestPhoneChange(gomock.Any(), user.Id).Return(cancelled, nil),
+		repo.EXPECT().GetLatestPhoneChange(gomock.Any(), user.Id).Return(earlier, nil),
+		repo.EXPECT().CreatePhoneChange(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, input repository.CreatePhoneChangeInput) (repository.PhoneChange, error) {
+				return repository.PhoneChange{Id: 4, UserId: user.Id, Phone: input.Phone, CodeHash: input.CodeHash, ExpiresAt: input.ExpiresAt}, nil
+			}),
+		repo.EXPECT().DeletePhoneChange(gomock.Any(), 4).Return(nil),
+	)
+
+	token, err := h.GenerateJWT(JWTClaims{UserId: user.Id})
+	if err != nil {
+		t.Error(err)
+	}
+	token = fmt.Sprintf("Bearer %s", token)
+
+	req := httptest.NewRequest(http.MethodDelete, "/user/phone-change", nil)
+	rec := httptest.NewRecorder()
+	assert.NoError(t, serve(h, e.NewContext(req, rec), func(ctx echo.Context) error {
+		return h.CancelPhoneChange(ctx, generated.CancelPhoneChangeParams{Authorization: &token})
+	}))
+	assert.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+
+	update := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/user", strings.NewReader(`{"phone_number":"+6281234567890"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		assert.NoError(t, serve(h, e.NewContext(req, rec), func(ctx echo.Context) error {
+			return h.UpdateUser(ctx, generated.UpdateUserParams{Authorization: &token})
+		}))
+		return rec
+	}
+
+	rec = update()
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code, rec.Body.String())
+
+	rec = update()
+	assert.Equal(t, http.StatusInternalServerError, rec.Code, rec.Body.String())
+}
@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/AthanatiusC/SawitPro/generated"
+	"github.com/AthanatiusC/SawitPro/i18n"
+	"github.com/AthanatiusC/SawitPro/repository"
+	"github.com/AthanatiusC/SawitPro/validation"
+	"github.com/labstack/echo/v4"
+)
+
+// Media type of every error response (RFC 7807)
+const problemMediaType = "application/problem+json"
+
+// Error returned by handlers for a failure the client can act on, written by HTTPErrorHandler
+type problem struct {
+	Status int
+	Code   i18n.Code
+	Args   []interface{}
+	Errors validation.Errors // validation failures, returned as the errors member
+}
+
+func newProblem(status int, code i18n.Code, args ...interface{}) *problem {
+	return &problem{Status: status, Code: code, Args: args}
+}
+
+// Problem of a request refused by validation, the failures are returned in the order they were found
+func validationProblem(status int, errs ...*validation.Error) *problem {
+	return &problem{Status: status, Code: i18n.ValidationFailed, Errors: errs}
+}
+
+func (p *problem) Error() string {
+	return i18n.Message(i18n.English, p.Code, p.Args...)
+}
+
+/*
+Error handler of the service, register as echo HTTPErrorHandler. Every error becomes an
+application/problem+json response:
+- problem returned by handlers
+- typed errors of Authenticate and Authorize, refused with 403
+- repository errors handlers let through, sql.ErrNoRows is 404, ErrConflict and ErrVersionMismatch are 409
+- echo.HTTPError of routing, binding and parameter parsing
+- anything else is logged and hidden behind a 500
+
+The correlation id is the X-Request-ID of the request, quote it to find the logs of a failure
+*/
+func (s *Server) HTTPErrorHandler(err error, ctx echo.Context) {
+	if ctx.Response().Committed {
+		return
+	}
+	if err := s.problemResponse(ctx, err); err != nil {
+		ctx.Logger().Errorf("failed to write error response: %v", err)
+	}
+}
+
+// Write err as a problem, see HTTPErrorHandler
+func (s *Server) problemResponse(ctx echo.Context, err error) error {
+	correlationId := ctx.Response().Header().Get(echo.HeaderXRequestID)
+	if correlationId == "" {
+		correlationId = generateRequestId()
+		ctx.Response().Header().Set(echo.HeaderXRequestID, correlationId)
+	}
+
+	p := toProblem(err)
+	if p.Status >= http.StatusInternalServerError {
+		ctx.Logger().Errorf("%s %s failed, correlation id %s: %v", ctx.Request().Method, ctx.Request().URL.Path, correlationId, err)
+	}
+
+	locale := requestLocale(ctx)
+	response := generated.Problem{
+		Type:          "/problems/" + string(p.Code),
+		Title:         http.StatusText(p.Status),
+		Status:        p.Status,
+		Detail:        i18n.Message(locale, p.Code, p.Args...),
+		Instance:      ctx.Request().URL.Path,
+		Code:          string(p.Code),
+		CorrelationId: correlationId,
+	}
+	if len(p.Errors) != 0 {
+		errors, messages := localizeErrors(p.Errors, locale)
+		response.Errors, response.Messages = &errors, &messages
+	}
+
+	body, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	if ctx.Request().Method == http.MethodHead {
+		return ctx.NoContent(p.Status)
+	}
+	return ctx.Blob(p.Status, problemMediaType, body)
+}
+
+// Resolve the problem an error stands for
+func toProblem(err error) *problem {
+	var p *problem
+	var httpErr *echo.HTTPError
+	switch {
+	case errors.As(err, &p):
+		return p
+	case errors.Is(err, ErrUnauthorized):
+		return newProblem(http.StatusForbidden, i18n.Unauthorized)
+	case errors.Is(err, ErrInsufficientScope):
+		return newProblem(http.StatusForbidden, i18n.InsufficientScope)
+	case errors.Is(err, ErrForbidden):
+		return newProblem(http.StatusForbidden, i18n.Forbidden)
+	case errors.Is(err, sql.ErrNoRows):
+		return newProblem(http.StatusNotFound, i18n.NotFound)
+	case errors.Is(err, repository.ErrConflict), errors.Is(err, repository.ErrVersionMismatch):
+		return newProblem(http.StatusConflict, i18n.Conflict)
+	case errors.As(err, &httpErr):
+		return httpProblem(httpErr)
+	default:
+		return newProblem(http.StatusInternalServerError, i18n.InternalError)
+	}
+}
+
+// Problem of an error raised by echo, its message is English and often leaks internals so only the status is kept
+func httpProblem(err *echo.HTTPError) *problem {
+	switch {
+	case err.Code == http.StatusNotFound:
+		return newProblem(err.Code, i18n.NotFound)
+	case err.Code == http.StatusMethodNotAllowed:
+		return newProblem(err.Code, i18n.MethodNotAllowed)
+	case err.Code == http.StatusUnsupportedMediaType:
+		return newProblem(err.Code, i18n.UnsupportedMediaType, echo.MIMEApplicationJSON)
+	case err.Code == http.StatusUnauthorized || err.Code == http.StatusForbidden:
+		return newProblem(err.Code, i18n.Forbidden)
+	case err.Code >= http.StatusBadRequest && err.Code < http.StatusInternalServerError:
+		return newProblem(err.Code, i18n.InvalidRequest)
+	default:
+		return newProblem(http.StatusInternalServerError, i18n.InternalError)
+	}
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/AthanatiusC/SawitPro/generated"
+	"github.com/AthanatiusC/SawitPro/i18n"
+	"github.com/AthanatiusC/SawitPro/validation"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+TestHTTPErrorHandler Criteria:
+- Errors are written as application/problem+json with the X-Request-ID as correlation id
+- Validation problems list their failures in errors and messages
+- Bind failures and typed errors map to their status, other errors are a 500 that leaks nothing
+*/
+func TestHTTPErrorHandler(t *testing.T) {
+	e := echo.New()
+	h := NewServer(NewServerOptions{})
+	e.HTTPErrorHandler = h.HTTPErrorHandler
+
+	handle := func(err error) (*httptest.ResponseRecorder, generated.Problem) {
+		req := httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(`{"full_name":`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderXRequestID, "request-1")
+		rec := httptest.NewRecorder()
+		ctx := e.NewContext(req, rec)
+		ctx.Error(h.AuditContext(func(echo.Context) error { return err })(ctx))
+
+		var problem generated.Problem
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem), rec.Body.String())
+		return rec, problem
+	}
+
+	rec, problem := handle(newProblem(http.StatusNotFound, i18n.UserNotFound))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, problemMediaType, rec.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, generated.Problem{
+		Type:          "/problems/user_not_found",
+		Title:         "Not Found",
+		Status:        http.StatusNotFound,
+		Detail:        "user not found",
+		Instance:      "/user",
+		Code:          "user_not_found",
+		CorrelationId: "request-1",
+	}, problem)
+
+	rec, problem = handle(validationProblem(http.StatusBadRequest, validation.NewError("full_name", i18n.Required, "full_name")))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "validation_failed", problem.Code)
+	if assert.NotNil(t, problem.Errors) && assert.Len(t, *problem.Errors, 1) {
+		assert.Equal(t, "required", (*problem.Errors)[0].Code)
+		assert.Equal(t, []string{"full_name : full_name is required"}, *problem.Messages)
+	}
+
+	var request generated.RegisterJSONBody
+	req := httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(`{"full_name":`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	bindErr := e.NewContext(req, httptest.NewRecorder()).Bind(&request)
+	rec, problem = handle(bindErr)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "invalid_request", problem.Code)
+
+	rec, problem = handle(ErrInsufficientScope)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Equal(t, "insufficient_scope", problem.Code)
+
+	rec, problem = handle(errors.New("pq: connection refused"))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, "internal_error", problem.Code)
+	assert.NotContains(t, rec.Body.String(), "connection refused")
+}
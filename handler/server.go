@@ -1,22 +1,110 @@
 package handler
 
 import (
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/AthanatiusC/SawitPro/generated"
+	"github.com/AthanatiusC/SawitPro/notification"
+	"github.com/AthanatiusC/SawitPro/phonenumber"
 	"github.com/AthanatiusC/SawitPro/repository"
+	"github.com/AthanatiusC/SawitPro/storage"
+	"github.com/AthanatiusC/SawitPro/validation"
+	"github.com/getkin/kin-openapi/routers"
 )
 
 type Server struct {
-	Repository repository.RepositoryInterface
-	JWTSecret  string
+	Repository           repository.RepositoryInterface
+	JWTSecret            string
+	IntrospectionClients map[string]string // client id to client secret allowed to call /oauth/introspect
+	Notifier             notification.Notifier
+	OTPSender            notification.OTPSender
+	Mailer               notification.Mailer
+	EmailVerificationURL string              // page the emailed link opens, it submits the token to POST /user/email/verify
+	PhoneNumbers         *phonenumber.Parser // parses numbers of the allowed regions into E.164
+	DeletionGracePeriod  time.Duration       // time a deleted account can be restored before it is purged
+	Users                validation.Schema   // rules of the fields users send, see userSchema
+	ValidateResponses    bool                // log responses that violate api.yml, see ValidateContract
+	Blobs                storage.BlobStore   // avatars and their thumbnails
+
+	contract routers.Router // operations of api.yml requests are validated against
 }
 
 type NewServerOptions struct {
-	Repository repository.RepositoryInterface
-	Secret     string
+	Repository           repository.RepositoryInterface
+	Secret               string
+	IntrospectionClients map[string]string
+	Notifier             notification.Notifier  // optional, messages are dropped when nil
+	OTPSender            notification.OTPSender // optional, codes are logged when nil
+	Mailer               notification.Mailer    // optional, emails are logged when nil
+	EmailVerificationURL string                 // optional, defaults to defaultEmailVerificationURL
+	PhoneNumbers         *phonenumber.Parser    // optional, defaults to Indonesian numbers only
+	DeletionGracePeriod  time.Duration          // optional, defaults to 30 days
+	ValidateResponses    bool                   // optional, for development, responses are checked against api.yml
+	Blobs                storage.BlobStore      // optional, defaults to files in the temporary directory
 }
 
 func NewServer(opts NewServerOptions) *Server {
+	var notifier notification.Notifier = notification.NopNotifier{}
+	if opts.Notifier != nil {
+		notifier = opts.Notifier
+	}
+
+	var otpSender notification.OTPSender = notification.NewLogOTPSender(log.Default())
+	if opts.OTPSender != nil {
+		otpSender = opts.OTPSender
+	}
+
+	var mailer notification.Mailer = notification.NewLogMailer(log.Default())
+	if opts.Mailer != nil {
+		mailer = opts.Mailer
+	}
+
+	emailVerificationURL := defaultEmailVerificationURL
+	if opts.EmailVerificationURL != "" {
+		emailVerificationURL = opts.EmailVerificationURL
+	}
+
+	phoneNumbers := opts.PhoneNumbers
+	if phoneNumbers == nil {
+		phoneNumbers, _ = phonenumber.NewParser("ID") // Ignore error because value is hardcoded
+	}
+
+	deletionGracePeriod := defaultDeletionGracePeriod
+	if opts.DeletionGracePeriod != 0 {
+		deletionGracePeriod = opts.DeletionGracePeriod
+	}
+
+	var blobs storage.BlobStore = storage.NewLocalStore(filepath.Join(os.TempDir(), "sawitpro-blobs"), "/blobs")
+	if opts.Blobs != nil {
+		blobs = opts.Blobs
+	}
+
+	// The spec is embedded by oapi-codegen, it only fails to load or validate when generated is out of date
+	spec, err := generated.GetSwagger()
+	if err != nil {
+		panic(err)
+	}
+	contract, err := newContractRouter(spec)
+	if err != nil {
+		panic(err)
+	}
+
 	return &Server{
-		Repository: opts.Repository,
-		JWTSecret:  opts.Secret,
+		Repository:           opts.Repository,
+		JWTSecret:            opts.Secret,
+		IntrospectionClients: opts.IntrospectionClients,
+		Notifier:             notifier,
+		OTPSender:            otpSender,
+		Mailer:               mailer,
+		EmailVerificationURL: emailVerificationURL,
+		PhoneNumbers:         phoneNumbers,
+		DeletionGracePeriod:  deletionGracePeriod,
+		Users:                userSchema(spec, phoneNumbers),
+		ValidateResponses:    opts.ValidateResponses,
+		Blobs:                blobs,
+		contract:             contract,
 	}
 }
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/AthanatiusC/SawitPro/generated"
+	"github.com/AthanatiusC/SawitPro/i18n"
+	"github.com/labstack/echo/v4"
+)
+
+// (GET /user/sessions) List sessions endpoint, returns every device the user is signed in on
+func (s *Server) ListSessions(ctx echo.Context, params generated.ListSessionsParams) error {
+	principal, err := s.Authorize(ctx.Request().Context(), params.Authorization, ScopeProfileRead)
+	if err != nil {
+		return err
+	}
+
+	sessions, err := s.Repository.ListSessionsByUserId(ctx.Request().Context(), principal.User.Id)
+	if err != nil {
+		return err
+	}
+
+	response := generated.SessionListResponse{Sessions: []generated.Session{}}
+	for _, session := range sessions {
+		response.Sessions = append(response.Sessions, generated.Session{
+			Id:         session.Id,
+			DeviceName: session.DeviceName,
+			UserAgent:  session.UserAgent,
+			Ip:         session.Ip,
+			Current:    session.Id == principal.Claims.SessionId,
+			LastSeenAt: session.LastSeenAt,
+			CreatedAt:  session.CreatedAt,
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, response)
+}
+
+// (DELETE /user/sessions/{id}) Revoke session endpoint, tokens issued with the session are refused from then on
+func (s *Server) RevokeSession(ctx echo.Context, id int, params generated.RevokeSessionParams) error {
+	principal, err := s.Authorize(ctx.Request().Context(), params.Authorization, ScopeProfileWrite)
+	if err != nil {
+		return err
+	}
+
+	err = s.Repository.RevokeSession(ctx.Request().Context(), principal.User.Id, id)
+	if err == sql.ErrNoRows {
+		return newProblem(http.StatusNotFound, i18n.SessionNotFound)
+	} else if err != nil {
+		return err
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
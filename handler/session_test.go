@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/AthanatiusC/SawitPro/generated"
+	"github.com/AthanatiusC/SawitPro/repository"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+TestListSessions Criteria:
+- Valid JWT issued with a session
+- Session of the request token is flagged current
+- Token of a revoked session is refused
+*/
+func TestListSessions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	e := echo.New()
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	h := NewServer(NewServerOptions{Repository: repo})
+
+	user := repository.User{Id: 1, Status: repository.UserStatusActive}
+	now := time.Now()
+	repo.EXPECT().GetUserById(gomock.Any(), user.Id).Return(user, nil).Times(2)
+	repo.EXPECT().GetSessionById(gomock.Any(), 10).Return(repository.Session{Id: 10, UserId: user.Id}, nil)
+	repo.EXPECT().GetSessionById(gomock.Any(), 11).Return(repository.Session{Id: 11, UserId: user.Id, RevokedAt: &now}, nil)
+	repo.EXPECT().TouchSession(gomock.Any(), 10).Return(nil)
+	repo.EXPECT().ListSessionsByUserId(gomock.Any(), user.Id).Return([]repository.Session{{Id: 10, UserId: user.Id}, {Id: 12, UserId: user.Id}}, nil)
+
+	listSessions := func(sessionId int) *httptest.ResponseRecorder {
+		token, err := h.GenerateJWT(JWTClaims{UserId: user.Id, SessionId: sessionId})
+		if err != nil {
+			t.Error(err)
+		}
+		token = fmt.Sprintf("Bearer %s", token)
+
+		req := httptest.NewRequest(http.MethodGet, "/user/sessions", nil)
+		rec := httptest.NewRecorder()
+		assert.NoError(t, serve(h, e.NewContext(req, rec), func(ctx echo.Context) error {
+			return h.ListSessions(ctx, generated.ListSessionsParams{Authorization: &token})
+		}))
+		return rec
+	}
+
+	rec := listSessions(10)
+	if assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String()) {
+		var response generated.SessionListResponse
+		json.Unmarshal(rec.Body.Bytes(), &response)
+
+		assert.Len(t, response.Sessions, 2)
+		assert.True(t, response.Sessions[0].Current)
+		assert.False(t, response.Sessions[1].Current)
+	}
+
+	rec = listSessions(11)
+	assert.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+}
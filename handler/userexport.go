@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AthanatiusC/SawitPro/generated"
+	"github.com/AthanatiusC/SawitPro/i18n"
+	"github.com/AthanatiusC/SawitPro/repository"
+	"github.com/labstack/echo/v4"
+)
+
+// Formats of the user export
+const (
+	userExportCSV    = "csv"
+	userExportNDJSON = "ndjson"
+)
+
+// Users written between two flushes of the response
+const userExportFlushSize = 500
+
+// Columns of the csv export, the password is never exported. An attributes.<name> column follows for every attribute
+// definition, in the format the import reads them
+var userExportColumns = []string{"id", "full_name", "phone_number", "role", "status", "deleted_at", "updated_at", "created_at"}
+
+// First characters of a cell spreadsheets read as a formula, such cells are exported behind a single quote so
+// a name like =HYPERLINK(...) is shown as text instead of being run when the file is opened
+const csvFormulaPrefixes = "=+-@\t\r"
+
+// (GET /admin/users/export) Export users endpoint, admin only, streams every user matching the listing filters as csv or ndjson.
+// Users are written as they are read from the database, a failure midway truncates the export and is only logged
+func (s *Server) ExportUsers(ctx echo.Context, params generated.ExportUsersParams) error {
+	_, err := s.AuthorizeAdmin(ctx.Request().Context(), params.Authorization)
+	if err != nil {
+		return err
+	}
+
+	input, errors, err := s.listUsersInput(ctx.Request().Context(), generated.ListUsersParams{
+		Name:          params.Name,
+		Phone:         params.Phone,
+		CreatedAfter:  params.CreatedAfter,
+		CreatedBefore: params.CreatedBefore,
+		UpdatedAfter:  params.UpdatedAfter,
+		UpdatedBefore: params.UpdatedBefore,
+		Status:        params.Status,
+		Role:          params.Role,
+		Attribute:     params.Attribute,
+		Sort:          params.Sort,
+	})
+	if err != nil {
+		return err
+	}
+	input.Limit = 0 // the export is never paginated
+	format := userExportCSV
+	if params.Format != nil {
+		format = *params.Format
+	}
+	if format != userExportCSV && format != userExportNDJSON {
+		errors.Add("format", i18n.OneOf, "csv, ndjson")
+	}
+	if len(errors) != 0 {
+		return validationProblem(http.StatusBadRequest, errors...)
+	}
+
+	response := ctx.Response()
+	response.Header().Set(echo.HeaderContentDisposition, `attachment; filename="users.`+format+`"`)
+	response.Header().Set("Cache-Control", "no-store")
+
+	var write func(user repository.User) error
+	var flush func() error
+	if format == userExportCSV {
+		definitions, err := s.Repository.ListAttributeDefinitions(ctx.Request().Context())
+		if err != nil {
+			return err
+		}
+		columns := append([]string{}, userExportColumns...)
+		for _, definition := range definitions {
+			columns = append(columns, importAttributePrefix+definition.Name)
+		}
+
+		response.Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+		writer := csv.NewWriter(response)
+		write = func(user repository.User) error {
+			record := userExportRecord(user, definitions)
+			for i, cell := range record {
+				record[i] = escapeCSVCell(cell)
+			}
+			return writer.Write(record)
+		}
+		flush = func() error {
+			writer.Flush()
+			return writer.Error()
+		}
+		response.WriteHeader(http.StatusOK)
+		writer.Write(columns) // buffered, a write error surfaces on flush
+	} else {
+		response.Header().Set(echo.HeaderContentType, "application/x-ndjson")
+		encoder := json.NewEncoder(response)
+		write = func(user repository.User) error {
+			return encoder.Encode(adminUserResponse(user))
+		}
+		flush = func() error { return nil }
+		response.WriteHeader(http.StatusOK)
+	}
+
+	count := 0
+	err = s.Repository.ExportUsers(ctx.Request().Context(), input, func(user repository.User) error {
+		if err := write(user); err != nil {
+			return err
+		}
+		count++
+		if count%userExportFlushSize == 0 {
+			if err := flush(); err != nil {
+				return err
+			}
+			response.Flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		ctx.Logger().Errorf("user export stopped after %d users: %v", count, err)
+	}
+
+	response.Flush()
+	return nil
+}
+
+func userExportRecord(user repository.User, definitions []repository.AttributeDefinition) []string {
+	deletedAt := ""
+	if user.DeletedAt != nil {
+		deletedAt = user.DeletedAt.Format(time.RFC3339)
+	}
+
+	record := []string{
+		strconv.Itoa(user.Id),
+		user.Name,
+		user.Phone,
+		user.Role,
+		user.EffectiveStatus(),
+		deletedAt,
+		user.UpdatedAt.Format(time.RFC3339),
+		user.CreatedAt.Format(time.RFC3339),
+	}
+	for _, definition := range definitions {
+		record = append(record, formatAttribute(user.Attributes[definition.Name]))
+	}
+	return record
+}
+
+// Quote a cell starting like a formula
+func escapeCSVCell(cell string) string {
+	if cell != "" && strings.ContainsRune(csvFormulaPrefixes, rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+// Undo escapeCSVCell so an exported file can be imported again
+func unescapeCSVCell(cell string) string {
+	if len(cell) > 1 && cell[0] == '\'' && strings.ContainsRune(csvFormulaPrefixes, rune(cell[1])) {
+		return cell[1:]
+	}
+	return cell
+}
+
+// Write an attribute value the way parseAttribute reads it back, unset values are empty
+func formatAttribute(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	default:
+		return fmt.Sprint(value)
+	}
+}
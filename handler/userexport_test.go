@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AthanatiusC/SawitPro/generated"
+	"github.com/AthanatiusC/SawitPro/repository"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+TestExportUsers Criteria:
+- Users are streamed as csv with a header row, or as ndjson
+- Listing filters are passed to the repository
+- Password is never exported
+- Cells starting like a spreadsheet formula are quoted
+- Custom attributes are exported as one attributes.<name> column per definition
+*/
+func TestExportUsers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	e := echo.New()
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	h := NewServer(NewServerOptions{Repository: repo})
+
+	admin := repository.User{Id: 1, Status: repository.UserStatusActive, Role: repository.RoleAdmin}
+	repo.EXPECT().GetUserById(gomock.Any(), admin.Id).Return(admin, nil).Times(2)
+
+	createdAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	users := []repository.User{
+		{Id: 2, Name: "Budi Santoso", Phone: "+6281234567890", Password: "secret-hash", Role: repository.RoleUser, Status: repository.UserStatusActive, UpdatedAt: createdAt, CreatedAt: createdAt,
+			Attributes: repository.Attributes{"estate": "Riau 2", "grade": float64(1200000), "contractor": true}},
+		{Id: 3, Name: "=HYPERLINK(\"http://evil.example\",\"Siti\")", Phone: "+6281234567891", Password: "secret-hash", Role: repository.RoleUser, Status: repository.UserStatusActive, DeletedAt: &createdAt, UpdatedAt: createdAt, CreatedAt: createdAt},
+	}
+	repo.EXPECT().ListAttributeDefinitions(gomock.Any()).Return([]repository.AttributeDefinition{
+		{Name: "contractor", Type: repository.AttributeTypeBoolean},
+		{Name: "estate", Type: repository.AttributeTypeString},
+		{Name: "grade", Type: repository.AttributeTypeNumber},
+	}, nil)
+	repo.EXPECT().ExportUsers(gomock.Any(), repository.ListUsersInput{Role: repository.RoleUser, SortBy: repository.UserSortCreatedAt}, gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ repository.ListUsersInput, each func(repository.User) error) error {
+			for _, user := range users {
+				if err := each(user); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Times(2)
+
+	token, err := h.GenerateJWT(JWTClaims{UserId: admin.Id})
+	if err != nil {
+		t.Error(err)
+	}
+	token = fmt.Sprintf("Bearer %s", token)
+
+	export := func(format string) *httptest.ResponseRecorder {
+		role := repository.RoleUser
+		req := httptest.NewRequest(http.MethodGet, "/admin/users/export", nil)
+		rec := httptest.NewRecorder()
+		assert.NoError(t, serve(h, e.NewContext(req, rec), func(ctx echo.Context) error {
+			return h.ExportUsers(ctx, generated.ExportUsersParams{Format: &format, Role: &role, Authorization: &token})
+		}))
+		assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.NotContains(t, rec.Body.String(), "secret-hash")
+		return rec
+	}
+
+	rec := export(userExportCSV)
+	assert.Equal(t, strings.Join([]string{
+		"id,full_name,phone_number,role,status,deleted_at,updated_at,created_at,attributes.contractor,attributes.estate,attributes.grade",
+		"2,Budi Santoso,'+6281234567890,user,active,,2024-01-01T00:00:00Z,2024-01-01T00:00:00Z,true,Riau 2,1200000",
+		`3,"'=HYPERLINK(""http://evil.example"",""Siti"")",'+6281234567891,user,deleted,2024-01-01T00:00:00Z,2024-01-01T00:00:00Z,2024-01-01T00:00:00Z,,,`,
+		"",
+	}, "\n"), rec.Body.String())
+
+	rec = export(userExportNDJSON)
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	if assert.Len(t, lines, 2) {
+		assert.Contains(t, lines[0], `"full_name":"Budi Santoso"`)
+		assert.Contains(t, lines[1], `"status":"deleted"`)
+	}
+}
+
+/*
+TestEscapeCSVCell Criteria:
+- Cells starting with =, +, -, @, tab or carriage return are prefixed with a single quote
+- Unescaping gives the cell back, other cells are left alone both ways
+*/
+func TestEscapeCSVCell(t *testing.T) {
+	testCases := []struct {
+		cell    string
+		escaped string
+	}{
+		{"=1+1", "'=1+1"},
+		{"+6281234567890", "'+6281234567890"},
+		{"-5", "'-5"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\t=1+1", "'\t=1+1"},
+		{"\r=1+1", "'\r=1+1"},
+		{"Budi Santoso", "Budi Santoso"},
+		{"'quoted", "'quoted"},
+		{"", ""},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.escaped, escapeCSVCell(tc.cell), tc.cell)
+		assert.Equal(t, tc.cell, unescapeCSVCell(tc.escaped), tc.escaped)
+	}
+}
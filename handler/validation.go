@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/AthanatiusC/SawitPro/generated"
+	"github.com/AthanatiusC/SawitPro/i18n"
+	"github.com/AthanatiusC/SawitPro/phonenumber"
+	"github.com/AthanatiusC/SawitPro/validation"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/labstack/echo/v4"
+)
+
+/*
+Rules of the fields users send, shared by every endpoint carrying them.
+  - lengths and formats are derived from the request bodies of Register and Update Email in api.yml
+  - phone numbers follow the regions of phoneNumbers, stored normalized by NormalizePhoneNumber
+  - passwords follow the password policy, which a single pattern cannot express without lookaround
+  - time zones are checked against the IANA database the server embeds
+*/
+func userSchema(spec *openapi3.T, phoneNumbers *phonenumber.Parser) validation.Schema {
+	return validation.Schema{
+		validation.Required("phone_number", validation.Phone(phoneNumbers)),
+		validation.Required("password", validation.Password()),
+		validation.Optional("timezone", validation.Timezone()),
+	}.
+		Merge(validation.FromOpenAPI(requestSchema(spec, "/user", http.MethodPost))).
+		Merge(validation.FromOpenAPI(requestSchema(spec, "/user/email", http.MethodPut)))
+}
+
+// Schema of the json request body of an operation of api.yml, nil when it has none
+func requestSchema(spec *openapi3.T, path string, method string) *openapi3.Schema {
+	item := spec.Paths.Find(path)
+	if item == nil || item.GetOperation(method) == nil || item.GetOperation(method).RequestBody == nil {
+		return nil
+	}
+	media := item.GetOperation(method).RequestBody.Value.Content.Get(echo.MIMEApplicationJSON)
+	if media == nil || media.Schema == nil {
+		return nil
+	}
+	return media.Schema.Value
+}
+
+/*
+Localize validation failures.
+  - errors has one entry per failure with its stable code and named params
+  - messages keeps the "field : message" strings of older clients, failures of a field are joined in one
+    message placed where the field first failed
+*/
+func localizeErrors(e validation.Errors, locale i18n.Locale) (errors []generated.ValidationError, messages []string) {
+	errors = make([]generated.ValidationError, len(e))
+	var fields []string
+	fieldMessages := make(map[string][]string)
+	for i, err := range e {
+		message := i18n.Message(locale, err.Code, err.Args...)
+		errors[i] = generated.ValidationError{
+			Field:   err.Field,
+			Code:    string(err.Code),
+			Message: message,
+			Params:  i18n.Params(err.Code, err.Args...),
+		}
+
+		if _, ok := fieldMessages[err.Field]; !ok {
+			fields = append(fields, err.Field)
+		}
+		fieldMessages[err.Field] = append(fieldMessages[err.Field], message)
+	}
+
+	messages = make([]string, len(fields))
+	for i, field := range fields {
+		messages[i] = fmt.Sprintf("%s : %s", field, strings.Join(fieldMessages[field], ", "))
+	}
+	return
+}
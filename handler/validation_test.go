@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/AthanatiusC/SawitPro/generated"
+	"github.com/AthanatiusC/SawitPro/i18n"
+	"github.com/AthanatiusC/SawitPro/repository"
+	"github.com/AthanatiusC/SawitPro/validation"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+TestValidationSchema Criteria:
+- Lengths and formats of the user fields are derived from api.yml
+- Schemas compose, merged rules of a field run in order and custom functions are rules
+- Only and Optional narrow a schema for endpoints accepting some fields
+- Pattern and enum constraints of api.yml become rules
+*/
+func TestValidationSchema(t *testing.T) {
+	h := NewServer(NewServerOptions{})
+
+	errors := h.Users.Validate(struct {
+		Email string `json:"email"`
+	}{strings.Repeat("a", 250) + "@b.co"})
+	if assert.Len(t, errors, 1) {
+		assert.Equal(t, validation.NewError("email", i18n.LengthBetween, 3, 254), errors[0])
+	}
+
+	notAdmin := func(value string, report validation.Report) {
+		if strings.EqualFold(value, "admin") {
+			report(i18n.UnknownField)
+		}
+	}
+	schema := h.Users.Merge(validation.Schema{validation.Required("full_name", notAdmin)})
+	errors = schema.Validate(struct {
+		FullName string `json:"full_name"`
+	}{"Admin"})
+	assert.Equal(t, validation.Errors{validation.NewError("full_name", i18n.UnknownField)}, errors)
+
+	profile := h.Users.Only("full_name", "phone_number").Optional()
+	assert.Empty(t, profile.Validate(generated.User{Email: new(string)}))
+	assert.Len(t, profile.Validate(generated.User{FullName: "Jo"}), 1)
+
+	maxLength := uint64(2)
+	derived := validation.FromOpenAPI(&openapi3.Schema{
+		Required: []string{"code"},
+		Properties: openapi3.Schemas{
+			"code": {Value: &openapi3.Schema{Type: openapi3.TypeString, Pattern: `^[A-Z]+$`, MaxLength: &maxLength}},
+			"kind": {Value: &openapi3.Schema{Type: openapi3.TypeString, Enum: []interface{}{"a", "b"}}},
+		},
+	})
+	errors = derived.Validate(struct {
+		Code *string `json:"code"`
+		Kind string  `json:"kind"`
+	}{nil, "c"})
+	assert.Equal(t, validation.Errors{
+		validation.NewError("code", i18n.Required, "code"),
+		validation.NewError("kind", i18n.OneOf, "a, b"),
+	}, errors)
+	code := "abc"
+	errors = derived.Validate(struct {
+		Code *string `json:"code"`
+	}{&code})
+	assert.Equal(t, validation.Errors{
+		validation.NewError("code", i18n.MaxLength, 2),
+		validation.NewError("code", i18n.Pattern, `^[A-Z]+$`),
+	}, errors)
+}
+
+/*
+TestUpdateUserValidation Criteria:
+- Fields sent to update the profile follow the registration rules
+*/
+func TestUpdateUserValidation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	e := echo.New()
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	h := NewServer(NewServerOptions{Repository: repo})
+
+	user := repository.User{Id: 1, Name: "old name", Phone: "+6280000000000", Status: repository.UserStatusActive}
+	repo.EXPECT().GetUserById(gomock.Any(), user.Id).Return(user, nil)
+	token, err := h.GenerateJWT(JWTClaims{UserId: user.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token = "Bearer " + token
+
+	req := httptest.NewRequest(http.MethodPut, "/user", strings.NewReader(`{"full_name":"Jo","phone_number":"+1555"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	if assert.NoError(t, serve(h, e.NewContext(req, rec), func(ctx echo.Context) error {
+		return h.UpdateUser(ctx, generated.UpdateUserParams{Authorization: &token})
+	})) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"code":"length_between","field":"full_name"`)
+		assert.Contains(t, rec.Body.String(), `"code":"phone_country_code","field":"phone_number"`)
+	}
+}
@@ -0,0 +1,130 @@
+// This file contains the localization layer.
+// Messages shown to users are looked up by a stable code in the catalog of the locale of the request.
+package i18n
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Locale is the primary language subtag of a BCP 47 language tag, e.g id for id-ID
+type Locale string
+
+const (
+	English    Locale = "en"
+	Indonesian Locale = "id"
+)
+
+// Locale of requests that accept none of the supported locales
+const DefaultLocale = English
+
+// Locales with a catalog, in order of preference when a client accepts several with the same weight
+var Locales = []Locale{English, Indonesian}
+
+// Parse a language tag into a supported locale, e.g id-ID or ID are Indonesian
+func Parse(tag string) (locale Locale, ok bool) {
+	primary, _, _ := strings.Cut(strings.TrimSpace(tag), "-")
+	locale = Locale(strings.ToLower(primary))
+	for _, supported := range Locales {
+		if supported == locale {
+			return locale, true
+		}
+	}
+	return "", false
+}
+
+/*
+Pick the supported locale a client prefers from an Accept-Language header (RFC 9110 12.5.4).
+Tags are weighed by their q value, 1 when missing, and q=0 refuses a tag. Only the primary
+subtag is matched, en-GB is English. Falls back to DefaultLocale when nothing matches
+*/
+func Negotiate(acceptLanguage string) Locale {
+	type candidate struct {
+		locale Locale
+		weight float64
+	}
+
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		weight := 1.0
+		if params = strings.TrimSpace(params); strings.HasPrefix(params, "q=") {
+			parsed, err := strconv.ParseFloat(strings.TrimPrefix(params, "q="), 64)
+			if err != nil {
+				continue
+			}
+			weight = parsed
+		}
+		if weight <= 0 {
+			continue
+		}
+
+		if strings.TrimSpace(tag) == "*" {
+			candidates = append(candidates, candidate{DefaultLocale, weight})
+		} else if locale, ok := Parse(tag); ok {
+			candidates = append(candidates, candidate{locale, weight})
+		}
+	}
+	if len(candidates) == 0 {
+		return DefaultLocale
+	}
+
+	// Stable so the first listed tag wins between equal weights
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].weight > candidates[j].weight
+	})
+	return candidates[0].locale
+}
+
+// Message of code in locale formatted with args, falls back to English then to the code itself
+// so a missing translation never hides the error
+func Message(locale Locale, code Code, args ...interface{}) string {
+	format, ok := catalogs[locale][code]
+	if !ok {
+		format, ok = catalogs[English][code]
+	}
+	if !ok {
+		return string(code)
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// Arguments of a message keyed by their name, empty when code takes none
+func Params(code Code, args ...interface{}) map[string]interface{} {
+	params := make(map[string]interface{}, len(args))
+	for i, name := range paramNames[code] {
+		if i < len(args) {
+			params[name] = args[i]
+		}
+	}
+	return params
+}
+
+type contextKey struct{}
+
+// Attach the locale of a request to its context, a pointer so it can be switched once the user is known
+func WithLocale(ctx context.Context, locale *Locale) context.Context {
+	return context.WithValue(ctx, contextKey{}, locale)
+}
+
+// Locale attached to the context by WithLocale, ok is false when there is none
+func FromContext(ctx context.Context) (locale Locale, ok bool) {
+	pointer, _ := ctx.Value(contextKey{}).(*Locale)
+	if pointer == nil {
+		return "", false
+	}
+	return *pointer, true
+}
+
+// Switch the locale attached to the context, does nothing when there is none
+func SetLocale(ctx context.Context, locale Locale) {
+	if pointer, _ := ctx.Value(contextKey{}).(*Locale); pointer != nil {
+		*pointer = locale
+	}
+}
@@ -0,0 +1,123 @@
+package i18n
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+TestNegotiate Criteria:
+- The supported locale with the highest q value wins, the first listed one between equal weights
+- Region subtags and case are ignored, q=0 refuses a tag and * is the default locale
+- Headers matching no supported locale fall back to the default locale
+*/
+func TestNegotiate(t *testing.T) {
+	testCases := map[string]Locale{
+		"":                          DefaultLocale,
+		"id":                        Indonesian,
+		"ID-id":                     Indonesian,
+		"id-ID,en;q=0.8":            Indonesian,
+		"en-GB,id;q=0.9":            English,
+		"fr, id;q=0.5, en;q=0.4":    Indonesian,
+		"en;q=0.5, id;q=0.9":        Indonesian,
+		"id;q=0.5, en;q=0.5":        Indonesian,
+		"en;q=0.5, id;q=0.5":        English,
+		"id;q=0, en;q=0.1":          English,
+		"id;q=0":                    DefaultLocale,
+		"*;q=0.9, id;q=0.8":         English,
+		"fr-FR, de":                 DefaultLocale,
+		"id;q=abc, en":              English,
+		"  id-ID  ;  q=1 ,  en  ":   Indonesian,
+		"ja,  *;q=0.1, id;q=0.05  ": English,
+	}
+	for header, expected := range testCases {
+		assert.Equal(t, expected, Negotiate(header), header)
+	}
+}
+
+/*
+TestParse Criteria:
+- Only the primary subtag of supported locales is matched, case insensitively
+*/
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		tag    string
+		locale Locale
+		ok     bool
+	}{
+		{"id", Indonesian, true},
+		{"id-ID", Indonesian, true},
+		{" EN-us ", English, true},
+		{"fr", "", false},
+		{"", "", false},
+	}
+	for _, tc := range testCases {
+		locale, ok := Parse(tc.tag)
+		assert.Equal(t, tc.locale, locale, tc.tag)
+		assert.Equal(t, tc.ok, ok, tc.tag)
+	}
+}
+
+/*
+TestMessage Criteria:
+- Messages are formatted with their args in the requested locale
+- Missing translations fall back to English, then to the code itself
+*/
+func TestMessage(t *testing.T) {
+	assert.Equal(t, "user not found", Message(English, UserNotFound))
+	assert.Equal(t, "pengguna tidak ditemukan", Message(Indonesian, UserNotFound))
+	assert.Equal(t, "harus lebih dari 3 dan kurang dari 60 karakter", Message(Indonesian, LengthBetween, 3, 60))
+
+	const untranslated Code = "untranslated"
+	catalogs[English][untranslated] = "only in %s"
+	defer delete(catalogs[English], untranslated)
+	assert.Equal(t, "only in English", Message(Indonesian, untranslated, "English"))
+	assert.Equal(t, "only in English", Message("fr", untranslated, "English"))
+
+	assert.Equal(t, "unknown_code", Message(Indonesian, "unknown_code"))
+}
+
+/*
+TestCatalogs Criteria:
+- Every English message is translated and takes the same arguments in every locale
+*/
+func TestCatalogs(t *testing.T) {
+	for _, locale := range Locales {
+		for code, english := range catalogs[English] {
+			message, ok := catalogs[locale][code]
+			if assert.True(t, ok, "%s misses %s", locale, code) {
+				assert.Equal(t, strings.Count(english, "%"), strings.Count(message, "%"), "%s of %s", code, locale)
+			}
+		}
+	}
+}
+
+/*
+TestParams Criteria:
+- Arguments are named after the params of their code, extra arguments are dropped
+*/
+func TestParams(t *testing.T) {
+	assert.Equal(t, map[string]interface{}{"min": 3, "max": 60}, Params(LengthBetween, 3, 60, 90))
+	assert.Equal(t, map[string]interface{}{"min": 3}, Params(LengthBetween, 3))
+	assert.Equal(t, map[string]interface{}{}, Params(UserNotFound))
+}
+
+/*
+TestContextLocale Criteria:
+- The locale attached to a context can be read and switched, contexts without one are left alone
+*/
+func TestContextLocale(t *testing.T) {
+	_, ok := FromContext(context.Background())
+	assert.False(t, ok)
+	SetLocale(context.Background(), Indonesian)
+
+	locale := English
+	ctx := WithLocale(context.Background(), &locale)
+	SetLocale(ctx, Indonesian)
+	got, ok := FromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, Indonesian, got)
+}
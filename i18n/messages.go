@@ -0,0 +1,299 @@
+package i18n
+
+// Code identifies a message, codes are part of the API and never change once released, messages may
+type Code string
+
+// Errors returned as a whole response
+const (
+	InternalError             Code = "internal_error"
+	InvalidRequest            Code = "invalid_request"
+	ValidationFailed          Code = "validation_failed"
+	NotFound                  Code = "not_found"
+	MethodNotAllowed          Code = "method_not_allowed"
+	Conflict                  Code = "conflict"
+	EmptyRequest              Code = "empty_request"
+	Unauthorized              Code = "unauthorized"
+	InsufficientScope         Code = "insufficient_scope"
+	Forbidden                 Code = "forbidden"
+	UnsupportedMediaType      Code = "unsupported_media_type"
+	RequestTooLarge           Code = "request_too_large"
+	UserNotFound              Code = "user_not_found"
+	UserModified              Code = "user_modified"
+	UserModifiedRetry         Code = "user_modified_retry"
+	UserStatusChanged         Code = "user_status_changed"
+	StatusTransitionInvalid   Code = "status_transition_invalid"
+	CannotChangeOwnStatus     Code = "cannot_change_own_status"
+	PhoneNumberRegistered     Code = "phone_number_registered"
+	EmailRegistered           Code = "email_registered"
+	EmailAlreadyVerified      Code = "email_already_verified"
+	UserHasNoEmail            Code = "user_has_no_email"
+	IncorrectPhoneCredentials Code = "incorrect_phone_credentials"
+	IncorrectEmailCredentials Code = "incorrect_email_credentials"
+	AccountSuspended          Code = "account_suspended"
+	AccountDisabled           Code = "account_disabled"
+	PhoneChangeNotPending     Code = "phone_change_not_pending"
+	IncorrectCode             Code = "incorrect_code"
+	CodeExpired               Code = "code_expired"
+	CodeTooSoon               Code = "code_too_soon"
+	LinkInvalid               Code = "link_invalid"
+	LinkTooSoon               Code = "link_too_soon"
+	DataExportNotFound        Code = "data_export_not_found"
+	DataExportNotAvailable    Code = "data_export_not_available"
+	DataExportInProgress      Code = "data_export_in_progress"
+	ApiKeyNotFound            Code = "api_key_not_found"
+	SessionNotFound           Code = "session_not_found"
+	InvalidClientCredentials  Code = "invalid_client_credentials"
+	CannotImpersonateSelf     Code = "cannot_impersonate_self"
+	AdminNotImpersonable      Code = "admin_not_impersonable"
+	AttributeNotFound         Code = "attribute_not_found"
+	AttributeNotUnique        Code = "attribute_not_unique"
+)
+
+// Errors of a single field, prefixed with the field name in validation responses
+const (
+	Required         Code = "required"
+	LengthBetween    Code = "length_between"
+	LengthRange      Code = "length_range"
+	MaxLength        Code = "max_length"
+	MinLength        Code = "min_length"
+	Pattern          Code = "pattern"
+	InvalidType      Code = "invalid_type"
+	InvalidFormat    Code = "invalid_format"
+	InvalidValue     Code = "invalid_value"
+	InvalidTimezone  Code = "invalid_timezone"
+	ImageType        Code = "image_type"
+	ImageDimensions  Code = "image_dimensions"
+	FileTooLarge     Code = "file_too_large"
+	Between          Code = "between"
+	OneOf            Code = "one_of"
+	PasswordSpecial  Code = "password_special"
+	PasswordCapital  Code = "password_capital"
+	PasswordNumber   Code = "password_number"
+	InvalidEmail     Code = "invalid_email"
+	PhoneCharacters  Code = "phone_characters"
+	PhoneCountryCode Code = "phone_country_code"
+	PhoneLength      Code = "phone_length"
+	CannotBeSentWith Code = "cannot_be_sent_with"
+	UnknownField     Code = "unknown_field"
+	CannotBeRemoved  Code = "cannot_be_removed"
+	MustBeString     Code = "must_be_string"
+	MustBeObject     Code = "must_be_object"
+	InvalidCursor    Code = "invalid_cursor"
+	MustBeFuture     Code = "must_be_future"
+	MustBeAfter      Code = "must_be_after"
+	MustBeUserId     Code = "must_be_user_id"
+	UnknownScope     Code = "unknown_scope"
+	InvalidSort      Code = "invalid_sort"
+	ImportTooLarge   Code = "import_too_large"
+	HeaderRequired   Code = "header_required"
+	ColumnRequired   Code = "column_required"
+	ColumnCount      Code = "column_count"
+	InvalidFile      Code = "invalid_file"
+	DuplicateRow     Code = "duplicate_row"
+	RowNotCreated    Code = "row_not_created"
+	AttributeTaken   Code = "attribute_taken"
+)
+
+// Names of the arguments of field codes in order, they are returned as the params of validation errors
+// so clients can build their own message. Like codes they never change once released
+var paramNames = map[Code][]string{
+	Required:         {"field"},
+	LengthBetween:    {"min", "max"},
+	LengthRange:      {"min", "max"},
+	MaxLength:        {"max"},
+	MinLength:        {"min"},
+	Pattern:          {"pattern"},
+	InvalidType:      {"type"},
+	InvalidFormat:    {"format"},
+	ImageType:        {"types"},
+	ImageDimensions:  {"min", "max"},
+	FileTooLarge:     {"max_bytes"},
+	Between:          {"min", "max"},
+	OneOf:            {"values"},
+	PhoneCountryCode: {"country_codes"},
+	PhoneLength:      {"min", "max", "calling_code", "region"},
+	CannotBeSentWith: {"field"},
+	MustBeAfter:      {"field"},
+	UnknownScope:     {"scope"},
+	ImportTooLarge:   {"max_bytes", "max_rows"},
+	ColumnRequired:   {"column"},
+	ColumnCount:      {"expected", "actual"},
+	InvalidFile:      {"format"},
+	DuplicateRow:     {"row"},
+}
+
+// Messages are fmt formats, translations must keep the verbs of the English message in the same order
+var catalogs = map[Locale]map[Code]string{
+	English: {
+		InternalError:             "something went wrong",
+		InvalidRequest:            "request is invalid",
+		ValidationFailed:          "request has invalid fields, see errors",
+		NotFound:                  "resource not found",
+		MethodNotAllowed:          "method not allowed",
+		Conflict:                  "resource was modified meanwhile, try again",
+		EmptyRequest:              "request cannot be empty",
+		Unauthorized:              "unauthorized",
+		InsufficientScope:         "insufficient scope",
+		Forbidden:                 "forbidden",
+		UnsupportedMediaType:      "content type must be %s",
+		RequestTooLarge:           "request must be less than %d bytes",
+		UserNotFound:              "user not found",
+		UserModified:              "user was modified, fetch it again",
+		UserModifiedRetry:         "user was modified meanwhile, try again",
+		UserStatusChanged:         "user status changed meanwhile, try again",
+		StatusTransitionInvalid:   "cannot change status from %s to %s",
+		CannotChangeOwnStatus:     "cannot change your own status",
+		PhoneNumberRegistered:     "phone number is already registered",
+		EmailRegistered:           "email is already registered",
+		EmailAlreadyVerified:      "email is already verified",
+		UserHasNoEmail:            "user has no email",
+		IncorrectPhoneCredentials: "incorrect password or phone number",
+		IncorrectEmailCredentials: "incorrect password or email",
+		AccountSuspended:          "account is suspended",
+		AccountDisabled:           "account is disabled",
+		PhoneChangeNotPending:     "no phone number change is pending",
+		IncorrectCode:             "incorrect code",
+		CodeExpired:               "code expired, request the change again",
+		CodeTooSoon:               "a code was sent less than a minute ago, try again later",
+		LinkInvalid:               "link is invalid or expired, request a new one",
+		LinkTooSoon:               "a link was sent less than a minute ago, try again later",
+		DataExportNotFound:        "data export not found",
+		DataExportNotAvailable:    "data export is not available",
+		DataExportInProgress:      "an export is already in progress",
+		ApiKeyNotFound:            "api key not found",
+		SessionNotFound:           "session not found",
+		InvalidClientCredentials:  "invalid client credentials",
+		CannotImpersonateSelf:     "cannot impersonate yourself",
+		AdminNotImpersonable:      "admins cannot be impersonated",
+		AttributeNotFound:         "attribute not found",
+		AttributeNotUnique:        "users already share values of the attribute, it cannot be unique",
+
+		Required:         "%s is required",
+		LengthBetween:    "must be more than %d and less than %d characters long",
+		LengthRange:      "must be between %d and %d characters long",
+		MaxLength:        "must be less than %d characters long",
+		MinLength:        "must be at least %d characters long",
+		Pattern:          "must match %s",
+		InvalidType:      "must be a %s",
+		InvalidFormat:    "must be a valid %s",
+		InvalidValue:     "is invalid",
+		InvalidTimezone:  "must be an IANA time zone e.g Asia/Jakarta",
+		ImageType:        "must be a %s image",
+		ImageDimensions:  "must be %d to %d pixels wide and high",
+		FileTooLarge:     "must be less than %d bytes",
+		Between:          "must be between %v and %v",
+		OneOf:            "must be one of %s",
+		PasswordSpecial:  "at least 1 special character",
+		PasswordCapital:  "at least 1 capital character",
+		PasswordNumber:   "at least 1 number",
+		InvalidEmail:     "must be a valid email address",
+		PhoneCharacters:  "must only contain digits, spaces, dashes, dots or brackets after an optional +",
+		PhoneCountryCode: "country code must be one of %s",
+		PhoneLength:      "must have %d to %d digits after +%s for %s, without leading 0",
+		CannotBeSentWith: "cannot be sent with %s",
+		UnknownField:     "unknown field",
+		CannotBeRemoved:  "cannot be removed",
+		MustBeString:     "must be a string",
+		MustBeObject:     "must be a json object",
+		InvalidCursor:    "invalid cursor",
+		MustBeFuture:     "must be in the future",
+		MustBeAfter:      "must be after %s",
+		MustBeUserId:     "must be a user id",
+		UnknownScope:     "unknown scope %s",
+		InvalidSort:      "must be created_at, updated_at or name, prefixed with - for descending order",
+		ImportTooLarge:   "must be less than %d bytes and %d rows",
+		HeaderRequired:   "header row is required",
+		ColumnRequired:   "%s column is required",
+		ColumnCount:      "expected %d columns, got %d",
+		InvalidFile:      "must be a valid %s file",
+		DuplicateRow:     "duplicate of row %d",
+		RowNotCreated:    "something went wrong, the row was not created",
+		AttributeTaken:   "is already used by another user",
+	},
+	Indonesian: {
+		InternalError:             "terjadi kesalahan, silakan coba lagi",
+		InvalidRequest:            "permintaan tidak valid",
+		ValidationFailed:          "permintaan berisi field yang tidak valid, lihat errors",
+		NotFound:                  "data tidak ditemukan",
+		MethodNotAllowed:          "metode tidak diizinkan",
+		Conflict:                  "data berubah saat diproses, silakan coba lagi",
+		EmptyRequest:              "permintaan tidak boleh kosong",
+		Unauthorized:              "tidak memiliki otorisasi",
+		InsufficientScope:         "cakupan akses tidak mencukupi",
+		Forbidden:                 "akses ditolak",
+		UnsupportedMediaType:      "tipe konten harus %s",
+		RequestTooLarge:           "permintaan harus kurang dari %d byte",
+		UserNotFound:              "pengguna tidak ditemukan",
+		UserModified:              "data pengguna telah berubah, muat ulang terlebih dahulu",
+		UserModifiedRetry:         "data pengguna berubah saat diproses, silakan coba lagi",
+		UserStatusChanged:         "status pengguna berubah saat diproses, silakan coba lagi",
+		StatusTransitionInvalid:   "status tidak dapat diubah dari %s ke %s",
+		CannotChangeOwnStatus:     "tidak dapat mengubah status akun sendiri",
+		PhoneNumberRegistered:     "nomor telepon sudah terdaftar",
+		EmailRegistered:           "email sudah terdaftar",
+		EmailAlreadyVerified:      "email sudah terverifikasi",
+		UserHasNoEmail:            "pengguna belum memiliki email",
+		IncorrectPhoneCredentials: "kata sandi atau nomor telepon salah",
+		IncorrectEmailCredentials: "kata sandi atau email salah",
+		AccountSuspended:          "akun sedang ditangguhkan",
+		AccountDisabled:           "akun telah dinonaktifkan",
+		PhoneChangeNotPending:     "tidak ada perubahan nomor telepon yang menunggu verifikasi",
+		IncorrectCode:             "kode salah",
+		CodeExpired:               "kode sudah kedaluwarsa, ajukan perubahan kembali",
+		CodeTooSoon:               "kode sudah dikirim kurang dari semenit yang lalu, coba lagi nanti",
+		LinkInvalid:               "tautan tidak valid atau sudah kedaluwarsa, minta tautan baru",
+		LinkTooSoon:               "tautan sudah dikirim kurang dari semenit yang lalu, coba lagi nanti",
+		DataExportNotFound:        "ekspor data tidak ditemukan",
+		DataExportNotAvailable:    "ekspor data tidak tersedia",
+		DataExportInProgress:      "ekspor data sedang diproses",
+		ApiKeyNotFound:            "api key tidak ditemukan",
+		SessionNotFound:           "sesi tidak ditemukan",
+		InvalidClientCredentials:  "kredensial klien tidak valid",
+		CannotImpersonateSelf:     "tidak dapat bertindak sebagai diri sendiri",
+		AdminNotImpersonable:      "tidak dapat bertindak sebagai admin",
+		AttributeNotFound:         "atribut tidak ditemukan",
+		AttributeNotUnique:        "beberapa pengguna sudah memiliki nilai atribut yang sama, atribut tidak dapat dibuat unik",
+
+		Required:         "%s wajib diisi",
+		LengthBetween:    "harus lebih dari %d dan kurang dari %d karakter",
+		LengthRange:      "harus antara %d sampai %d karakter",
+		MaxLength:        "harus kurang dari %d karakter",
+		MinLength:        "harus minimal %d karakter",
+		Pattern:          "harus sesuai dengan pola %s",
+		InvalidType:      "harus berupa %s",
+		InvalidFormat:    "harus berupa %s yang valid",
+		InvalidValue:     "tidak valid",
+		InvalidTimezone:  "harus berupa zona waktu IANA, misalnya Asia/Jakarta",
+		ImageType:        "harus berupa gambar %s",
+		ImageDimensions:  "lebar dan tinggi harus %d sampai %d piksel",
+		FileTooLarge:     "harus kurang dari %d byte",
+		Between:          "harus antara %v sampai %v",
+		OneOf:            "harus salah satu dari %s",
+		PasswordSpecial:  "minimal 1 karakter khusus",
+		PasswordCapital:  "minimal 1 huruf kapital",
+		PasswordNumber:   "minimal 1 angka",
+		InvalidEmail:     "harus berupa alamat email yang valid",
+		PhoneCharacters:  "hanya boleh berisi angka, spasi, tanda hubung, titik atau kurung setelah tanda + opsional",
+		PhoneCountryCode: "kode negara harus salah satu dari %s",
+		PhoneLength:      "harus %d sampai %d digit setelah +%s untuk %s, tanpa awalan 0",
+		CannotBeSentWith: "tidak boleh dikirim bersama %s",
+		UnknownField:     "field tidak dikenal",
+		CannotBeRemoved:  "tidak dapat dihapus",
+		MustBeString:     "harus berupa teks",
+		MustBeObject:     "harus berupa objek json",
+		InvalidCursor:    "cursor tidak valid",
+		MustBeFuture:     "harus di masa mendatang",
+		MustBeAfter:      "harus setelah %s",
+		MustBeUserId:     "harus berupa id pengguna",
+		UnknownScope:     "scope %s tidak dikenal",
+		InvalidSort:      "harus created_at, updated_at atau name, diawali - untuk urutan menurun",
+		ImportTooLarge:   "harus kurang dari %d byte dan %d baris",
+		HeaderRequired:   "baris header wajib ada",
+		ColumnRequired:   "kolom %s wajib ada",
+		ColumnCount:      "seharusnya %d kolom, terdapat %d",
+		InvalidFile:      "harus berupa file %s yang valid",
+		DuplicateRow:     "duplikat dari baris %d",
+		RowNotCreated:    "terjadi kesalahan, baris tidak dibuat",
+		AttributeTaken:   "sudah digunakan oleh pengguna lain",
+	},
+}
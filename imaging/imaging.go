@@ -0,0 +1,58 @@
+// This file contains image processing.
+// Only what avatars need is implemented: square thumbnails encoded as JPEG, on the standard library alone.
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"io"
+)
+
+// Square thumbnail of the center of src at most size pixels wide, smaller images are not enlarged.
+// Pixels are averaged over the area they cover so downscaled images keep their detail without aliasing
+func Thumbnail(src image.Image, size int) *image.RGBA {
+	bounds := src.Bounds()
+	side := bounds.Dx()
+	if bounds.Dy() < side {
+		side = bounds.Dy()
+	}
+	if size > side {
+		size = side
+	}
+
+	// Center square converted once, draw has fast paths for the decoded formats
+	crop := image.NewRGBA(image.Rect(0, 0, side, side))
+	origin := image.Pt(bounds.Min.X+(bounds.Dx()-side)/2, bounds.Min.Y+(bounds.Dy()-side)/2)
+	draw.Draw(crop, crop.Bounds(), src, origin, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		y0, y1 := y*side/size, (y+1)*side/size
+		for x := 0; x < size; x++ {
+			x0, x1 := x*side/size, (x+1)*side/size
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				row := crop.Pix[sy*crop.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r, g, b, a = r+uint64(p[0]), g+uint64(p[1]), b+uint64(p[2]), a+uint64(p[3])
+					n++
+				}
+			}
+			i := y*dst.Stride + x*4
+			dst.Pix[i], dst.Pix[i+1], dst.Pix[i+2], dst.Pix[i+3] = uint8(r/n), uint8(g/n), uint8(b/n), uint8(a/n)
+		}
+	}
+	return dst
+}
+
+// Encode img as a JPEG, transparent pixels are laid on white since JPEG has no alpha
+func EncodeJPEG(w io.Writer, img image.Image) error {
+	opaque := image.NewRGBA(img.Bounds())
+	draw.Draw(opaque, opaque.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(opaque, opaque.Bounds(), img, img.Bounds().Min, draw.Over)
+	return jpeg.Encode(w, opaque, &jpeg.Options{Quality: 85})
+}
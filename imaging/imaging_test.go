@@ -0,0 +1,92 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Image of w x h pixels, red left of x, blue from x on
+func split(w, h, x int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for py := 0; py < h; py++ {
+		for px := 0; px < w; px++ {
+			if px < x {
+				img.Set(px, py, color.RGBA{R: 255, A: 255})
+			} else {
+				img.Set(px, py, color.RGBA{B: 255, A: 255})
+			}
+		}
+	}
+	return img
+}
+
+/*
+TestThumbnail Criteria:
+- Thumbnails are square, at most size pixels and never larger than the shorter side of the image
+- The center square is kept, the sides of wide and tall images are cropped
+- Downscaled pixels average the pixels they cover
+*/
+func TestThumbnail(t *testing.T) {
+	testCases := []struct {
+		width, height, size, side int
+	}{
+		{512, 512, 128, 128},
+		{800, 600, 256, 256},
+		{300, 900, 512, 300},
+		{100, 100, 100, 100},
+		{257, 129, 64, 64},
+	}
+	for _, tc := range testCases {
+		thumbnail := Thumbnail(image.NewRGBA(image.Rect(0, 0, tc.width, tc.height)), tc.size)
+		assert.Equal(t, image.Rect(0, 0, tc.side, tc.side), thumbnail.Bounds(), "%dx%d at %d", tc.width, tc.height, tc.size)
+	}
+
+	// Red band of 100 pixels on each side of a 300x100 image, only the blue center is kept
+	wide := split(300, 100, 100)
+	for x := 200; x < 300; x++ {
+		for y := 0; y < 100; y++ {
+			wide.Set(x, y, color.RGBA{R: 255, A: 255})
+		}
+	}
+	thumbnail := Thumbnail(wide, 10)
+	for _, p := range []image.Point{{0, 0}, {9, 0}, {5, 5}, {0, 9}, {9, 9}} {
+		assert.Equal(t, color.RGBA{B: 255, A: 255}, thumbnail.RGBAAt(p.X, p.Y), p)
+	}
+
+	// Tall images keep their vertical center
+	tall := image.NewRGBA(image.Rect(0, 0, 2, 6))
+	tall.Set(0, 2, color.RGBA{G: 255, A: 255})
+	assert.Equal(t, color.RGBA{G: 255, A: 255}, Thumbnail(tall, 2).RGBAAt(0, 0))
+
+	// A 2x2 block of half red and half blue averages to purple
+	averaged := Thumbnail(split(4, 4, 1), 2)
+	assert.Equal(t, color.RGBA{R: 127, B: 127, A: 255}, averaged.RGBAAt(0, 0))
+	assert.Equal(t, color.RGBA{B: 255, A: 255}, averaged.RGBAAt(1, 0))
+}
+
+/*
+TestEncodeJPEG Criteria:
+- Images are encoded as JPEG of the same size, transparent pixels become white
+*/
+func TestEncodeJPEG(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+
+	var buf bytes.Buffer
+	if !assert.NoError(t, EncodeJPEG(&buf, img)) {
+		return
+	}
+	decoded, err := jpeg.Decode(&buf)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, img.Bounds(), decoded.Bounds())
+	r, g, b, _ := decoded.At(8, 8).RGBA()
+	assert.Greater(t, r>>8, uint32(250))
+	assert.Greater(t, g>>8, uint32(250))
+	assert.Greater(t, b>>8, uint32(250))
+}
@@ -0,0 +1,58 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type Mail struct {
+	To      string
+	Subject string
+	Body    string // plain text
+}
+
+// Mailer delivers emails, e.g through an SMTP relay or a transactional email service
+type Mailer interface {
+	SendMail(ctx context.Context, mail Mail) (err error)
+}
+
+// LogMailer writes emails to a logger instead of sending them, used for local runs
+type LogMailer struct {
+	Logger *log.Logger
+}
+
+func NewLogMailer(logger *log.Logger) *LogMailer {
+	return &LogMailer{Logger: logger}
+}
+
+func (m *LogMailer) SendMail(ctx context.Context, mail Mail) (err error) {
+	m.Logger.Printf("mail to=%s subject=%q body=%q", mail.To, mail.Subject, mail.Body)
+	return
+}
+
+// FileMailer writes every email to its own .eml file in Dir instead of sending it, used for local runs
+// where links in emails must be followed, the files open in any mail client
+type FileMailer struct {
+	Dir string
+}
+
+func NewFileMailer(dir string) *FileMailer {
+	return &FileMailer{Dir: dir}
+}
+
+func (m *FileMailer) SendMail(ctx context.Context, mail Mail) (err error) {
+	if err = os.MkdirAll(m.Dir, 0o755); err != nil {
+		return
+	}
+
+	now := time.Now()
+	name := fmt.Sprintf("%d-%s.eml", now.UnixNano(), strings.NewReplacer("@", "_at_", "/", "_").Replace(mail.To))
+	content := fmt.Sprintf("Date: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n",
+		now.Format(time.RFC1123Z), mail.To, mail.Subject, mail.Body)
+	return os.WriteFile(filepath.Join(m.Dir, name), []byte(content), 0o644)
+}
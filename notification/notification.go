@@ -0,0 +1,41 @@
+// This file contains the notification layer.
+// Notifiers deliver messages to users outside of the API, e.g security alerts.
+// Implementations are pluggable so local runs can log messages instead of sending them.
+package notification
+
+import (
+	"context"
+	"log"
+)
+
+type Message struct {
+	UserId  int
+	Phone   string
+	Subject string
+	Body    string
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, message Message) (err error)
+}
+
+// LogNotifier writes messages to a logger instead of delivering them, used for local runs
+type LogNotifier struct {
+	Logger *log.Logger
+}
+
+func NewLogNotifier(logger *log.Logger) *LogNotifier {
+	return &LogNotifier{Logger: logger}
+}
+
+func (n *LogNotifier) Notify(ctx context.Context, message Message) (err error) {
+	n.Logger.Printf("notify user=%d phone=%s subject=%q body=%q", message.UserId, message.Phone, message.Subject, message.Body)
+	return
+}
+
+// NopNotifier drops every message, used when no notifier is configured
+type NopNotifier struct{}
+
+func (NopNotifier) Notify(ctx context.Context, message Message) (err error) {
+	return
+}
@@ -0,0 +1,25 @@
+package notification
+
+import (
+	"context"
+	"log"
+)
+
+// OTPSender delivers one time passwords proving the user holds a phone number, e.g through an SMS gateway
+type OTPSender interface {
+	SendOTP(ctx context.Context, phone string, code string) (err error)
+}
+
+// LogOTPSender writes codes to a logger instead of sending them, used for local runs only since it leaks the codes
+type LogOTPSender struct {
+	Logger *log.Logger
+}
+
+func NewLogOTPSender(logger *log.Logger) *LogOTPSender {
+	return &LogOTPSender{Logger: logger}
+}
+
+func (s *LogOTPSender) SendOTP(ctx context.Context, phone string, code string) (err error) {
+	s.Logger.Printf("otp phone=%s code=%s", phone, code)
+	return
+}
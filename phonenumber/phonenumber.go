@@ -0,0 +1,176 @@
+// This file contains the phone number layer.
+// Numbers are parsed from national or international formats and stored in E.164, e.g +6281234567890.
+package phonenumber
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Region numbering plan, lengths count the digits of the national significant number,
+// i.e after the country calling code and without the trunk prefix
+type Region struct {
+	Code        string // ISO 3166-1 alpha-2 code
+	Name        string
+	CallingCode string
+	TrunkPrefix string // dialed before national numbers inside the country, empty when the region has none
+	MinLength   int
+	MaxLength   int
+}
+
+// Regions the service can be configured with, add a region here to support its numbers
+var Regions = map[string]Region{
+	"ID": {Code: "ID", Name: "Indonesia", CallingCode: "62", TrunkPrefix: "0", MinLength: 8, MaxLength: 12},
+	"MY": {Code: "MY", Name: "Malaysia", CallingCode: "60", TrunkPrefix: "0", MinLength: 8, MaxLength: 10},
+	"PG": {Code: "PG", Name: "Papua New Guinea", CallingCode: "675", MinLength: 7, MaxLength: 8},
+}
+
+// Returned by Parse when the number has characters other than digits and formatting
+var ErrCharacters = errors.New("phone number must only contain digits, spaces, dashes, dots or brackets after an optional +")
+
+// Returned by Parse when an international number is not of an allowed region
+type CallingCodeError struct {
+	CallingCodes []string // calling codes of the allowed regions, e.g +62
+}
+
+func (e *CallingCodeError) Error() string {
+	return "country code must be one of " + strings.Join(e.CallingCodes, ", ")
+}
+
+// Returned by Parse when the number is too short or too long for its region
+type LengthError struct {
+	Region Region
+}
+
+func (e *LengthError) Error() string {
+	return fmt.Sprintf("phone number must have %d to %d digits after +%s for %s, without leading 0", e.Region.MinLength, e.Region.MaxLength, e.Region.CallingCode, e.Region.Name)
+}
+
+// Parser reads numbers of the allowed regions, the first region is the one of numbers without a calling code
+type Parser struct {
+	Regions []Region
+}
+
+// Create a parser for the given region codes, at least one is required and unknown codes are refused
+func NewParser(codes ...string) (*Parser, error) {
+	if len(codes) == 0 {
+		return nil, fmt.Errorf("no phone number region given")
+	}
+
+	parser := &Parser{}
+	for _, code := range codes {
+		region, ok := Regions[strings.ToUpper(strings.TrimSpace(code))]
+		if !ok {
+			return nil, fmt.Errorf("unknown phone number region %q", code)
+		}
+		parser.Regions = append(parser.Regions, region)
+	}
+	return parser, nil
+}
+
+/*
+Parse a phone number and return it in E.164. Spaces, dashes, dots and brackets are ignored.
+Flow:
+ 1. +<calling code> and 00<calling code> are international, the calling code must be of an allowed region
+ 2. Numbers starting with the trunk prefix of the default region are national, e.g 0812... is +62812... for ID
+ 3. Numbers starting with the calling code of an allowed region are international, e.g 62812..., kept because
+    numbers were submitted this way before several regions were supported
+ 4. Anything else is a national number of the default region
+
+Errors are ErrCharacters, *CallingCodeError or *LengthError
+*/
+func (p *Parser) Parse(number string) (e164 string, err error) {
+	digits, international, err := clean(number)
+	if err != nil {
+		return
+	}
+
+	home := p.Regions[0]
+	var region Region
+	switch {
+	case international:
+		var ok bool
+		if region, digits, ok = p.splitCallingCode(digits); !ok {
+			return "", &CallingCodeError{CallingCodes: p.callingCodes()}
+		}
+		// +62 0812... is a common mistake, the trunk prefix is never part of the international number
+		digits = strings.TrimPrefix(digits, region.TrunkPrefix)
+	case home.TrunkPrefix != "" && strings.HasPrefix(digits, home.TrunkPrefix):
+		region, digits = home, strings.TrimPrefix(digits, home.TrunkPrefix)
+	default:
+		region = home
+		if candidate, national, ok := p.splitCallingCode(digits); ok && validLength(candidate, national) {
+			region, digits = candidate, national
+		}
+	}
+
+	if strings.HasPrefix(digits, "0") || !validLength(region, digits) {
+		return "", &LengthError{Region: region}
+	}
+	return "+" + region.CallingCode + digits, nil
+}
+
+/*
+Normalize the start of a phone number the same way as Parse so it can be matched against stored numbers,
+e.g 0812 is +62812 for ID. Prefixes are not validated since they are not complete numbers
+*/
+func (p *Parser) Prefix(number string) string {
+	digits, international, err := clean(number)
+	if err != nil || digits == "" {
+		return strings.TrimSpace(number)
+	}
+
+	home := p.Regions[0]
+	if !international && home.TrunkPrefix != "" && strings.HasPrefix(digits, home.TrunkPrefix) {
+		return "+" + home.CallingCode + strings.TrimPrefix(digits, home.TrunkPrefix)
+	}
+	return "+" + digits
+}
+
+// Strip formatting characters, international tells whether the number started with + or 00
+func clean(number string) (digits string, international bool, err error) {
+	number = strings.TrimSpace(number)
+	if strings.HasPrefix(number, "+") {
+		number, international = number[1:], true
+	}
+
+	var builder strings.Builder
+	for _, r := range number {
+		switch {
+		case r >= '0' && r <= '9':
+			builder.WriteRune(r)
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+		default:
+			return "", false, ErrCharacters
+		}
+	}
+
+	digits = builder.String()
+	if !international && strings.HasPrefix(digits, "00") {
+		digits, international = digits[2:], true
+	}
+	return
+}
+
+// Find the allowed region whose calling code starts digits, calling codes are prefix free so at most one matches
+func (p *Parser) splitCallingCode(digits string) (region Region, national string, ok bool) {
+	for _, region := range p.Regions {
+		if strings.HasPrefix(digits, region.CallingCode) {
+			return region, digits[len(region.CallingCode):], true
+		}
+	}
+	return
+}
+
+func (p *Parser) callingCodes() []string {
+	codes := make([]string, len(p.Regions))
+	for i, region := range p.Regions {
+		codes[i] = "+" + region.CallingCode
+	}
+	return codes
+}
+
+func validLength(region Region, national string) bool {
+	return len(national) >= region.MinLength && len(national) <= region.MaxLength
+}
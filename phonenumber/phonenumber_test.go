@@ -0,0 +1,115 @@
+package phonenumber
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+TestParse Criteria:
+- National and international formats of the allowed regions are normalized to E.164
+- Numbers without a calling code are of the first region
+- Numbers of other regions are refused with the allowed calling codes
+- Numbers too short or too long for their region, or with letters, are refused
+*/
+func TestParse(t *testing.T) {
+	parser, err := NewParser("ID", "MY", "PG")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		number string
+		e164   string
+		err    error
+	}{
+		{"+6281234567890", "+6281234567890", nil},
+		{" +62 812-3456-7890 ", "+6281234567890", nil},
+		{"+62 (0812) 3456.7890", "+6281234567890", nil},
+		{"006281234567890", "+6281234567890", nil},
+		{"6281234567890", "+6281234567890", nil},
+		{"081234567890", "+6281234567890", nil},
+		{"81234567890", "+6281234567890", nil},
+		{"+60 12-345 6789", "+60123456789", nil},
+		{"0060123456789", "+60123456789", nil},
+		{"+675 7123 4567", "+67571234567", nil},
+		{"+6581234567", "", &CallingCodeError{CallingCodes: []string{"+62", "+60", "+675"}}},
+		{"+1 202 555 0100", "", &CallingCodeError{CallingCodes: []string{"+62", "+60", "+675"}}},
+		{"+62 812 345", "", &LengthError{Region: Regions["ID"]}},
+		{"+628123456789012", "", &LengthError{Region: Regions["ID"]}},
+		{"+60 12 345", "", &LengthError{Region: Regions["MY"]}},
+		{"+675 7123 45678", "", &LengthError{Region: Regions["PG"]}},
+		{"00812345678", "", &CallingCodeError{CallingCodes: []string{"+62", "+60", "+675"}}},
+		{"+62 (812) x 3456", "", ErrCharacters},
+		{"", "", &LengthError{Region: Regions["ID"]}},
+	}
+	for _, tc := range testCases {
+		e164, err := parser.Parse(tc.number)
+		assert.Equal(t, tc.e164, e164, tc.number)
+		assert.Equal(t, tc.err, err, tc.number)
+	}
+}
+
+/*
+TestParseDefaultRegion Criteria:
+- National numbers follow the first region of the parser
+- Regions that are not allowed are refused even with a valid number
+*/
+func TestParseDefaultRegion(t *testing.T) {
+	parser, err := NewParser("MY")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e164, err := parser.Parse("012-345 6789")
+	assert.NoError(t, err)
+	assert.Equal(t, "+60123456789", e164)
+
+	_, err = parser.Parse("+6281234567890")
+	assert.Equal(t, &CallingCodeError{CallingCodes: []string{"+60"}}, err)
+	assert.EqualError(t, err, "country code must be one of +60")
+}
+
+/*
+TestNewParser Criteria:
+- Region codes are matched case insensitively
+- No region or an unknown region is refused
+*/
+func TestNewParser(t *testing.T) {
+	parser, err := NewParser(" id", "pg")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []Region{Regions["ID"], Regions["PG"]}, parser.Regions)
+	}
+
+	_, err = NewParser()
+	assert.Error(t, err)
+
+	_, err = NewParser("ID", "SG")
+	assert.EqualError(t, err, `unknown phone number region "SG"`)
+}
+
+/*
+TestPrefix Criteria:
+- National prefixes of the first region are normalized like Parse
+- International prefixes keep their digits, invalid prefixes are returned trimmed
+*/
+func TestPrefix(t *testing.T) {
+	parser, err := NewParser("ID", "MY")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := map[string]string{
+		"0812":     "+62812",
+		"+62 812":  "+62812",
+		"0060 12":  "+6012",
+		"62812":    "+62812",
+		" budi ":   "budi",
+		"":         "",
+		"0812-345": "+62812345",
+	}
+	for prefix, expected := range testCases {
+		assert.Equal(t, expected, parser.Prefix(prefix), prefix)
+	}
+}
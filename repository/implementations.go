@@ -2,71 +2,1373 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
 )
 
 func (r *Repository) CreateUser(ctx context.Context, input CreateUserInput) (output User, err error) {
-	tx, err := r.Db.Begin()
+	tx, err := r.Db.BeginTx(ctx, nil)
 	if err != nil {
 		return
 	}
-	defer tx.Commit()
+	defer tx.Rollback()
 
-	query := `INSERT INTO users(name, phone, password) values($1, $2, $3) RETURNING id`
-	err = tx.QueryRowContext(ctx, query, input.Name, input.Phone, input.Password).Scan(&output.Id)
+	query := `INSERT INTO users AS u(name, phone, password, attributes) values($1, $2, $3, $4) RETURNING ` + userColumns
+	output, err = scanUser(tx.QueryRowContext(ctx, query, input.Name, input.Phone, input.Password, input.Attributes))
 	if err != nil {
-		tx.Rollback()
+		return output, mapError(err)
+	}
+
+	if err = insertAuditEvent(ctx, tx, AuditActionUserCreate, output.Id, nil, &output); err != nil {
 		return
 	}
-	return
+
+	return output, tx.Commit()
+}
+
+/*
+Create a batch of users in a single transaction, results follow the order of inputs.
+A taken phone number or value of a unique attribute only fails its own row, any other error fails the whole batch.
+A dry run only checks the phone numbers against the database without inserting
+*/
+func (r *Repository) CreateUsers(ctx context.Context, inputs []CreateUserInput, dryRun bool) (output []CreateUsersResult, err error) {
+	tx, err := r.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	// Checked upfront so taken phone numbers do not consume ids, same as Register
+	phones := make([]string, len(inputs))
+	for i, input := range inputs {
+		phones[i] = input.Phone
+	}
+	rows, err := tx.QueryContext(ctx, `SELECT phone FROM users WHERE phone = ANY($1)`, pq.Array(phones))
+	if err != nil {
+		return
+	}
+	taken := make(map[string]bool)
+	for rows.Next() {
+		var phone string
+		if err = rows.Scan(&phone); err != nil {
+			rows.Close()
+			return
+		}
+		taken[phone] = true
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return
+	}
+
+	output = make([]CreateUsersResult, len(inputs))
+	for i, input := range inputs {
+		output[i].Conflict = taken[input.Phone]
+	}
+	if dryRun {
+		return output, nil
+	}
+
+	// ON CONFLICT covers phone numbers registered since the check
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO users AS u(name, phone, password, attributes) VALUES($1, $2, $3, $4) ON CONFLICT (phone) DO NOTHING RETURNING `+userColumns)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	for i, input := range inputs {
+		if output[i].Conflict {
+			continue
+		}
+
+		// A taken unique attribute aborts the insert, rolling back to the savepoint keeps the transaction usable
+		if _, err = tx.ExecContext(ctx, `SAVEPOINT create_user`); err != nil {
+			return nil, err
+		}
+		user, insertErr := scanUser(stmt.QueryRowContext(ctx, input.Name, input.Phone, input.Password, input.Attributes))
+		var takenErr *AttributeTakenError
+		if errors.As(mapError(insertErr), &takenErr) {
+			if _, err = tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT create_user`); err != nil {
+				return nil, err
+			}
+			output[i].AttributeTaken = takenErr.Name
+			continue
+		} else if insertErr != nil && insertErr != sql.ErrNoRows {
+			return nil, insertErr
+		}
+		if _, err = tx.ExecContext(ctx, `RELEASE SAVEPOINT create_user`); err != nil {
+			return nil, err
+		}
+		if insertErr == sql.ErrNoRows {
+			output[i].Conflict = true
+			continue
+		}
+		output[i].Id = user.Id
+
+		if err = insertAuditEvent(ctx, tx, AuditActionUserCreate, user.Id, nil, &user); err != nil {
+			return nil, err
+		}
+	}
+
+	return output, tx.Commit()
 }
 
+// Compare-and-swap update, returns ErrVersionMismatch when the user is no longer at input.Version
 func (r *Repository) UpdateUserById(ctx context.Context, input UpdateUserInput) (output User, err error) {
-	tx, err := r.Db.Begin()
+	tx, err := r.Db.BeginTx(ctx, nil)
 	if err != nil {
 		return
 	}
-	defer tx.Commit()
+	defer tx.Rollback()
 
-	query := `UPDATE users SET name=$1, phone=$2, updated_at=NOW() WHERE id = $3 RETURNING name,phone`
-	err = r.Db.QueryRowContext(ctx, query, input.Name, input.Phone, input.Id).Scan(
-		&output.Name,
+	before, err := lockUser(ctx, tx, input.Id)
+	if err == sql.ErrNoRows || (err == nil && before.Version != input.Version) {
+		return output, ErrVersionMismatch
+	} else if err != nil {
+		return
+	}
+
+	query := `UPDATE users u SET name=$1, phone=$2, locale=$3, timezone=$4, avatar=$5, attributes=$6, version=version+1, updated_at=NOW() WHERE u.id = $7 AND u.version = $8 RETURNING ` + userColumns
+	output, err = scanUser(tx.QueryRowContext(ctx, query, input.Name, input.Phone, input.Locale, input.Timezone, input.Avatar, input.Attributes, input.Id, input.Version))
+	if err != nil {
+		return output, mapError(err)
+	}
+
+	if err = insertAuditEvent(ctx, tx, AuditActionUserUpdate, output.Id, &before, &output); err != nil {
+		return
+	}
+
+	return output, tx.Commit()
+}
+
+// Deleted users are never returned, see GetDeletedUserByPhoneNumber
+func (r *Repository) GetUserById(ctx context.Context, id int) (output User, err error) {
+	query := `SELECT ` + userColumns + ` FROM users u WHERE u.id = $1 AND u.deleted_at IS NULL`
+	return scanUser(r.Db.QueryRowContext(ctx, query, id))
+}
+
+// Deleted users are never returned, see GetDeletedUserByPhoneNumber
+func (r *Repository) GetUserByPhoneNumber(ctx context.Context, phone string) (output User, err error) {
+	query := `SELECT ` + userColumns + ` FROM users u WHERE u.phone = $1 AND u.deleted_at IS NULL`
+	return scanUser(r.Db.QueryRowContext(ctx, query, phone))
+}
+
+// Get a user by verified email, email must be normalized. Deleted users are never returned
+func (r *Repository) GetUserByEmail(ctx context.Context, email string) (output User, err error) {
+	query := `SELECT ` + userColumns + ` FROM users u WHERE u.email = $1 AND u.deleted_at IS NULL`
+	return scanUser(r.Db.QueryRowContext(ctx, query, email))
+}
+
+/*
+List users matching every given filter, deleted and purged users included unless filtered by status.
+Pages use keyset pagination on (sort column, id) so rows inserted meanwhile never shift a page
+*/
+func (r *Repository) ListUsers(ctx context.Context, input ListUsersInput) (output []User, err error) {
+	query, args := listUsersQuery(input)
+	args = append(args, input.Limit)
+	query += fmt.Sprintf(` LIMIT $%d`, len(args))
+
+	rows, err := r.Db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var user User
+		if user, err = scanUser(rows); err != nil {
+			return
+		}
+		output = append(output, user)
+	}
+	return output, rows.Err()
+}
+
+/*
+Call each for every user matching the filters of input, in its sort order, Limit and After are ignored.
+Users are fetched in chunks from a server side cursor so the table is never held in memory,
+an error returned by each stops the export and is returned
+*/
+func (r *Repository) ExportUsers(ctx context.Context, input ListUsersInput, each func(User) error) (err error) {
+	// Cursors only live inside a transaction, repeatable read gives the export a single snapshot
+	tx, err := r.Db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	input.After = nil
+	query, args := listUsersQuery(input)
+	if _, err = tx.ExecContext(ctx, `DECLARE export_users NO SCROLL CURSOR FOR `+query, args...); err != nil {
+		return
+	}
+
+	for {
+		rows, err := tx.QueryContext(ctx, fmt.Sprintf(`FETCH FORWARD %d FROM export_users`, exportUsersFetchSize))
+		if err != nil {
+			return err
+		}
+
+		fetched := 0
+		for rows.Next() {
+			fetched++
+			user, err := scanUser(rows)
+			if err == nil {
+				err = each(user)
+			}
+			if err != nil {
+				rows.Close()
+				return err
+			}
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		if fetched < exportUsersFetchSize {
+			return nil
+		}
+	}
+}
+
+// Users fetched per round trip by ExportUsers
+const exportUsersFetchSize = 500
+
+// Build the filtered and ordered users query shared by ListUsers and ExportUsers, without a limit
+func listUsersQuery(input ListUsersInput) (query string, args []interface{}) {
+	var conditions []string
+	where := func(condition string, arg ...interface{}) {
+		for _, value := range arg {
+			args = append(args, value)
+			condition = strings.Replace(condition, "?", fmt.Sprintf("$%d", len(args)), 1)
+		}
+		conditions = append(conditions, condition)
+	}
+
+	if input.NameContains != "" {
+		where(`u.name ILIKE '%' || ? || '%'`, escapeLike(input.NameContains))
+	}
+	if input.PhonePrefix != "" {
+		where(`u.phone LIKE ? || '%'`, escapeLike(input.PhonePrefix))
+	}
+	if input.CreatedAfter != nil {
+		where(`u.created_at >= ?`, *input.CreatedAfter)
+	}
+	if input.CreatedBefore != nil {
+		where(`u.created_at < ?`, *input.CreatedBefore)
+	}
+	if input.UpdatedAfter != nil {
+		where(`u.updated_at >= ?`, *input.UpdatedAfter)
+	}
+	if input.UpdatedBefore != nil {
+		where(`u.updated_at < ?`, *input.UpdatedBefore)
+	}
+	switch input.Status {
+	case UserStatusActive, UserStatusSuspended, UserStatusDisabled:
+		where(`u.status = ? AND u.deleted_at IS NULL`, input.Status)
+	case UserStatusDeleted:
+		where(`u.deleted_at IS NOT NULL AND u.purged_at IS NULL`)
+	case UserStatusPurged:
+		where(`u.purged_at IS NOT NULL`)
+	}
+	if input.Role != "" {
+		where(`u.role = ?`, input.Role)
+	}
+	if len(input.Attributes) != 0 {
+		where(`u.attributes @> ?::jsonb`, input.Attributes)
+	}
+
+	// Ties on the sort column are broken by id, which also makes the cursor unique
+	column, direction, comparison := "u.created_at", "ASC", ">"
+	if input.Descending {
+		direction, comparison = "DESC", "<"
+	}
+	var after interface{}
+	switch input.SortBy {
+	case UserSortUpdatedAt:
+		column = "u.updated_at"
+		if input.After != nil {
+			after = input.After.UpdatedAt
+		}
+	case UserSortName:
+		column = "u.name"
+		if input.After != nil {
+			after = input.After.Name
+		}
+	default:
+		if input.After != nil {
+			after = input.After.CreatedAt
+		}
+	}
+	if input.After != nil {
+		where(fmt.Sprintf(`(%s, u.id) %s (?, ?)`, column, comparison), after, input.After.Id)
+	}
+
+	query = `SELECT ` + userColumns + ` FROM users u`
+	if len(conditions) != 0 {
+		query += ` WHERE ` + strings.Join(conditions, ` AND `)
+	}
+	query += fmt.Sprintf(` ORDER BY %s %s, u.id %s`, column, direction, direction)
+	return
+}
+
+/*
+Search users by name whatever their status, deleted users excepted, tolerating typos and accents, best matches first.
+The score is the trigram word similarity of the query to the closest part of the name,
+so "budi" fully matches "Budi Santoso" and "budy" still scores about 0.4
+*/
+func (r *Repository) SearchUsersByName(ctx context.Context, input SearchUsersInput) (output []UserMatch, err error) {
+	tx, err := r.Db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	// The <% operator is the one served by the trigram index, its threshold is only settable per transaction
+	_, err = tx.ExecContext(ctx, `SELECT set_config('pg_trgm.word_similarity_threshold', $1, true)`, strconv.FormatFloat(input.MinScore, 'f', -1, 64))
+	if err != nil {
+		return
+	}
+
+	query := `SELECT ` + userColumns + `, word_similarity(immutable_unaccent(lower($1)), immutable_unaccent(lower(u.name))) AS score
+		FROM users u
+		WHERE u.deleted_at IS NULL AND immutable_unaccent(lower($1)) <% immutable_unaccent(lower(u.name))
+		ORDER BY score DESC, u.id LIMIT $2`
+	rows, err := tx.QueryContext(ctx, query, input.Query, input.Limit)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var match UserMatch
+		if match.User, err = scanUser(extraColumns{rows, []interface{}{&match.Score}}); err != nil {
+			return
+		}
+		output = append(output, match)
+	}
+	return output, rows.Err()
+}
+
+/*
+Change the lifecycle status of a user and record who changed it and why, in one transaction.
+Every token of the user is revoked. Returns sql.ErrNoRows when the user is deleted or its status is no longer input.From
+*/
+func (r *Repository) UpdateUserStatus(ctx context.Context, input UpdateUserStatusInput) (output User, err error) {
+	tx, err := r.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	before, err := lockUser(ctx, tx, input.Id)
+	if err != nil {
+		return
+	}
+
+	query := `UPDATE users u SET status = $1, token_version = token_version + 1, version = version + 1, updated_at = NOW()
+		WHERE u.id = $2 AND u.status = $3 AND u.deleted_at IS NULL RETURNING ` + userColumns
+	output, err = scanUser(tx.QueryRowContext(ctx, query, input.To, input.Id, input.From))
+	if err != nil {
+		return
+	}
+
+	if err = insertAuditEvent(ctx, tx, AuditActionUserStatusChange, output.Id, &before, &output); err != nil {
+		return
+	}
+
+	query = `INSERT INTO user_status_events(user_id, actor_id, from_status, to_status, reason) VALUES($1, $2, $3, $4, $5)`
+	_, err = tx.ExecContext(ctx, query, input.Id, input.ActorId, input.From, input.To, input.Reason)
+	if err != nil {
+		return
+	}
+
+	return output, tx.Commit()
+}
+
+// List the status changes of a user, oldest first
+func (r *Repository) ListUserStatusEventsByUserId(ctx context.Context, userId int) (output []UserStatusEvent, err error) {
+	query := `SELECT id, user_id, actor_id, from_status, to_status, reason, created_at FROM user_status_events WHERE user_id = $1 ORDER BY id`
+	rows, err := r.Db.QueryContext(ctx, query, userId)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event UserStatusEvent
+		err = rows.Scan(
+			&event.Id,
+			&event.UserId,
+			&event.ActorId,
+			&event.FromStatus,
+			&event.ToStatus,
+			&event.Reason,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return
+		}
+		output = append(output, event)
+	}
+	return output, rows.Err()
+}
+
+// Soft delete a user and revoke every token issued to them, returns sql.ErrNoRows when already deleted
+func (r *Repository) DeleteUserById(ctx context.Context, id int) (output User, err error) {
+	query := `UPDATE users u SET deleted_at = NOW(), token_version = token_version + 1, version = version + 1 WHERE u.id = $1 AND u.deleted_at IS NULL RETURNING ` + userColumns
+	return r.updateUserAudited(ctx, AuditActionUserDelete, id, query)
+}
+
+// Get a deleted user who can still be restored, deleted after deletedAfter and not yet purged
+func (r *Repository) GetDeletedUserByPhoneNumber(ctx context.Context, phone string, deletedAfter time.Time) (output User, err error) {
+	query := `SELECT ` + userColumns + ` FROM users u WHERE u.phone = $1 AND u.deleted_at > $2 AND u.purged_at IS NULL`
+	return scanUser(r.Db.QueryRowContext(ctx, query, phone, deletedAfter))
+}
+
+// Cancel a pending deletion, returns sql.ErrNoRows when the user is not pending deletion
+func (r *Repository) RestoreUserById(ctx context.Context, id int) (output User, err error) {
+	query := `UPDATE users u SET deleted_at = NULL, version = version + 1, updated_at = NOW() WHERE u.id = $1 AND u.deleted_at IS NOT NULL AND u.purged_at IS NULL RETURNING ` + userColumns
+	return r.updateUserAudited(ctx, AuditActionUserRestore, id, query)
+}
+
+// Run an update of a single user taking id as its only argument and record it, see DeleteUserById
+func (r *Repository) updateUserAudited(ctx context.Context, action string, id int, query string) (output User, err error) {
+	tx, err := r.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	before, err := lockUser(ctx, tx, id)
+	if err != nil {
+		return
+	}
+
+	output, err = scanUser(tx.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return
+	}
+
+	if err = insertAuditEvent(ctx, tx, action, output.Id, &before, &output); err != nil {
+		return
+	}
+
+	return output, tx.Commit()
+}
+
+/*
+Permanently anonymize users deleted before deletedBefore, returns the number of purged users and the avatars
+they had, the caller deletes their blobs.
+The row is kept so references from audit records stay valid, but every personal field is
+overwritten and the data hanging off the user (credentials, sessions, login history) is removed.
+*/
+func (r *Repository) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (count int, avatars []string, err error) {
+	tx, err := r.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	// Phone keeps its unique constraint, 'd' + id is unique and never a valid phone number.
+	// RETURNING only sees the new row, the avatar is read from the locked row before the update
+	query := `WITH purged AS (SELECT id, avatar FROM users WHERE deleted_at < $1 AND purged_at IS NULL FOR UPDATE)
+		UPDATE users u SET name = 'deleted user', phone = 'd' || u.id, email = NULL, email_verified_at = NULL, locale = NULL, timezone = NULL, avatar = NULL, attributes = '{}', password = '', purged_at = NOW(), version = version + 1, updated_at = NOW()
+		FROM purged p WHERE u.id = p.id RETURNING u.id, p.avatar`
+	rows, err := tx.QueryContext(ctx, query, deletedBefore)
+	if err != nil {
+		return
+	}
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		var avatar sql.NullString
+		if err = rows.Scan(&id, &avatar); err != nil {
+			rows.Close()
+			return
+		}
+		ids = append(ids, id)
+		if avatar.Valid {
+			avatars = append(avatars, avatar.String)
+		}
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return
+	}
+
+	// Audit events outlive the account but must not keep its personal data
+	for _, query := range []string{
+		`UPDATE audit_events SET before = before - '{name,phone,email,locale,timezone,avatar,attributes}'::text[], after = after - '{name,phone,email,locale,timezone,avatar,attributes}'::text[] WHERE target_id = ANY($1)`,
+		`DELETE FROM api_keys WHERE user_id = ANY($1)`,
+		`DELETE FROM sessions WHERE user_id = ANY($1)`,
+		`DELETE FROM login_attempts WHERE user_id = ANY($1)`,
+		`DELETE FROM data_exports WHERE user_id = ANY($1)`,
+		`DELETE FROM phone_changes WHERE user_id = ANY($1)`,
+		`DELETE FROM email_verifications WHERE user_id = ANY($1)`,
+	} {
+		if _, err = tx.ExecContext(ctx, query, pq.Array(ids)); err != nil {
+			return
+		}
+	}
+
+	for _, id := range ids {
+		if err = insertAuditEvent(ctx, tx, AuditActionUserPurge, id, nil, nil); err != nil {
+			return
+		}
+	}
+
+	return len(ids), avatars, tx.Commit()
+}
+
+// Lock a user row until the end of tx and return it as it was before the write, deleted users included
+func lockUser(ctx context.Context, tx *sql.Tx, id int) (output User, err error) {
+	query := `SELECT ` + userColumns + ` FROM users u WHERE u.id = $1 FOR UPDATE`
+	return scanUser(tx.QueryRowContext(ctx, query, id))
+}
+
+// Fields of a user recorded in audit events, the password hash is never stored only whether it is set
+type auditUser struct {
+	Name       string     `json:"name"`
+	Phone      string     `json:"phone"`
+	Email      *string    `json:"email"`
+	Locale     *string    `json:"locale"`
+	Timezone   *string    `json:"timezone"`
+	Avatar     *string    `json:"avatar"`
+	Attributes Attributes `json:"attributes"`
+	Password   string     `json:"password,omitempty"`
+	Role       string     `json:"role"`
+	Status     string     `json:"status"`
+	DeletedAt  *time.Time `json:"deleted_at"`
+}
+
+// Encode the audited fields of a user, NULL when there is no user
+func auditValue(user *User) (value sql.NullString, err error) {
+	if user == nil {
+		return
+	}
+
+	audited := auditUser{
+		Name:       user.Name,
+		Phone:      user.Phone,
+		Email:      user.Email,
+		Locale:     user.Locale,
+		Timezone:   user.Timezone,
+		Avatar:     user.Avatar,
+		Attributes: user.Attributes,
+		Role:       user.Role,
+		Status:     user.Status,
+		DeletedAt:  user.DeletedAt,
+	}
+	if user.Password != "" {
+		audited.Password = AuditRedacted
+	}
+
+	// Sent as text, the driver would send []byte as bytea
+	encoded, err := json.Marshal(audited)
+	return sql.NullString{String: string(encoded), Valid: true}, err
+}
+
+/*
+Append an audit event in the transaction of the write it records, so neither exists without the other.
+The actor, request id and ip come from the AuditMetadata of ctx, left empty when there is none
+*/
+func insertAuditEvent(ctx context.Context, tx *sql.Tx, action string, targetId int, before, after *User) error {
+	var metadata AuditMetadata
+	if m := AuditMetadataFromContext(ctx); m != nil {
+		metadata = *m
+	}
+
+	var actorId sql.NullInt64
+	if metadata.ActorId != 0 {
+		actorId = sql.NullInt64{Int64: int64(metadata.ActorId), Valid: true}
+	}
+
+	beforeValue, err := auditValue(before)
+	if err != nil {
+		return err
+	}
+	afterValue, err := auditValue(after)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO audit_events(actor_id, target_id, action, before, after, request_id, ip) VALUES($1, $2, $3, $4, $5, $6, $7)`
+	_, err = tx.ExecContext(ctx, query, actorId, targetId, action, beforeValue, afterValue, metadata.RequestId, metadata.Ip)
+	return err
+}
+
+/*
+List audit events newest first, paginated by id since events are append only.
+Events of every user are listed unless filtered by target or actor, the time range is inclusive of CreatedAfter only
+*/
+func (r *Repository) ListAuditEvents(ctx context.Context, input ListAuditEventsInput) (output []AuditEvent, err error) {
+	query := `SELECT id, actor_id, target_id, action, before, after, request_id, ip, created_at FROM audit_events
+		WHERE ($1 = 0 OR target_id = $1)
+			AND ($2::timestamp IS NULL OR created_at >= $2)
+			AND ($3::timestamp IS NULL OR created_at < $3)
+			AND ($4 = 0 OR id < $4)
+			AND ($6 = 0 OR actor_id = $6)
+		ORDER BY id DESC LIMIT $5`
+	rows, err := r.Db.QueryContext(ctx, query, input.TargetId, input.CreatedAfter, input.CreatedBefore, input.BeforeId, input.Limit, input.ActorId)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event AuditEvent
+		var actorId sql.NullInt64
+		var before, after []byte
+		err = rows.Scan(
+			&event.Id,
+			&actorId,
+			&event.TargetId,
+			&event.Action,
+			&before,
+			&after,
+			&event.RequestId,
+			&event.Ip,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return
+		}
+		if actorId.Valid {
+			id := int(actorId.Int64)
+			event.ActorId = &id
+		}
+		event.Before, event.After = before, after
+		output = append(output, event)
+	}
+	return output, rows.Err()
+}
+
+// Start a phone number change, the pending change of the user if any is cancelled and kept
+func (r *Repository) CreatePhoneChange(ctx context.Context, input CreatePhoneChangeInput) (output PhoneChange, err error) {
+	tx, err := r.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `UPDATE phone_changes SET cancelled_at = NOW() WHERE user_id = $1 AND verified_at IS NULL AND cancelled_at IS NULL`, input.UserId)
+	if err != nil {
+		return
+	}
+
+	query := `INSERT INTO phone_changes(user_id, phone, code_hash, expires_at) VALUES($1, $2, $3, $4) RETURNING ` + phoneChangeColumns
+	output, err = scanPhoneChange(tx.QueryRowContext(ctx, query, input.UserId, input.Phone, input.CodeHash, input.ExpiresAt))
+	if err != nil {
+		return
+	}
+
+	return output, tx.Commit()
+}
+
+// Get the latest phone change of a user whether pending, verified or cancelled, returns sql.ErrNoRows when there is none
+func (r *Repository) GetLatestPhoneChange(ctx context.Context, userId int) (output PhoneChange, err error) {
+	query := `SELECT ` + phoneChangeColumns + ` FROM phone_changes WHERE user_id = $1 ORDER BY id DESC LIMIT 1`
+	return scanPhoneChange(r.Db.QueryRowContext(ctx, query, userId))
+}
+
+// Get the unverified phone change of a user, expired ones included so the caller can tell why it failed
+func (r *Repository) GetPendingPhoneChange(ctx context.Context, userId int) (output PhoneChange, err error) {
+	query := `SELECT ` + phoneChangeColumns + ` FROM phone_changes WHERE user_id = $1 AND verified_at IS NULL AND cancelled_at IS NULL`
+	return scanPhoneChange(r.Db.QueryRowContext(ctx, query, userId))
+}
+
+// Count a code submitted for a phone change in one statement, so concurrent guesses cannot exceed maxAttempts.
+// Returns sql.ErrNoRows when maxAttempts were already made
+func (r *Repository) IncrementPhoneChangeAttempts(ctx context.Context, id int, maxAttempts int) (attempts int, err error) {
+	query := `UPDATE phone_changes SET attempts = attempts + 1 WHERE id = $1 AND attempts < $2 RETURNING attempts`
+	err = r.Db.QueryRowContext(ctx, query, id, maxAttempts).Scan(&attempts)
+	return
+}
+
+/*
+Switch the phone number of the user to the verified one of a pending change, in one transaction.
+Returns sql.ErrNoRows when the change is no longer pending or the user is deleted, ErrConflict when
+the number was registered meanwhile
+*/
+func (r *Repository) CompletePhoneChange(ctx context.Context, id int) (output User, err error) {
+	tx, err := r.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	var userId int
+	var phone string
+	query := `UPDATE phone_changes SET verified_at = NOW() WHERE id = $1 AND verified_at IS NULL AND cancelled_at IS NULL RETURNING user_id, phone`
+	err = tx.QueryRowContext(ctx, query, id).Scan(&userId, &phone)
+	if err != nil {
+		return
+	}
+
+	before, err := lockUser(ctx, tx, userId)
+	if err != nil {
+		return
+	}
+
+	query = `UPDATE users u SET phone = $1, version = version + 1, updated_at = NOW() WHERE u.id = $2 AND u.deleted_at IS NULL RETURNING ` + userColumns
+	output, err = scanUser(tx.QueryRowContext(ctx, query, phone, userId))
+	if err != nil {
+		return output, mapError(err)
+	}
+
+	if err = insertAuditEvent(ctx, tx, AuditActionUserUpdate, output.Id, &before, &output); err != nil {
+		return
+	}
+
+	return output, tx.Commit()
+}
+
+// Cancel the pending phone change of a user, returns sql.ErrNoRows when there is none
+func (r *Repository) CancelPhoneChange(ctx context.Context, userId int) (err error) {
+	var id int
+	query := `UPDATE phone_changes SET cancelled_at = NOW() WHERE user_id = $1 AND verified_at IS NULL AND cancelled_at IS NULL RETURNING id`
+	return r.Db.QueryRowContext(ctx, query, userId).Scan(&id)
+}
+
+// Delete a phone change whose code could not be sent, it does not count against the time between two codes
+func (r *Repository) DeletePhoneChange(ctx context.Context, id int) (err error) {
+	_, err = r.Db.ExecContext(ctx, `DELETE FROM phone_changes WHERE id = $1 AND verified_at IS NULL`, id)
+	return
+}
+
+// Columns selected for every phone change read, keep in sync with scanPhoneChange
+const phoneChangeColumns = `id, user_id, phone, code_hash, attempts, expires_at, verified_at, cancelled_at, created_at`
+
+func scanPhoneChange(row scanner) (output PhoneChange, err error) {
+	err = row.Scan(
+		&output.Id,
+		&output.UserId,
 		&output.Phone,
+		&output.CodeHash,
+		&output.Attempts,
+		&output.ExpiresAt,
+		&output.VerifiedAt,
+		&output.CancelledAt,
+		&output.CreatedAt,
 	)
+	return
+}
+
+// Start the verification of an email, replacing the pending verification of the user if any
+func (r *Repository) CreateEmailVerification(ctx context.Context, input CreateEmailVerificationInput) (output EmailVerification, err error) {
+	tx, err := r.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM email_verifications WHERE user_id = $1 AND verified_at IS NULL`, input.UserId)
 	if err != nil {
-		tx.Rollback()
 		return
 	}
+
+	query := `INSERT INTO email_verifications(user_id, email, token_hash, expires_at) VALUES($1, $2, $3, $4) RETURNING ` + emailVerificationColumns
+	output, err = scanEmailVerification(tx.QueryRowContext(ctx, query, input.UserId, input.Email, input.TokenHash, input.ExpiresAt))
+	if err != nil {
+		return
+	}
+
+	return output, tx.Commit()
+}
+
+// Get the unverified email verification of a user, expired ones included
+func (r *Repository) GetPendingEmailVerification(ctx context.Context, userId int) (output EmailVerification, err error) {
+	query := `SELECT ` + emailVerificationColumns + ` FROM email_verifications WHERE user_id = $1 AND verified_at IS NULL`
+	return scanEmailVerification(r.Db.QueryRowContext(ctx, query, userId))
+}
+
+// Get the unverified email verification a link was sent for, expired ones included
+func (r *Repository) GetEmailVerificationByTokenHash(ctx context.Context, tokenHash string) (output EmailVerification, err error) {
+	query := `SELECT ` + emailVerificationColumns + ` FROM email_verifications WHERE token_hash = $1 AND verified_at IS NULL`
+	return scanEmailVerification(r.Db.QueryRowContext(ctx, query, tokenHash))
+}
+
+/*
+Set the email of the user to the one of a pending verification, in one transaction.
+Returns sql.ErrNoRows when the verification is no longer pending or the user is deleted, ErrConflict when
+another user verified the email meanwhile
+*/
+func (r *Repository) CompleteEmailVerification(ctx context.Context, id int) (output User, err error) {
+	tx, err := r.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	var userId int
+	var email string
+	query := `UPDATE email_verifications SET verified_at = NOW() WHERE id = $1 AND verified_at IS NULL RETURNING user_id, email`
+	err = tx.QueryRowContext(ctx, query, id).Scan(&userId, &email)
+	if err != nil {
+		return
+	}
+
+	before, err := lockUser(ctx, tx, userId)
+	if err != nil {
+		return
+	}
+
+	query = `UPDATE users u SET email = $1, email_verified_at = NOW(), version = version + 1, updated_at = NOW()
+		WHERE u.id = $2 AND u.deleted_at IS NULL RETURNING ` + userColumns
+	output, err = scanUser(tx.QueryRowContext(ctx, query, email, userId))
+	if err != nil {
+		return output, mapError(err)
+	}
+
+	if err = insertAuditEvent(ctx, tx, AuditActionUserUpdate, output.Id, &before, &output); err != nil {
+		return
+	}
+
+	return output, tx.Commit()
+}
+
+// Drop the pending email verification of a user, returns sql.ErrNoRows when there is none
+func (r *Repository) CancelEmailVerification(ctx context.Context, userId int) (err error) {
+	var id int
+	query := `DELETE FROM email_verifications WHERE user_id = $1 AND verified_at IS NULL RETURNING id`
+	return r.Db.QueryRowContext(ctx, query, userId).Scan(&id)
+}
+
+// Remove the email of a user, returns sql.ErrNoRows when the user has none
+func (r *Repository) RemoveUserEmail(ctx context.Context, userId int) (output User, err error) {
+	query := `UPDATE users u SET email = NULL, email_verified_at = NULL, version = version + 1, updated_at = NOW()
+		WHERE u.id = $1 AND u.email IS NOT NULL AND u.deleted_at IS NULL RETURNING ` + userColumns
+	return r.updateUserAudited(ctx, AuditActionUserUpdate, userId, query)
+}
+
+// Columns selected for every email verification read, keep in sync with scanEmailVerification
+const emailVerificationColumns = `id, user_id, email, token_hash, expires_at, verified_at, created_at`
+
+func scanEmailVerification(row scanner) (output EmailVerification, err error) {
+	err = row.Scan(
+		&output.Id,
+		&output.UserId,
+		&output.Email,
+		&output.TokenHash,
+		&output.ExpiresAt,
+		&output.VerifiedAt,
+		&output.CreatedAt,
+	)
 	return
 }
 
-func (r *Repository) GetUserById(ctx context.Context, id int) (output User, err error) {
-	query := `SELECT u.id, u.name, u.phone, u.password, u.updated_at, u.created_at FROM users u WHERE u.id = $1`
-	err = r.Db.QueryRowContext(ctx, query, id).Scan(
+// Escape the wildcards of a LIKE pattern so user input only matches literally
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+// Columns selected for every user read, keep in sync with scanUser
+const userColumns = `u.id, u.name, u.phone, u.email, u.email_verified_at, u.locale, u.timezone, u.avatar, u.attributes, u.password, u.role, u.status, u.token_version, u.version, u.deleted_at, u.purged_at, u.updated_at, u.created_at`
+
+// Scan a single users row selected with userColumns
+func scanUser(row scanner) (output User, err error) {
+	err = row.Scan(
 		&output.Id,
 		&output.Name,
 		&output.Phone,
+		&output.Email,
+		&output.EmailVerifiedAt,
+		&output.Locale,
+		&output.Timezone,
+		&output.Avatar,
+		&output.Attributes,
 		&output.Password,
+		&output.Role,
+		&output.Status,
+		&output.TokenVersion,
+		&output.Version,
+		&output.DeletedAt,
+		&output.PurgedAt,
 		&output.UpdatedAt,
 		&output.CreatedAt,
 	)
+	return
+}
+
+// Attribute definitions ordered by name
+func (r *Repository) ListAttributeDefinitions(ctx context.Context) (output []AttributeDefinition, err error) {
+	rows, err := r.Db.QueryContext(ctx, `SELECT `+attributeDefinitionColumns+` FROM attribute_definitions ORDER BY name`)
 	if err != nil {
 		return
 	}
-	return
+	defer rows.Close()
+
+	for rows.Next() {
+		var definition AttributeDefinition
+		if definition, err = scanAttributeDefinition(rows); err != nil {
+			return
+		}
+		output = append(output, definition)
+	}
+	return output, rows.Err()
 }
 
-func (r *Repository) GetUserByPhoneNumber(ctx context.Context, phone string) (output User, err error) {
-	query := `SELECT u.id, u.name, u.phone, u.password, u.updated_at, u.created_at FROM users u WHERE u.phone = $1`
-	err = r.Db.QueryRowContext(ctx, query, phone).Scan(
-		&output.Id,
+/*
+Create or replace the definition of an attribute, values users already have are not checked against it.
+A unique attribute gets a unique index on its values, AttributeTakenError is returned when users already share a value.
+Building the index blocks writes to users meanwhile, definitions change rarely
+*/
+func (r *Repository) PutAttributeDefinition(ctx context.Context, input AttributeDefinition) (output AttributeDefinition, err error) {
+	tx, err := r.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	allowedValues, err := attributeValues(input.AllowedValues)
+	if err != nil {
+		return
+	}
+	query := `INSERT INTO attribute_definitions(name, type, required, allowed_values, is_unique, description) VALUES($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (name) DO UPDATE SET type = $2, required = $3, allowed_values = $4, is_unique = $5, description = $6, updated_at = NOW()
+		RETURNING ` + attributeDefinitionColumns
+	output, err = scanAttributeDefinition(tx.QueryRowContext(ctx, query, input.Name, input.Type, input.Required, allowedValues, input.Unique, input.Description))
+	if err != nil {
+		return
+	}
+
+	// Identifiers and literals cannot be parameters, both are quoted
+	index := pq.QuoteIdentifier(attributeIndexPrefix + input.Name)
+	statement := `DROP INDEX IF EXISTS ` + index
+	if input.Unique {
+		statement = fmt.Sprintf(`CREATE UNIQUE INDEX IF NOT EXISTS %s ON users ((attributes -> %s))`, index, pq.QuoteLiteral(input.Name))
+	}
+	if _, err = tx.ExecContext(ctx, statement); err != nil {
+		return output, mapError(err)
+	}
+
+	return output, tx.Commit()
+}
+
+// Delete the definition of an attribute and its value from every user, sql.ErrNoRows when it is not defined
+func (r *Repository) DeleteAttributeDefinition(ctx context.Context, name string) (err error) {
+	tx, err := r.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	if err = tx.QueryRowContext(ctx, `DELETE FROM attribute_definitions WHERE name = $1 RETURNING name`, name).Scan(&name); err != nil {
+		return
+	}
+	if _, err = tx.ExecContext(ctx, `DROP INDEX IF EXISTS `+pq.QuoteIdentifier(attributeIndexPrefix+name)); err != nil {
+		return
+	}
+
+	// Every user losing the value is audited, RETURNING only sees the new row so the old attributes are read from the locked row
+	query := `WITH old AS (SELECT id, attributes FROM users WHERE attributes ? $1 FOR UPDATE)
+		UPDATE users u SET attributes = u.attributes - $1, version = version + 1, updated_at = NOW()
+		FROM old o WHERE u.id = o.id RETURNING ` + userColumns + `, o.attributes`
+	rows, err := tx.QueryContext(ctx, query, name)
+	if err != nil {
+		return
+	}
+	var befores, afters []User
+	for rows.Next() {
+		var after User
+		var attributes Attributes
+		if after, err = scanUser(extraColumns{rows, []interface{}{&attributes}}); err != nil {
+			rows.Close()
+			return
+		}
+		before := after
+		before.Attributes = attributes
+		befores, afters = append(befores, before), append(afters, after)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return
+	}
+
+	for i := range afters {
+		if err = insertAuditEvent(ctx, tx, AuditActionUserUpdate, afters[i].Id, &befores[i], &afters[i]); err != nil {
+			return
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Encode the allowed values of a definition, NULL when any value is allowed
+func attributeValues(values []interface{}) (value sql.NullString, err error) {
+	if values == nil {
+		return
+	}
+	encoded, err := json.Marshal(values)
+	return sql.NullString{String: string(encoded), Valid: true}, err
+}
+
+// Unique attributes are enforced by an index named after them
+const attributeIndexPrefix = "index_user_attribute_"
+
+const attributeDefinitionColumns = `name, type, required, allowed_values, is_unique, description, updated_at, created_at`
+
+func scanAttributeDefinition(row scanner) (output AttributeDefinition, err error) {
+	var allowedValues []byte
+	err = row.Scan(
 		&output.Name,
-		&output.Phone,
-		&output.Password,
+		&output.Type,
+		&output.Required,
+		&allowedValues,
+		&output.Unique,
+		&output.Description,
 		&output.UpdatedAt,
 		&output.CreatedAt,
 	)
+	if err == nil && allowedValues != nil {
+		err = json.Unmarshal(allowedValues, &output.AllowedValues)
+	}
+	return
+}
+
+func (r *Repository) CreateApiKey(ctx context.Context, input CreateApiKeyInput) (output ApiKey, err error) {
+	query := `INSERT INTO api_keys(user_id, name, prefix, key_hash, scopes, expires_at) VALUES($1, $2, $3, $4, $5, $6) RETURNING ` + apiKeyColumns
+	return scanApiKey(r.Db.QueryRowContext(ctx, query, input.UserId, input.Name, input.Prefix, input.KeyHash, pq.Array(input.Scopes), input.ExpiresAt))
+}
+
+// List every api key of a user including revoked ones, newest first
+func (r *Repository) ListAllApiKeysByUserId(ctx context.Context, userId int) (output []ApiKey, err error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE user_id = $1 ORDER BY created_at DESC, id DESC`
+	rows, err := r.Db.QueryContext(ctx, query, userId)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		apiKey, err := scanApiKey(rows)
+		if err != nil {
+			return output, err
+		}
+		output = append(output, apiKey)
+	}
+	return output, rows.Err()
+}
+
+// List active (not revoked) api keys of a user, newest first
+func (r *Repository) ListApiKeysByUserId(ctx context.Context, userId int) (output []ApiKey, err error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE user_id = $1 AND revoked_at IS NULL ORDER BY created_at DESC, id DESC`
+	rows, err := r.Db.QueryContext(ctx, query, userId)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		apiKey, err := scanApiKey(rows)
+		if err != nil {
+			return output, err
+		}
+		output = append(output, apiKey)
+	}
+	return output, rows.Err()
+}
+
+func (r *Repository) GetApiKeyByHash(ctx context.Context, keyHash string) (output ApiKey, err error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE key_hash = $1`
+	return scanApiKey(r.Db.QueryRowContext(ctx, query, keyHash))
+}
+
+// Rename an active api key owned by the user, returns sql.ErrNoRows when not found
+func (r *Repository) UpdateApiKeyName(ctx context.Context, input UpdateApiKeyInput) (output ApiKey, err error) {
+	query := `UPDATE api_keys SET name = $1 WHERE id = $2 AND user_id = $3 AND revoked_at IS NULL RETURNING ` + apiKeyColumns
+	return scanApiKey(r.Db.QueryRowContext(ctx, query, input.Name, input.Id, input.UserId))
+}
+
+// Revoke an active api key owned by the user, returns sql.ErrNoRows when not found
+func (r *Repository) RevokeApiKey(ctx context.Context, userId int, id int) (err error) {
+	query := `UPDATE api_keys SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL RETURNING id`
+	return r.Db.QueryRowContext(ctx, query, id, userId).Scan(&id)
+}
+
+// Record api key usage, last_used_at is only informational so callers may ignore the error
+func (r *Repository) TouchApiKey(ctx context.Context, id int) (err error) {
+	query := `UPDATE api_keys SET last_used_at = NOW() WHERE id = $1`
+	_, err = r.Db.ExecContext(ctx, query, id)
+	return
+}
+
+// Append an impersonation event, events are never updated or deleted
+func (r *Repository) CreateImpersonationEvent(ctx context.Context, input CreateImpersonationEventInput) (err error) {
+	query := `INSERT INTO impersonation_events(actor_id, target_id, action, reason, method, path, status, ip, user_agent) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+	_, err = r.Db.ExecContext(ctx, query, input.ActorId, input.TargetId, input.Action, input.Reason, input.Method, input.Path, input.Status, input.Ip, input.UserAgent)
+	return
+}
+
+func (r *Repository) CreateSession(ctx context.Context, input CreateSessionInput) (output Session, err error) {
+	query := `INSERT INTO sessions(user_id, device_name, user_agent, ip, expires_at) VALUES($1, $2, $3, $4, $5) RETURNING ` + sessionColumns
+	return scanSession(r.Db.QueryRowContext(ctx, query, input.UserId, input.DeviceName, input.UserAgent, input.Ip, input.ExpiresAt))
+}
+
+func (r *Repository) GetSessionById(ctx context.Context, id int) (output Session, err error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE id = $1`
+	return scanSession(r.Db.QueryRowContext(ctx, query, id))
+}
+
+// List every session of a user including revoked and expired ones, most recently seen first
+func (r *Repository) ListAllSessionsByUserId(ctx context.Context, userId int) (output []Session, err error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE user_id = $1 ORDER BY last_seen_at DESC, id DESC`
+	rows, err := r.Db.QueryContext(ctx, query, userId)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return output, err
+		}
+		output = append(output, session)
+	}
+	return output, rows.Err()
+}
+
+// List sessions of a user which are neither revoked nor expired, most recently seen first
+func (r *Repository) ListSessionsByUserId(ctx context.Context, userId int) (output []Session, err error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW() ORDER BY last_seen_at DESC, id DESC`
+	rows, err := r.Db.QueryContext(ctx, query, userId)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return output, err
+		}
+		output = append(output, session)
+	}
+	return output, rows.Err()
+}
+
+// Revoke an active session owned by the user, returns sql.ErrNoRows when not found
+func (r *Repository) RevokeSession(ctx context.Context, userId int, id int) (err error) {
+	query := `UPDATE sessions SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL RETURNING id`
+	return r.Db.QueryRowContext(ctx, query, id, userId).Scan(&id)
+}
+
+// Record session activity, writes at most once a minute per session to keep authenticated reads cheap
+func (r *Repository) TouchSession(ctx context.Context, id int) (err error) {
+	query := `UPDATE sessions SET last_seen_at = NOW() WHERE id = $1 AND last_seen_at < NOW() - INTERVAL '1 minute'`
+	_, err = r.Db.ExecContext(ctx, query, id)
+	return
+}
+
+// Columns selected for every session read, keep in sync with scanSession
+const sessionColumns = `id, user_id, device_name, user_agent, ip, expires_at, last_seen_at, revoked_at, created_at`
+
+// Scan a single sessions row selected with sessionColumns
+func scanSession(row scanner) (output Session, err error) {
+	err = row.Scan(
+		&output.Id,
+		&output.UserId,
+		&output.DeviceName,
+		&output.UserAgent,
+		&output.Ip,
+		&output.ExpiresAt,
+		&output.LastSeenAt,
+		&output.RevokedAt,
+		&output.CreatedAt,
+	)
+	return
+}
+
+// Append a login attempt, attempts are never updated or deleted
+func (r *Repository) CreateLoginAttempt(ctx context.Context, input CreateLoginAttemptInput) (err error) {
+	query := `INSERT INTO login_attempts(user_id, phone, success, reason, ip, user_agent, device_fingerprint) VALUES($1, $2, $3, $4, $5, $6, $7)`
+	_, err = r.Db.ExecContext(ctx, query, input.UserId, input.Phone, input.Success, input.Reason, input.Ip, input.UserAgent, input.DeviceFingerprint)
+	return
+}
+
+// List login attempts of a user newest first, paginated by id since attempts are append only
+func (r *Repository) ListLoginAttemptsByUserId(ctx context.Context, input ListLoginAttemptsInput) (output []LoginAttempt, err error) {
+	query := `SELECT id, user_id, phone, success, reason, ip, user_agent, device_fingerprint, created_at FROM login_attempts
+		WHERE user_id = $1 AND ($2 = 0 OR id < $2) ORDER BY id DESC LIMIT $3`
+	rows, err := r.Db.QueryContext(ctx, query, input.UserId, input.BeforeId, input.Limit)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var attempt LoginAttempt
+		err = rows.Scan(
+			&attempt.Id,
+			&attempt.UserId,
+			&attempt.Phone,
+			&attempt.Success,
+			&attempt.Reason,
+			&attempt.Ip,
+			&attempt.UserAgent,
+			&attempt.DeviceFingerprint,
+			&attempt.CreatedAt,
+		)
+		if err != nil {
+			return
+		}
+		output = append(output, attempt)
+	}
+	return output, rows.Err()
+}
+
+func (r *Repository) GetLoginDeviceStatus(ctx context.Context, userId int, deviceFingerprint string) (output LoginDeviceStatus, err error) {
+	query := `SELECT
+		EXISTS(SELECT 1 FROM login_attempts WHERE user_id = $1 AND success),
+		EXISTS(SELECT 1 FROM login_attempts WHERE user_id = $1 AND success AND device_fingerprint = $2)`
+	err = r.Db.QueryRowContext(ctx, query, userId, deviceFingerprint).Scan(&output.HasLoggedIn, &output.DeviceSeen)
+	return
+}
+
+// List every impersonation event where the user was impersonated, oldest first
+func (r *Repository) ListImpersonationEventsByTargetId(ctx context.Context, targetId int) (output []ImpersonationEvent, err error) {
+	query := `SELECT id, actor_id, target_id, action, reason, method, path, status, ip, user_agent, created_at FROM impersonation_events WHERE target_id = $1 ORDER BY id`
+	rows, err := r.Db.QueryContext(ctx, query, targetId)
 	if err != nil {
 		return
 	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event ImpersonationEvent
+		err = rows.Scan(
+			&event.Id,
+			&event.ActorId,
+			&event.TargetId,
+			&event.Action,
+			&event.Reason,
+			&event.Method,
+			&event.Path,
+			&event.Status,
+			&event.Ip,
+			&event.UserAgent,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return
+		}
+		output = append(output, event)
+	}
+	return output, rows.Err()
+}
+
+// Queue a data export, returns ErrConflict while another export of the user is pending or running
+func (r *Repository) CreateDataExport(ctx context.Context, input CreateDataExportInput) (output DataExport, err error) {
+	query := `INSERT INTO data_exports(user_id, format) VALUES($1, $2) RETURNING ` + dataExportColumns
+	output, err = scanDataExport(r.Db.QueryRowContext(ctx, query, input.UserId, input.Format))
+	return output, mapError(err)
+}
+
+func (r *Repository) GetDataExportById(ctx context.Context, userId int, id int) (output DataExport, err error) {
+	query := `SELECT ` + dataExportColumns + ` FROM data_exports WHERE id = $1 AND user_id = $2`
+	return scanDataExport(r.Db.QueryRowContext(ctx, query, id, userId))
+}
+
+// Get the archive of a completed export, returns sql.ErrNoRows when it is not available (anymore)
+func (r *Repository) GetDataExportArchive(ctx context.Context, userId int, id int) (archive []byte, err error) {
+	query := `SELECT archive FROM data_exports WHERE id = $1 AND user_id = $2 AND status = $3 AND expires_at > NOW()`
+	err = r.Db.QueryRowContext(ctx, query, id, userId, DataExportStatusCompleted).Scan(&archive)
 	return
 }
+
+// Mark the oldest pending export running and return it, concurrent workers never claim the same export.
+// Returns sql.ErrNoRows when nothing is pending
+func (r *Repository) ClaimPendingDataExport(ctx context.Context, startedBefore time.Time) (output DataExport, err error) {
+	query := `UPDATE data_exports SET status = $1, started_at = NOW() WHERE id = (
+			SELECT id FROM data_exports WHERE status = $2 OR (status = $1 AND started_at < $3)
+			ORDER BY id LIMIT 1 FOR UPDATE SKIP LOCKED
+		) RETURNING ` + dataExportColumns
+	return scanDataExport(r.Db.QueryRowContext(ctx, query, DataExportStatusRunning, DataExportStatusPending, startedBefore))
+}
+
+func (r *Repository) CompleteDataExport(ctx context.Context, input CompleteDataExportInput) (err error) {
+	query := `UPDATE data_exports SET status = $1, archive = $2, expires_at = $3, completed_at = NOW() WHERE id = $4`
+	_, err = r.Db.ExecContext(ctx, query, DataExportStatusCompleted, input.Archive, input.ExpiresAt, input.Id)
+	return
+}
+
+func (r *Repository) FailDataExport(ctx context.Context, id int, reason string) (err error) {
+	query := `UPDATE data_exports SET status = $1, error = $2, completed_at = NOW() WHERE id = $3`
+	_, err = r.Db.ExecContext(ctx, query, DataExportStatusFailed, reason, id)
+	return
+}
+
+// Drop archives of completed exports past their expiry, returns the number of expired exports
+func (r *Repository) ExpireDataExports(ctx context.Context) (count int, err error) {
+	query := `UPDATE data_exports SET status = $1, archive = NULL WHERE status = $2 AND expires_at <= NOW()`
+	result, err := r.Db.ExecContext(ctx, query, DataExportStatusExpired, DataExportStatusCompleted)
+	if err != nil {
+		return
+	}
+
+	affected, err := result.RowsAffected()
+	return int(affected), err
+}
+
+// Columns selected for every data export read, the archive is only read on download
+const dataExportColumns = `id, user_id, format, status, error, expires_at, completed_at, created_at`
+
+// Scan a single data_exports row selected with dataExportColumns
+func scanDataExport(row scanner) (output DataExport, err error) {
+	err = row.Scan(
+		&output.Id,
+		&output.UserId,
+		&output.Format,
+		&output.Status,
+		&output.Error,
+		&output.ExpiresAt,
+		&output.CompletedAt,
+		&output.CreatedAt,
+	)
+	return
+}
+
+// Columns selected for every api key read, keep in sync with scanApiKey
+const apiKeyColumns = `id, user_id, name, prefix, key_hash, scopes, expires_at, last_used_at, revoked_at, created_at`
+
+// Scan a single api_keys row selected with apiKeyColumns
+func scanApiKey(row scanner) (output ApiKey, err error) {
+	err = row.Scan(
+		&output.Id,
+		&output.UserId,
+		&output.Name,
+		&output.Prefix,
+		&output.KeyHash,
+		pq.Array(&output.Scopes),
+		&output.ExpiresAt,
+		&output.LastUsedAt,
+		&output.RevokedAt,
+		&output.CreatedAt,
+	)
+	return
+}
+
+// Common interface of *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// Scans columns selected after the ones a scanXxx helper knows about into dest
+type extraColumns struct {
+	row  scanner
+	dest []interface{}
+}
+
+func (e extraColumns) Scan(dest ...interface{}) error {
+	return e.row.Scan(append(dest, e.dest...)...)
+}
@@ -4,11 +4,76 @@
 // interfaces using mockgen. See the Makefile for more information.
 package repository
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type RepositoryInterface interface {
 	CreateUser(ctx context.Context, input CreateUserInput) (output User, err error)
+	CreateUsers(ctx context.Context, inputs []CreateUserInput, dryRun bool) (output []CreateUsersResult, err error)
 	UpdateUserById(ctx context.Context, input UpdateUserInput) (output User, err error)
 	GetUserById(ctx context.Context, id int) (output User, err error)
 	GetUserByPhoneNumber(ctx context.Context, phone string) (output User, err error)
+	GetUserByEmail(ctx context.Context, email string) (output User, err error)
+	ListUsers(ctx context.Context, input ListUsersInput) (output []User, err error)
+	ExportUsers(ctx context.Context, input ListUsersInput, each func(User) error) (err error)
+	UpdateUserStatus(ctx context.Context, input UpdateUserStatusInput) (output User, err error)
+	ListUserStatusEventsByUserId(ctx context.Context, userId int) (output []UserStatusEvent, err error)
+	SearchUsersByName(ctx context.Context, input SearchUsersInput) (output []UserMatch, err error)
+	DeleteUserById(ctx context.Context, id int) (output User, err error)
+	GetDeletedUserByPhoneNumber(ctx context.Context, phone string, deletedAfter time.Time) (output User, err error)
+	RestoreUserById(ctx context.Context, id int) (output User, err error)
+	PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (count int, avatars []string, err error)
+
+	ListAttributeDefinitions(ctx context.Context) (output []AttributeDefinition, err error)
+	PutAttributeDefinition(ctx context.Context, input AttributeDefinition) (output AttributeDefinition, err error)
+	DeleteAttributeDefinition(ctx context.Context, name string) (err error)
+
+	CreatePhoneChange(ctx context.Context, input CreatePhoneChangeInput) (output PhoneChange, err error)
+	GetPendingPhoneChange(ctx context.Context, userId int) (output PhoneChange, err error)
+	GetLatestPhoneChange(ctx context.Context, userId int) (output PhoneChange, err error)
+	IncrementPhoneChangeAttempts(ctx context.Context, id int, maxAttempts int) (attempts int, err error)
+	CompletePhoneChange(ctx context.Context, id int) (output User, err error)
+	CancelPhoneChange(ctx context.Context, userId int) (err error)
+	DeletePhoneChange(ctx context.Context, id int) (err error)
+
+	CreateEmailVerification(ctx context.Context, input CreateEmailVerificationInput) (output EmailVerification, err error)
+	GetPendingEmailVerification(ctx context.Context, userId int) (output EmailVerification, err error)
+	GetEmailVerificationByTokenHash(ctx context.Context, tokenHash string) (output EmailVerification, err error)
+	CompleteEmailVerification(ctx context.Context, id int) (output User, err error)
+	CancelEmailVerification(ctx context.Context, userId int) (err error)
+	RemoveUserEmail(ctx context.Context, userId int) (output User, err error)
+
+	CreateApiKey(ctx context.Context, input CreateApiKeyInput) (output ApiKey, err error)
+	ListApiKeysByUserId(ctx context.Context, userId int) (output []ApiKey, err error)
+	ListAllApiKeysByUserId(ctx context.Context, userId int) (output []ApiKey, err error)
+	GetApiKeyByHash(ctx context.Context, keyHash string) (output ApiKey, err error)
+	UpdateApiKeyName(ctx context.Context, input UpdateApiKeyInput) (output ApiKey, err error)
+	RevokeApiKey(ctx context.Context, userId int, id int) (err error)
+	TouchApiKey(ctx context.Context, id int) (err error)
+
+	CreateImpersonationEvent(ctx context.Context, input CreateImpersonationEventInput) (err error)
+	ListImpersonationEventsByTargetId(ctx context.Context, targetId int) (output []ImpersonationEvent, err error)
+
+	ListAuditEvents(ctx context.Context, input ListAuditEventsInput) (output []AuditEvent, err error)
+
+	CreateSession(ctx context.Context, input CreateSessionInput) (output Session, err error)
+	GetSessionById(ctx context.Context, id int) (output Session, err error)
+	ListSessionsByUserId(ctx context.Context, userId int) (output []Session, err error)
+	ListAllSessionsByUserId(ctx context.Context, userId int) (output []Session, err error)
+	RevokeSession(ctx context.Context, userId int, id int) (err error)
+	TouchSession(ctx context.Context, id int) (err error)
+
+	CreateLoginAttempt(ctx context.Context, input CreateLoginAttemptInput) (err error)
+	ListLoginAttemptsByUserId(ctx context.Context, input ListLoginAttemptsInput) (output []LoginAttempt, err error)
+	GetLoginDeviceStatus(ctx context.Context, userId int, deviceFingerprint string) (output LoginDeviceStatus, err error)
+
+	CreateDataExport(ctx context.Context, input CreateDataExportInput) (output DataExport, err error)
+	GetDataExportById(ctx context.Context, userId int, id int) (output DataExport, err error)
+	GetDataExportArchive(ctx context.Context, userId int, id int) (archive []byte, err error)
+	ClaimPendingDataExport(ctx context.Context, startedBefore time.Time) (output DataExport, err error)
+	CompleteDataExport(ctx context.Context, input CompleteDataExportInput) (err error)
+	FailDataExport(ctx context.Context, id int, reason string) (err error)
+	ExpireDataExports(ctx context.Context) (count int, err error)
 }
@@ -7,6 +7,7 @@ package repository
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 )
@@ -34,6 +35,196 @@ func (m *MockRepositoryInterface) EXPECT() *MockRepositoryInterfaceMockRecorder
 	return m.recorder
 }
 
+// CancelEmailVerification mocks base method.
+func (m *MockRepositoryInterface) CancelEmailVerification(ctx context.Context, userId int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelEmailVerification", ctx, userId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CancelEmailVerification indicates an expected call of CancelEmailVerification.
+func (mr *MockRepositoryInterfaceMockRecorder) CancelEmailVerification(ctx, userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelEmailVerification", reflect.TypeOf((*MockRepositoryInterface)(nil).CancelEmailVerification), ctx, userId)
+}
+
+// CancelPhoneChange mocks base method.
+func (m *MockRepositoryInterface) CancelPhoneChange(ctx context.Context, userId int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelPhoneChange", ctx, userId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CancelPhoneChange indicates an expected call of CancelPhoneChange.
+func (mr *MockRepositoryInterfaceMockRecorder) CancelPhoneChange(ctx, userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelPhoneChange", reflect.TypeOf((*MockRepositoryInterface)(nil).CancelPhoneChange), ctx, userId)
+}
+
+// ClaimPendingDataExport mocks base method.
+func (m *MockRepositoryInterface) ClaimPendingDataExport(ctx context.Context, startedBefore time.Time) (DataExport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimPendingDataExport", ctx, startedBefore)
+	ret0, _ := ret[0].(DataExport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimPendingDataExport indicates an expected call of ClaimPendingDataExport.
+func (mr *MockRepositoryInterfaceMockRecorder) ClaimPendingDataExport(ctx, startedBefore interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimPendingDataExport", reflect.TypeOf((*MockRepositoryInterface)(nil).ClaimPendingDataExport), ctx, startedBefore)
+}
+
+// CompleteDataExport mocks base method.
+func (m *MockRepositoryInterface) CompleteDataExport(ctx context.Context, input CompleteDataExportInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompleteDataExport", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CompleteDataExport indicates an expected call of CompleteDataExport.
+func (mr *MockRepositoryInterfaceMockRecorder) CompleteDataExport(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompleteDataExport", reflect.TypeOf((*MockRepositoryInterface)(nil).CompleteDataExport), ctx, input)
+}
+
+// CompleteEmailVerification mocks base method.
+func (m *MockRepositoryInterface) CompleteEmailVerification(ctx context.Context, id int) (User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompleteEmailVerification", ctx, id)
+	ret0, _ := ret[0].(User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CompleteEmailVerification indicates an expected call of CompleteEmailVerification.
+func (mr *MockRepositoryInterfaceMockRecorder) CompleteEmailVerification(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompleteEmailVerification", reflect.TypeOf((*MockRepositoryInterface)(nil).CompleteEmailVerification), ctx, id)
+}
+
+// CompletePhoneChange mocks base method.
+func (m *MockRepositoryInterface) CompletePhoneChange(ctx context.Context, id int) (User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompletePhoneChange", ctx, id)
+	ret0, _ := ret[0].(User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CompletePhoneChange indicates an expected call of CompletePhoneChange.
+func (mr *MockRepositoryInterfaceMockRecorder) CompletePhoneChange(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompletePhoneChange", reflect.TypeOf((*MockRepositoryInterface)(nil).CompletePhoneChange), ctx, id)
+}
+
+// CreateApiKey mocks base method.
+func (m *MockRepositoryInterface) CreateApiKey(ctx context.Context, input CreateApiKeyInput) (ApiKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateApiKey", ctx, input)
+	ret0, _ := ret[0].(ApiKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateApiKey indicates an expected call of CreateApiKey.
+func (mr *MockRepositoryInterfaceMockRecorder) CreateApiKey(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateApiKey", reflect.TypeOf((*MockRepositoryInterface)(nil).CreateApiKey), ctx, input)
+}
+
+// CreateDataExport mocks base method.
+func (m *MockRepositoryInterface) CreateDataExport(ctx context.Context, input CreateDataExportInput) (DataExport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateDataExport", ctx, input)
+	ret0, _ := ret[0].(DataExport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateDataExport indicates an expected call of CreateDataExport.
+func (mr *MockRepositoryInterfaceMockRecorder) CreateDataExport(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateDataExport", reflect.TypeOf((*MockRepositoryInterface)(nil).CreateDataExport), ctx, input)
+}
+
+// CreateEmailVerification mocks base method.
+func (m *MockRepositoryInterface) CreateEmailVerification(ctx context.Context, input CreateEmailVerificationInput) (EmailVerification, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateEmailVerification", ctx, input)
+	ret0, _ := ret[0].(EmailVerification)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateEmailVerification indicates an expected call of CreateEmailVerification.
+func (mr *MockRepositoryInterfaceMockRecorder) CreateEmailVerification(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateEmailVerification", reflect.TypeOf((*MockRepositoryInterface)(nil).CreateEmailVerification), ctx, input)
+}
+
+// CreateImpersonationEvent mocks base method.
+func (m *MockRepositoryInterface) CreateImpersonationEvent(ctx context.Context, input CreateImpersonationEventInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateImpersonationEvent", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateImpersonationEvent indicates an expected call of CreateImpersonationEvent.
+func (mr *MockRepositoryInterfaceMockRecorder) CreateImpersonationEvent(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateImpersonationEvent", reflect.TypeOf((*MockRepositoryInterface)(nil).CreateImpersonationEvent), ctx, input)
+}
+
+// CreateLoginAttempt mocks base method.
+func (m *MockRepositoryInterface) CreateLoginAttempt(ctx context.Context, input CreateLoginAttemptInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateLoginAttempt", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateLoginAttempt indicates an expected call of CreateLoginAttempt.
+func (mr *MockRepositoryInterfaceMockRecorder) CreateLoginAttempt(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateLoginAttempt", reflect.TypeOf((*MockRepositoryInterface)(nil).CreateLoginAttempt), ctx, input)
+}
+
+// CreatePhoneChange mocks base method.
+func (m *MockRepositoryInterface) CreatePhoneChange(ctx context.Context, input CreatePhoneChangeInput) (PhoneChange, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePhoneChange", ctx, input)
+	ret0, _ := ret[0].(PhoneChange)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreatePhoneChange indicates an expected call of CreatePhoneChange.
+func (mr *MockRepositoryInterfaceMockRecorder) CreatePhoneChange(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePhoneChange", reflect.TypeOf((*MockRepositoryInterface)(nil).CreatePhoneChange), ctx, input)
+}
+
+// CreateSession mocks base method.
+func (m *MockRepositoryInterface) CreateSession(ctx context.Context, input CreateSessionInput) (Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSession", ctx, input)
+	ret0, _ := ret[0].(Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateSession indicates an expected call of CreateSession.
+func (mr *MockRepositoryInterfaceMockRecorder) CreateSession(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSession", reflect.TypeOf((*MockRepositoryInterface)(nil).CreateSession), ctx, input)
+}
+
 // CreateUser mocks base method.
 func (m *MockRepositoryInterface) CreateUser(ctx context.Context, input CreateUserInput) (User, error) {
 	m.ctrl.T.Helper()
@@ -49,6 +240,272 @@ func (mr *MockRepositoryInterfaceMockRecorder) CreateUser(ctx, input interface{}
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockRepositoryInterface)(nil).CreateUser), ctx, input)
 }
 
+// CreateUsers mocks base method.
+func (m *MockRepositoryInterface) CreateUsers(ctx context.Context, inputs []CreateUserInput, dryRun bool) ([]CreateUsersResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateUsers", ctx, inputs, dryRun)
+	ret0, _ := ret[0].([]CreateUsersResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateUsers indicates an expected call of CreateUsers.
+func (mr *MockRepositoryInterfaceMockRecorder) CreateUsers(ctx, inputs, dryRun interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUsers", reflect.TypeOf((*MockRepositoryInterface)(nil).CreateUsers), ctx, inputs, dryRun)
+}
+
+// DeleteAttributeDefinition mocks base method.
+func (m *MockRepositoryInterface) DeleteAttributeDefinition(ctx context.Context, name string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteAttributeDefinition", ctx, name)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteAttributeDefinition indicates an expected call of DeleteAttributeDefinition.
+func (mr *MockRepositoryInterfaceMockRecorder) DeleteAttributeDefinition(ctx, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAttributeDefinition", reflect.TypeOf((*MockRepositoryInterface)(nil).DeleteAttributeDefinition), ctx, name)
+}
+
+// DeletePhoneChange mocks base method.
+func (m *MockRepositoryInterface) DeletePhoneChange(ctx context.Context, id int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeletePhoneChange", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeletePhoneChange indicates an expected call of DeletePhoneChange.
+func (mr *MockRepositoryInterfaceMockRecorder) DeletePhoneChange(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePhoneChange", reflect.TypeOf((*MockRepositoryInterface)(nil).DeletePhoneChange), ctx, id)
+}
+
+// DeleteUserById mocks base method.
+func (m *MockRepositoryInterface) DeleteUserById(ctx context.Context, id int) (User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUserById", ctx, id)
+	ret0, _ := ret[0].(User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteUserById indicates an expected call of DeleteUserById.
+func (mr *MockRepositoryInterfaceMockRecorder) DeleteUserById(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUserById", reflect.TypeOf((*MockRepositoryInterface)(nil).DeleteUserById), ctx, id)
+}
+
+// ExpireDataExports mocks base method.
+func (m *MockRepositoryInterface) ExpireDataExports(ctx context.Context) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpireDataExports", ctx)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExpireDataExports indicates an expected call of ExpireDataExports.
+func (mr *MockRepositoryInterfaceMockRecorder) ExpireDataExports(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpireDataExports", reflect.TypeOf((*MockRepositoryInterface)(nil).ExpireDataExports), ctx)
+}
+
+// ExportUsers mocks base method.
+func (m *MockRepositoryInterface) ExportUsers(ctx context.Context, input ListUsersInput, each func(User) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExportUsers", ctx, input, each)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExportUsers indicates an expected call of ExportUsers.
+func (mr *MockRepositoryInterfaceMockRecorder) ExportUsers(ctx, input, each interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportUsers", reflect.TypeOf((*MockRepositoryInterface)(nil).ExportUsers), ctx, input, each)
+}
+
+// FailDataExport mocks base method.
+func (m *MockRepositoryInterface) FailDataExport(ctx context.Context, id int, reason string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FailDataExport", ctx, id, reason)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FailDataExport indicates an expected call of FailDataExport.
+func (mr *MockRepositoryInterfaceMockRecorder) FailDataExport(ctx, id, reason interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FailDataExport", reflect.TypeOf((*MockRepositoryInterface)(nil).FailDataExport), ctx, id, reason)
+}
+
+// GetApiKeyByHash mocks base method.
+func (m *MockRepositoryInterface) GetApiKeyByHash(ctx context.Context, keyHash string) (ApiKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetApiKeyByHash", ctx, keyHash)
+	ret0, _ := ret[0].(ApiKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetApiKeyByHash indicates an expected call of GetApiKeyByHash.
+func (mr *MockRepositoryInterfaceMockRecorder) GetApiKeyByHash(ctx, keyHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetApiKeyByHash", reflect.TypeOf((*MockRepositoryInterface)(nil).GetApiKeyByHash), ctx, keyHash)
+}
+
+// GetDataExportArchive mocks base method.
+func (m *MockRepositoryInterface) GetDataExportArchive(ctx context.Context, userId, id int) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDataExportArchive", ctx, userId, id)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDataExportArchive indicates an expected call of GetDataExportArchive.
+func (mr *MockRepositoryInterfaceMockRecorder) GetDataExportArchive(ctx, userId, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDataExportArchive", reflect.TypeOf((*MockRepositoryInterface)(nil).GetDataExportArchive), ctx, userId, id)
+}
+
+// GetDataExportById mocks base method.
+func (m *MockRepositoryInterface) GetDataExportById(ctx context.Context, userId, id int) (DataExport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDataExportById", ctx, userId, id)
+	ret0, _ := ret[0].(DataExport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDataExportById indicates an expected call of GetDataExportById.
+func (mr *MockRepositoryInterfaceMockRecorder) GetDataExportById(ctx, userId, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDataExportById", reflect.TypeOf((*MockRepositoryInterface)(nil).GetDataExportById), ctx, userId, id)
+}
+
+// GetDeletedUserByPhoneNumber mocks base method.
+func (m *MockRepositoryInterface) GetDeletedUserByPhoneNumber(ctx context.Context, phone string, deletedAfter time.Time) (User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDeletedUserByPhoneNumber", ctx, phone, deletedAfter)
+	ret0, _ := ret[0].(User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDeletedUserByPhoneNumber indicates an expected call of GetDeletedUserByPhoneNumber.
+func (mr *MockRepositoryInterfaceMockRecorder) GetDeletedUserByPhoneNumber(ctx, phone, deletedAfter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeletedUserByPhoneNumber", reflect.TypeOf((*MockRepositoryInterface)(nil).GetDeletedUserByPhoneNumber), ctx, phone, deletedAfter)
+}
+
+// GetEmailVerificationByTokenHash mocks base method.
+func (m *MockRepositoryInterface) GetEmailVerificationByTokenHash(ctx context.Context, tokenHash string) (EmailVerification, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEmailVerificationByTokenHash", ctx, tokenHash)
+	ret0, _ := ret[0].(EmailVerification)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetEmailVerificationByTokenHash indicates an expected call of GetEmailVerificationByTokenHash.
+func (mr *MockRepositoryInterfaceMockRecorder) GetEmailVerificationByTokenHash(ctx, tokenHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEmailVerificationByTokenHash", reflect.TypeOf((*MockRepositoryInterface)(nil).GetEmailVerificationByTokenHash), ctx, tokenHash)
+}
+
+// GetLatestPhoneChange mocks base method.
+func (m *MockRepositoryInterface) GetLatestPhoneChange(ctx context.Context, userId int) (PhoneChange, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLatestPhoneChange", ctx, userId)
+	ret0, _ := ret[0].(PhoneChange)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLatestPhoneChange indicates an expected call of GetLatestPhoneChange.
+func (mr *MockRepositoryInterfaceMockRecorder) GetLatestPhoneChange(ctx, userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLatestPhoneChange", reflect.TypeOf((*MockRepositoryInterface)(nil).GetLatestPhoneChange), ctx, userId)
+}
+
+// GetLoginDeviceStatus mocks base method.
+func (m *MockRepositoryInterface) GetLoginDeviceStatus(ctx context.Context, userId int, deviceFingerprint string) (LoginDeviceStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLoginDeviceStatus", ctx, userId, deviceFingerprint)
+	ret0, _ := ret[0].(LoginDeviceStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLoginDeviceStatus indicates an expected call of GetLoginDeviceStatus.
+func (mr *MockRepositoryInterfaceMockRecorder) GetLoginDeviceStatus(ctx, userId, deviceFingerprint interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLoginDeviceStatus", reflect.TypeOf((*MockRepositoryInterface)(nil).GetLoginDeviceStatus), ctx, userId, deviceFingerprint)
+}
+
+// GetPendingEmailVerification mocks base method.
+func (m *MockRepositoryInterface) GetPendingEmailVerification(ctx context.Context, userId int) (EmailVerification, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPendingEmailVerification", ctx, userId)
+	ret0, _ := ret[0].(EmailVerification)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPendingEmailVerification indicates an expected call of GetPendingEmailVerification.
+func (mr *MockRepositoryInterfaceMockRecorder) GetPendingEmailVerification(ctx, userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPendingEmailVerification", reflect.TypeOf((*MockRepositoryInterface)(nil).GetPendingEmailVerification), ctx, userId)
+}
+
+// GetPendingPhoneChange mocks base method.
+func (m *MockRepositoryInterface) GetPendingPhoneChange(ctx context.Context, userId int) (PhoneChange, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPendingPhoneChange", ctx, userId)
+	ret0, _ := ret[0].(PhoneChange)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPendingPhoneChange indicates an expected call of GetPendingPhoneChange.
+func (mr *MockRepositoryInterfaceMockRecorder) GetPendingPhoneChange(ctx, userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPendingPhoneChange", reflect.TypeOf((*MockRepositoryInterface)(nil).GetPendingPhoneChange), ctx, userId)
+}
+
+// GetSessionById mocks base method.
+func (m *MockRepositoryInterface) GetSessionById(ctx context.Context, id int) (Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSessionById", ctx, id)
+	ret0, _ := ret[0].(Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSessionById indicates an expected call of GetSessionById.
+func (mr *MockRepositoryInterfaceMockRecorder) GetSessionById(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSessionById", reflect.TypeOf((*MockRepositoryInterface)(nil).GetSessionById), ctx, id)
+}
+
+// GetUserByEmail mocks base method.
+func (m *MockRepositoryInterface) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByEmail", ctx, email)
+	ret0, _ := ret[0].(User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByEmail indicates an expected call of GetUserByEmail.
+func (mr *MockRepositoryInterfaceMockRecorder) GetUserByEmail(ctx, email interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByEmail", reflect.TypeOf((*MockRepositoryInterface)(nil).GetUserByEmail), ctx, email)
+}
+
 // GetUserById mocks base method.
 func (m *MockRepositoryInterface) GetUserById(ctx context.Context, id int) (User, error) {
 	m.ctrl.T.Helper()
@@ -79,6 +536,318 @@ func (mr *MockRepositoryInterfaceMockRecorder) GetUserByPhoneNumber(ctx, phone i
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByPhoneNumber", reflect.TypeOf((*MockRepositoryInterface)(nil).GetUserByPhoneNumber), ctx, phone)
 }
 
+// IncrementPhoneChangeAttempts mocks base method.
+func (m *MockRepositoryInterface) IncrementPhoneChangeAttempts(ctx context.Context, id, maxAttempts int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrementPhoneChangeAttempts", ctx, id, maxAttempts)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IncrementPhoneChangeAttempts indicates an expected call of IncrementPhoneChangeAttempts.
+func (mr *MockRepositoryInterfaceMockRecorder) IncrementPhoneChangeAttempts(ctx, id, maxAttempts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementPhoneChangeAttempts", reflect.TypeOf((*MockRepositoryInterface)(nil).IncrementPhoneChangeAttempts), ctx, id, maxAttempts)
+}
+
+// ListAllApiKeysByUserId mocks base method.
+func (m *MockRepositoryInterface) ListAllApiKeysByUserId(ctx context.Context, userId int) ([]ApiKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAllApiKeysByUserId", ctx, userId)
+	ret0, _ := ret[0].([]ApiKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAllApiKeysByUserId indicates an expected call of ListAllApiKeysByUserId.
+func (mr *MockRepositoryInterfaceMockRecorder) ListAllApiKeysByUserId(ctx, userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAllApiKeysByUserId", reflect.TypeOf((*MockRepositoryInterface)(nil).ListAllApiKeysByUserId), ctx, userId)
+}
+
+// ListAllSessionsByUserId mocks base method.
+func (m *MockRepositoryInterface) ListAllSessionsByUserId(ctx context.Context, userId int) ([]Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAllSessionsByUserId", ctx, userId)
+	ret0, _ := ret[0].([]Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAllSessionsByUserId indicates an expected call of ListAllSessionsByUserId.
+func (mr *MockRepositoryInterfaceMockRecorder) ListAllSessionsByUserId(ctx, userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAllSessionsByUserId", reflect.TypeOf((*MockRepositoryInterface)(nil).ListAllSessionsByUserId), ctx, userId)
+}
+
+// ListApiKeysByUserId mocks base method.
+func (m *MockRepositoryInterface) ListApiKeysByUserId(ctx context.Context, userId int) ([]ApiKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListApiKeysByUserId", ctx, userId)
+	ret0, _ := ret[0].([]ApiKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListApiKeysByUserId indicates an expected call of ListApiKeysByUserId.
+func (mr *MockRepositoryInterfaceMockRecorder) ListApiKeysByUserId(ctx, userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListApiKeysByUserId", reflect.TypeOf((*MockRepositoryInterface)(nil).ListApiKeysByUserId), ctx, userId)
+}
+
+// ListAttributeDefinitions mocks base method.
+func (m *MockRepositoryInterface) ListAttributeDefinitions(ctx context.Context) ([]AttributeDefinition, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAttributeDefinitions", ctx)
+	ret0, _ := ret[0].([]AttributeDefinition)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAttributeDefinitions indicates an expected call of ListAttributeDefinitions.
+func (mr *MockRepositoryInterfaceMockRecorder) ListAttributeDefinitions(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAttributeDefinitions", reflect.TypeOf((*MockRepositoryInterface)(nil).ListAttributeDefinitions), ctx)
+}
+
+// ListAuditEvents mocks base method.
+func (m *MockRepositoryInterface) ListAuditEvents(ctx context.Context, input ListAuditEventsInput) ([]AuditEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAuditEvents", ctx, input)
+	ret0, _ := ret[0].([]AuditEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAuditEvents indicates an expected call of ListAuditEvents.
+func (mr *MockRepositoryInterfaceMockRecorder) ListAuditEvents(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAuditEvents", reflect.TypeOf((*MockRepositoryInterface)(nil).ListAuditEvents), ctx, input)
+}
+
+// ListImpersonationEventsByTargetId mocks base method.
+func (m *MockRepositoryInterface) ListImpersonationEventsByTargetId(ctx context.Context, targetId int) ([]ImpersonationEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListImpersonationEventsByTargetId", ctx, targetId)
+	ret0, _ := ret[0].([]ImpersonationEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListImpersonationEventsByTargetId indicates an expected call of ListImpersonationEventsByTargetId.
+func (mr *MockRepositoryInterfaceMockRecorder) ListImpersonationEventsByTargetId(ctx, targetId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListImpersonationEventsByTargetId", reflect.TypeOf((*MockRepositoryInterface)(nil).ListImpersonationEventsByTargetId), ctx, targetId)
+}
+
+// ListLoginAttemptsByUserId mocks base method.
+func (m *MockRepositoryInterface) ListLoginAttemptsByUserId(ctx context.Context, input ListLoginAttemptsInput) ([]LoginAttempt, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListLoginAttemptsByUserId", ctx, input)
+	ret0, _ := ret[0].([]LoginAttempt)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListLoginAttemptsByUserId indicates an expected call of ListLoginAttemptsByUserId.
+func (mr *MockRepositoryInterfaceMockRecorder) ListLoginAttemptsByUserId(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListLoginAttemptsByUserId", reflect.TypeOf((*MockRepositoryInterface)(nil).ListLoginAttemptsByUserId), ctx, input)
+}
+
+// ListSessionsByUserId mocks base method.
+func (m *MockRepositoryInterface) ListSessionsByUserId(ctx context.Context, userId int) ([]Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListSessionsByUserId", ctx, userId)
+	ret0, _ := ret[0].([]Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListSessionsByUserId indicates an expected call of ListSessionsByUserId.
+func (mr *MockRepositoryInterfaceMockRecorder) ListSessionsByUserId(ctx, userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSessionsByUserId", reflect.TypeOf((*MockRepositoryInterface)(nil).ListSessionsByUserId), ctx, userId)
+}
+
+// ListUserStatusEventsByUserId mocks base method.
+func (m *MockRepositoryInterface) ListUserStatusEventsByUserId(ctx context.Context, userId int) ([]UserStatusEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUserStatusEventsByUserId", ctx, userId)
+	ret0, _ := ret[0].([]UserStatusEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUserStatusEventsByUserId indicates an expected call of ListUserStatusEventsByUserId.
+func (mr *MockRepositoryInterfaceMockRecorder) ListUserStatusEventsByUserId(ctx, userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUserStatusEventsByUserId", reflect.TypeOf((*MockRepositoryInterface)(nil).ListUserStatusEventsByUserId), ctx, userId)
+}
+
+// ListUsers mocks base method.
+func (m *MockRepositoryInterface) ListUsers(ctx context.Context, input ListUsersInput) ([]User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUsers", ctx, input)
+	ret0, _ := ret[0].([]User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUsers indicates an expected call of ListUsers.
+func (mr *MockRepositoryInterfaceMockRecorder) ListUsers(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUsers", reflect.TypeOf((*MockRepositoryInterface)(nil).ListUsers), ctx, input)
+}
+
+// PurgeDeletedUsers mocks base method.
+func (m *MockRepositoryInterface) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int, []string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeDeletedUsers", ctx, deletedBefore)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].([]string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// PurgeDeletedUsers indicates an expected call of PurgeDeletedUsers.
+func (mr *MockRepositoryInterfaceMockRecorder) PurgeDeletedUsers(ctx, deletedBefore interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeDeletedUsers", reflect.TypeOf((*MockRepositoryInterface)(nil).PurgeDeletedUsers), ctx, deletedBefore)
+}
+
+// PutAttributeDefinition mocks base method.
+func (m *MockRepositoryInterface) PutAttributeDefinition(ctx context.Context, input AttributeDefinition) (AttributeDefinition, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PutAttributeDefinition", ctx, input)
+	ret0, _ := ret[0].(AttributeDefinition)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PutAttributeDefinition indicates an expected call of PutAttributeDefinition.
+func (mr *MockRepositoryInterfaceMockRecorder) PutAttributeDefinition(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutAttributeDefinition", reflect.TypeOf((*MockRepositoryInterface)(nil).PutAttributeDefinition), ctx, input)
+}
+
+// RemoveUserEmail mocks base method.
+func (m *MockRepositoryInterface) RemoveUserEmail(ctx context.Context, userId int) (User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveUserEmail", ctx, userId)
+	ret0, _ := ret[0].(User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RemoveUserEmail indicates an expected call of RemoveUserEmail.
+func (mr *MockRepositoryInterfaceMockRecorder) RemoveUserEmail(ctx, userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveUserEmail", reflect.TypeOf((*MockRepositoryInterface)(nil).RemoveUserEmail), ctx, userId)
+}
+
+// RestoreUserById mocks base method.
+func (m *MockRepositoryInterface) RestoreUserById(ctx context.Context, id int) (User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreUserById", ctx, id)
+	ret0, _ := ret[0].(User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RestoreUserById indicates an expected call of RestoreUserById.
+func (mr *MockRepositoryInterfaceMockRecorder) RestoreUserById(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreUserById", reflect.TypeOf((*MockRepositoryInterface)(nil).RestoreUserById), ctx, id)
+}
+
+// RevokeApiKey mocks base method.
+func (m *MockRepositoryInterface) RevokeApiKey(ctx context.Context, userId, id int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeApiKey", ctx, userId, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeApiKey indicates an expected call of RevokeApiKey.
+func (mr *MockRepositoryInterfaceMockRecorder) RevokeApiKey(ctx, userId, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeApiKey", reflect.TypeOf((*MockRepositoryInterface)(nil).RevokeApiKey), ctx, userId, id)
+}
+
+// RevokeSession mocks base method.
+func (m *MockRepositoryInterface) RevokeSession(ctx context.Context, userId, id int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeSession", ctx, userId, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeSession indicates an expected call of RevokeSession.
+func (mr *MockRepositoryInterfaceMockRecorder) RevokeSession(ctx, userId, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeSession", reflect.TypeOf((*MockRepositoryInterface)(nil).RevokeSession), ctx, userId, id)
+}
+
+// SearchUsersByName mocks base method.
+func (m *MockRepositoryInterface) SearchUsersByName(ctx context.Context, input SearchUsersInput) ([]UserMatch, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchUsersByName", ctx, input)
+	ret0, _ := ret[0].([]UserMatch)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchUsersByName indicates an expected call of SearchUsersByName.
+func (mr *MockRepositoryInterfaceMockRecorder) SearchUsersByName(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchUsersByName", reflect.TypeOf((*MockRepositoryInterface)(nil).SearchUsersByName), ctx, input)
+}
+
+// TouchApiKey mocks base method.
+func (m *MockRepositoryInterface) TouchApiKey(ctx context.Context, id int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TouchApiKey", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TouchApiKey indicates an expected call of TouchApiKey.
+func (mr *MockRepositoryInterfaceMockRecorder) TouchApiKey(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TouchApiKey", reflect.TypeOf((*MockRepositoryInterface)(nil).TouchApiKey), ctx, id)
+}
+
+// TouchSession mocks base method.
+func (m *MockRepositoryInterface) TouchSession(ctx context.Context, id int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TouchSession", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TouchSession indicates an expected call of TouchSession.
+func (mr *MockRepositoryInterfaceMockRecorder) TouchSession(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TouchSession", reflect.TypeOf((*MockRepositoryInterface)(nil).TouchSession), ctx, id)
+}
+
+// UpdateApiKeyName mocks base method.
+func (m *MockRepositoryInterface) UpdateApiKeyName(ctx context.Context, input UpdateApiKeyInput) (ApiKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateApiKeyName", ctx, input)
+	ret0, _ := ret[0].(ApiKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateApiKeyName indicates an expected call of UpdateApiKeyName.
+func (mr *MockRepositoryInterfaceMockRecorder) UpdateApiKeyName(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateApiKeyName", reflect.TypeOf((*MockRepositoryInterface)(nil).UpdateApiKeyName), ctx, input)
+}
+
 // UpdateUserById mocks base method.
 func (m *MockRepositoryInterface) UpdateUserById(ctx context.Context, input UpdateUserInput) (User, error) {
 	m.ctrl.T.Helper()
@@ -93,3 +862,18 @@ func (mr *MockRepositoryInterfaceMockRecorder) UpdateUserById(ctx, input interfa
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserById", reflect.TypeOf((*MockRepositoryInterface)(nil).UpdateUserById), ctx, input)
 }
+
+// UpdateUserStatus mocks base method.
+func (m *MockRepositoryInterface) UpdateUserStatus(ctx context.Context, input UpdateUserStatusInput) (User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserStatus", ctx, input)
+	ret0, _ := ret[0].(User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUserStatus indicates an expected call of UpdateUserStatus.
+func (mr *MockRepositoryInterfaceMockRecorder) UpdateUserStatus(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserStatus", reflect.TypeOf((*MockRepositoryInterface)(nil).UpdateUserStatus), ctx, input)
+}
@@ -3,10 +3,28 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
+	"strings"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
+// Returned when a write violates a unique constraint, e.g a phone number already taken
+var ErrConflict = errors.New("conflict")
+
+// Returned when a write gives a unique custom attribute a value another user has, or when an attribute
+// cannot be made unique because users share a value
+type AttributeTakenError struct {
+	Name string
+}
+
+func (e *AttributeTakenError) Error() string {
+	return "attribute " + e.Name + " taken"
+}
+
+// Returned by compare-and-swap updates when the row changed since it was read
+var ErrVersionMismatch = errors.New("version mismatch")
+
 type Repository struct {
 	Db *sql.DB
 }
@@ -24,3 +42,15 @@ func NewRepository(opts NewRepositoryOptions) *Repository {
 		Db: db,
 	}
 }
+
+// Translate driver errors the handler layer reacts to into repository errors
+func mapError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" { // unique_violation
+		if name := strings.TrimPrefix(pqErr.Constraint, attributeIndexPrefix); name != pqErr.Constraint {
+			return &AttributeTakenError{Name: name}
+		}
+		return ErrConflict
+	}
+	return err
+}
@@ -1,25 +1,433 @@
 // This file contains types that are used in the repository layer.
 package repository
 
-import "time"
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
 
 type CreateUserInput struct {
-	Name     string
-	Phone    string
-	Password string
+	Name       string
+	Phone      string
+	Password   string
+	Attributes Attributes
+}
+
+// Outcome of a single user of a CreateUsers batch, Id is 0 when the phone number or a unique attribute is taken
+type CreateUsersResult struct {
+	Id             int
+	Conflict       bool   // phone number taken
+	AttributeTaken string // name of the unique attribute whose value another user has
 }
 
 type UpdateUserInput struct {
-	Id    int
-	Name  string
-	Phone string
+	Id         int
+	Name       string
+	Phone      string
+	Locale     *string
+	Timezone   *string
+	Avatar     *string
+	Attributes Attributes
+	Version    int // the update only applies while the user is still at this version
 }
 
+// Roles a user can hold, stored in users.role
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type User struct {
-	Id        int
+	Id              int
+	Name            string
+	Phone           string
+	Email           *string // verified email, nil when the user has none
+	EmailVerifiedAt *time.Time
+	Locale          *string // preferred language of messages, nil to follow the client
+	Timezone        *string // IANA time zone times are shown in e.g Asia/Jakarta, nil to follow the client
+	Avatar          *string // blob key prefix of the avatar images, nil when the user has none
+	Attributes      Attributes
+	Password        string
+	Role            string
+	Status          string // lifecycle status, see UserStatusActive
+	TokenVersion    int
+	Version         int        // incremented on every update, see UpdateUserInput
+	DeletedAt       *time.Time // set while the account is pending deletion
+	PurgedAt        *time.Time // set once the account is anonymized
+	UpdatedAt       time.Time
+	CreatedAt       time.Time
+}
+
+// Lifecycle statuses stored in users.status, only active users can sign in
+const (
+	UserStatusActive    = "active"
+	UserStatusSuspended = "suspended" // blocked until reactivated
+	UserStatusDisabled  = "disabled"  // blocked for good
+)
+
+// Statuses derived from the deletion columns of users, they take precedence over users.status
+const (
+	UserStatusDeleted = "deleted" // pending deletion, can still be restored
+	UserStatusPurged  = "purged"
+)
+
+// Status shown to admins, an account pending deletion or purged reports that instead of its lifecycle status
+func (u User) EffectiveStatus() string {
+	if u.PurgedAt != nil {
+		return UserStatusPurged
+	} else if u.DeletedAt != nil {
+		return UserStatusDeleted
+	}
+	return u.Status
+}
+
+type UserStatusEvent struct {
+	Id         int
+	UserId     int
+	ActorId    int
+	FromStatus string
+	ToStatus   string
+	Reason     string
+	CreatedAt  time.Time
+}
+
+type UpdateUserStatusInput struct {
+	Id      int
+	ActorId int
+	From    string // the update only applies while the user still has this status
+	To      string
+	Reason  string
+}
+
+type SearchUsersInput struct {
+	Query    string
+	MinScore float64 // between 0 and 1, matches scoring lower are left out
+	Limit    int
+}
+
+// User matching a name search, score is 1 for an exact match
+type UserMatch struct {
+	User  User
+	Score float64
+}
+
+// Columns users can be listed by
+const (
+	UserSortCreatedAt = "created_at"
+	UserSortUpdatedAt = "updated_at"
+	UserSortName      = "name"
+)
+
+// Filters, order and page of a user listing, zero values leave a filter out
+type ListUsersInput struct {
+	NameContains  string
+	PhonePrefix   string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
+	Status        string
+	Role          string
+	Attributes    Attributes // users having every one of these values
+	SortBy        string
+	Descending    bool
+	After         *User // last user of the previous page, only its sort column and id are used
+	Limit         int
+}
+
+// Custom attributes of a user by name, values are strings, numbers (float64) or booleans as decoded from json.
+// Stored in the users.attributes jsonb column, nil is stored as no attributes
+type Attributes map[string]interface{}
+
+func (a Attributes) Value() (driver.Value, error) {
+	if a == nil {
+		return "{}", nil
+	}
+	// Sent as text, the driver would send []byte as bytea
+	encoded, err := json.Marshal(a)
+	return string(encoded), err
+}
+
+func (a *Attributes) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case []byte:
+		return json.Unmarshal(src, a)
+	case string:
+		return json.Unmarshal([]byte(src), a)
+	default:
+		return fmt.Errorf("cannot scan %T into Attributes", src)
+	}
+}
+
+// Types of attribute values
+const (
+	AttributeTypeString  = "string"
+	AttributeTypeNumber  = "number"
+	AttributeTypeBoolean = "boolean"
+)
+
+// Custom attribute admins define for every user, values are checked against it when they are written
+type AttributeDefinition struct {
+	Name          string // key in Attributes
+	Type          string // see AttributeTypeString
+	Required      bool
+	AllowedValues []interface{} // values accepted, nil accepts any value of the type
+	Unique        bool          // no two users can have the same value
+	Description   string
+	UpdatedAt     time.Time
+	CreatedAt     time.Time
+}
+
+type ApiKey struct {
+	Id         int
+	UserId     int
+	Name       string
+	Prefix     string // first characters of the key, shown to help users tell keys apart
+	KeyHash    string // sha256 of the full key, the key itself is never stored
+	Scopes     []string
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+}
+
+type CreateApiKeyInput struct {
+	UserId    int
 	Name      string
+	Prefix    string
+	KeyHash   string
+	Scopes    []string
+	ExpiresAt *time.Time
+}
+
+type UpdateApiKeyInput struct {
+	Id     int
+	UserId int
+	Name   string
+}
+
+// Actions recorded in impersonation_events
+const (
+	ImpersonationActionStart   = "start"   // admin was issued a token for the target
+	ImpersonationActionRequest = "request" // request made with an impersonation token
+)
+
+type CreateImpersonationEventInput struct {
+	ActorId   int
+	TargetId  int
+	Action    string
+	Reason    string
+	Method    string
+	Path      string
+	Status    int
+	Ip        string
+	UserAgent string
+}
+
+type Session struct {
+	Id         int
+	UserId     int
+	DeviceName string
+	UserAgent  string
+	Ip         string
+	ExpiresAt  time.Time
+	LastSeenAt time.Time
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+}
+
+type CreateSessionInput struct {
+	UserId     int
+	DeviceName string
+	UserAgent  string
+	Ip         string
+	ExpiresAt  time.Time
+}
+
+// Reasons recorded for failed login attempts
+const (
+	LoginReasonInvalidRequest  = "invalid_request"
+	LoginReasonUnknownPhone    = "unknown_phone"
+	LoginReasonUnknownEmail    = "unknown_email"
+	LoginReasonInvalidPassword = "invalid_password"
+	LoginReasonInactiveUser    = "inactive_user"
+)
+
+type LoginAttempt struct {
+	Id                int
+	UserId            *int // nil when the phone number is not registered
+	Phone             string
+	Success           bool
+	Reason            string
+	Ip                string
+	UserAgent         string
+	DeviceFingerprint string
+	CreatedAt         time.Time
+}
+
+type CreateLoginAttemptInput struct {
+	UserId            *int
+	Phone             string
+	Success           bool
+	Reason            string
+	Ip                string
+	UserAgent         string
+	DeviceFingerprint string
+}
+
+type ListLoginAttemptsInput struct {
+	UserId   int
+	Limit    int
+	BeforeId int // return attempts older than this id, 0 starts from the newest
+}
+
+type LoginDeviceStatus struct {
+	HasLoggedIn bool // user signed in successfully before
+	DeviceSeen  bool // user signed in successfully before from the device
+}
+
+type ImpersonationEvent struct {
+	Id        int
+	ActorId   int
+	TargetId  int
+	Action    string
+	Reason    string
+	Method    string
+	Path      string
+	Status    int
+	Ip        string
+	UserAgent string
+	CreatedAt time.Time
+}
+
+// Email waiting for the user to follow the link sent to it
+type EmailVerification struct {
+	Id         int
+	UserId     int
+	Email      string
+	TokenHash  string
+	ExpiresAt  time.Time
+	VerifiedAt *time.Time
+	CreatedAt  time.Time
+}
+
+type CreateEmailVerificationInput struct {
+	UserId    int
+	Email     string
+	TokenHash string
+	ExpiresAt time.Time
+}
+
+// Change of phone number waiting for the user to prove they hold the new number
+type PhoneChange struct {
+	Id          int
+	UserId      int
+	Phone       string
+	CodeHash    string
+	Attempts    int // wrong codes submitted so far
+	ExpiresAt   time.Time
+	VerifiedAt  *time.Time
+	CancelledAt *time.Time // set once cancelled or replaced by another change
+	CreatedAt   time.Time
+}
+
+type CreatePhoneChangeInput struct {
+	UserId    int
 	Phone     string
-	Password  string
-	UpdatedAt time.Time
+	CodeHash  string
+	ExpiresAt time.Time
+}
+
+// Actions recorded in audit_events, one per kind of write to users
+const (
+	AuditActionUserCreate       = "user.create"
+	AuditActionUserUpdate       = "user.update"
+	AuditActionUserStatusChange = "user.status_change"
+	AuditActionUserDelete       = "user.delete"
+	AuditActionUserRestore      = "user.restore"
+	AuditActionUserPurge        = "user.purge"
+)
+
+// Value stored in place of sensitive fields in audit events
+const AuditRedacted = "[REDACTED]"
+
+// Request a write is made for, every write records it in its audit event
+type AuditMetadata struct {
+	ActorId   int // authenticated caller, 0 for anonymous requests and background jobs
+	RequestId string
+	Ip        string
+}
+
+type auditMetadataKey struct{}
+
+// Attach audit metadata to ctx, the pointer is shared so the actor can be filled in once authenticated
+func WithAuditMetadata(ctx context.Context, metadata *AuditMetadata) context.Context {
+	return context.WithValue(ctx, auditMetadataKey{}, metadata)
+}
+
+// Audit metadata attached to ctx, nil when there is none
+func AuditMetadataFromContext(ctx context.Context) *AuditMetadata {
+	metadata, _ := ctx.Value(auditMetadataKey{}).(*AuditMetadata)
+	return metadata
+}
+
+type AuditEvent struct {
+	Id        int
+	ActorId   *int // nil for anonymous requests and background jobs
+	TargetId  int
+	Action    string
+	Before    json.RawMessage // audited fields before the write, nil on create
+	After     json.RawMessage // audited fields after the write
+	RequestId string
+	Ip        string
 	CreatedAt time.Time
 }
+
+type ListAuditEventsInput struct {
+	TargetId      int // 0 lists events of every user
+	ActorId       int // 0 lists events of every actor
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	Limit         int
+	BeforeId      int // return events older than this id, 0 starts from the newest
+}
+
+// Statuses of a data export job
+const (
+	DataExportStatusPending   = "pending"
+	DataExportStatusRunning   = "running"
+	DataExportStatusCompleted = "completed"
+	DataExportStatusFailed    = "failed"
+	DataExportStatusExpired   = "expired"
+)
+
+// Archive formats of a data export
+const (
+	DataExportFormatJSON = "json"
+	DataExportFormatZIP  = "zip"
+)
+
+type DataExport struct {
+	Id          int
+	UserId      int
+	Format      string
+	Status      string
+	Error       string
+	ExpiresAt   *time.Time // set once completed, the archive is dropped afterwards
+	CompletedAt *time.Time
+	CreatedAt   time.Time
+}
+
+type CreateDataExportInput struct {
+	UserId int
+	Format string
+}
+
+type CompleteDataExportInput struct {
+	Id        int
+	Archive   []byte
+	ExpiresAt time.Time
+}
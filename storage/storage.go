@@ -0,0 +1,83 @@
+// This file contains the storage layer.
+// Blob stores keep the files clients download, e.g avatars, outside of the database.
+// Implementations are pluggable so local runs can keep files on disk instead of a bucket.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Keys are slash separated relative paths, e.g avatars/1/5f2b/128.jpg
+var ErrInvalidKey = errors.New("invalid blob key")
+
+type BlobStore interface {
+	Put(ctx context.Context, key string, contentType string, body io.Reader) (err error)
+	DeletePrefix(ctx context.Context, prefix string) (err error) // delete every blob under prefix/, none is not an error
+	URL(key string) string                                       // where clients download the blob
+}
+
+// LocalStore keeps blobs as files under Dir, served by the application under BaseURL, used for local runs
+// and single instance deployments
+type LocalStore struct {
+	Dir     string
+	BaseURL string
+}
+
+func NewLocalStore(dir string, baseURL string) *LocalStore {
+	return &LocalStore{Dir: dir, BaseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// Write to a temporary file renamed once complete, so a blob is never served half written
+func (s *LocalStore) Put(ctx context.Context, key string, contentType string, body io.Reader) (err error) {
+	name, err := s.path(key)
+	if err != nil {
+		return
+	}
+	if err = os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(name), ".put-*")
+	if err != nil {
+		return
+	}
+	defer os.Remove(file.Name())
+
+	if _, err = io.Copy(file, body); err != nil {
+		file.Close()
+		return
+	}
+	if err = file.Close(); err != nil {
+		return
+	}
+	if err = os.Chmod(file.Name(), 0o644); err != nil {
+		return
+	}
+	return os.Rename(file.Name(), name)
+}
+
+func (s *LocalStore) DeletePrefix(ctx context.Context, prefix string) (err error) {
+	name, err := s.path(prefix)
+	if err != nil {
+		return
+	}
+	return os.RemoveAll(name)
+}
+
+func (s *LocalStore) URL(key string) string {
+	return s.BaseURL + "/" + key
+}
+
+// File of a key, keys escaping Dir are refused
+func (s *LocalStore) path(key string) (string, error) {
+	if key == "" || path.IsAbs(key) || path.Clean(key) != key || strings.HasPrefix(key, "../") || key == ".." {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(s.Dir, filepath.FromSlash(key)), nil
+}
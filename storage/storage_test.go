@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+TestLocalStore Criteria:
+- Put writes the blob under Dir, replacing a previous one, without leaving temporary files
+- DeletePrefix deletes every blob under the prefix only, a missing prefix is not an error
+- URL is the key under BaseURL
+*/
+func TestLocalStore(t *testing.T) {
+	dir := t.TempDir()
+	store := NewLocalStore(dir, "https://cdn.example.com/blobs/")
+	ctx := context.Background()
+
+	for _, key := range []string{"avatars/1/a/128.jpg", "avatars/1/a/512.jpg", "avatars/2/b/128.jpg"} {
+		assert.NoError(t, store.Put(ctx, key, "image/jpeg", strings.NewReader("old "+key)))
+	}
+	assert.NoError(t, store.Put(ctx, "avatars/1/a/128.jpg", "image/jpeg", strings.NewReader("new")))
+
+	content, err := os.ReadFile(filepath.Join(dir, "avatars", "1", "a", "128.jpg"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "new", string(content))
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, "avatars", "1", "a"))
+	if assert.NoError(t, err) {
+		assert.Len(t, entries, 2)
+	}
+
+	assert.NoError(t, store.DeletePrefix(ctx, "avatars/1/a"))
+	assert.NoDirExists(t, filepath.Join(dir, "avatars", "1", "a"))
+	assert.FileExists(t, filepath.Join(dir, "avatars", "2", "b", "128.jpg"))
+	assert.NoError(t, store.DeletePrefix(ctx, "avatars/3"))
+
+	assert.Equal(t, "https://cdn.example.com/blobs/avatars/2/b/128.jpg", store.URL("avatars/2/b/128.jpg"))
+}
+
+/*
+TestLocalStoreInvalidKey Criteria:
+- Empty, absolute, unclean and escaping keys are refused before touching the disk
+*/
+func TestLocalStoreInvalidKey(t *testing.T) {
+	dir := t.TempDir()
+	store := NewLocalStore(filepath.Join(dir, "blobs"), "/blobs")
+	ctx := context.Background()
+
+	for _, key := range []string{"", "/etc/passwd", "..", "../outside", "avatars/../../outside", "avatars//1", "avatars/1/"} {
+		assert.ErrorIs(t, store.Put(ctx, key, "text/plain", strings.NewReader("x")), ErrInvalidKey, key)
+		assert.ErrorIs(t, store.DeletePrefix(ctx, key), ErrInvalidKey, key)
+	}
+	assert.NoFileExists(t, filepath.Join(dir, "outside"))
+}
@@ -0,0 +1,75 @@
+package validation
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/AthanatiusC/SawitPro/i18n"
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+/*
+Derive the rules of the string properties of an object schema of api.yml, so a constraint is declared once
+for the documentation and the validation.
+- required lists the required fields
+- minLength and maxLength become Length, MinLength or MaxLength
+- format email becomes Email, other formats are left to the handlers
+- pattern becomes Pattern, reporting the pattern itself
+- enum becomes OneOf
+
+Fields are sorted by name, Validate reports them in the order of the request anyway
+*/
+func FromOpenAPI(schema *openapi3.Schema) Schema {
+	if schema == nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(schema.Properties))
+	for name, property := range schema.Properties {
+		if property != nil && property.Value != nil && property.Value.Type == openapi3.TypeString {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	fields := make(Schema, len(names))
+	for i, name := range names {
+		fields[i] = Field{Name: name, Rules: StringRules(schema.Properties[name].Value)}
+		for _, required := range schema.Required {
+			fields[i].Required = fields[i].Required || required == name
+		}
+	}
+	return fields
+}
+
+// Rules of the constraints of a string schema, also used to explain values api.yml refused
+func StringRules(schema *openapi3.Schema) (rules []Rule) {
+	if schema.Format == "email" {
+		rules = append(rules, Email())
+	}
+
+	min := int(schema.MinLength)
+	switch {
+	case schema.MaxLength != nil && min > 0:
+		rules = append(rules, Length(min, int(*schema.MaxLength)))
+	case schema.MaxLength != nil:
+		rules = append(rules, MaxLength(int(*schema.MaxLength)))
+	case min > 0:
+		rules = append(rules, MinLength(min))
+	}
+
+	if schema.Pattern != "" {
+		// api.yml is ours, an invalid pattern is a defect that must stop the service at startup
+		rules = append(rules, Pattern(regexp.MustCompile(schema.Pattern), i18n.Pattern, schema.Pattern))
+	}
+
+	if len(schema.Enum) != 0 {
+		values := make([]string, len(schema.Enum))
+		for i, value := range schema.Enum {
+			values[i] = fmt.Sprint(value)
+		}
+		rules = append(rules, OneOf(values...))
+	}
+	return
+}
@@ -0,0 +1,73 @@
+package validation
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/AthanatiusC/SawitPro/i18n"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+TestFromOpenAPI Criteria:
+- String properties become fields sorted by name, required as listed, other types are left out
+- Each constraint becomes its rule: format email, minLength and maxLength, pattern and enum
+- A nil schema has no fields
+*/
+func TestFromOpenAPI(t *testing.T) {
+	var schema openapi3.Schema
+	err := json.Unmarshal([]byte(`{
+		"type": "object",
+		"required": ["full_name", "email"],
+		"properties": {
+			"full_name": {"type": "string", "minLength": 3, "maxLength": 60},
+			"email": {"type": "string", "format": "email", "maxLength": 254},
+			"device_name": {"type": "string", "maxLength": 10},
+			"password": {"type": "string", "minLength": 6},
+			"code": {"type": "string", "pattern": "^[0-9]{6}$"},
+			"format": {"type": "string", "enum": ["csv", "ndjson"]},
+			"note": {"type": "string"},
+			"limit": {"type": "integer", "maximum": 100},
+			"scopes": {"type": "array", "items": {"type": "string"}}
+		}
+	}`), &schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields := FromOpenAPI(&schema)
+	var names []string
+	for _, field := range fields {
+		names = append(names, field.Name)
+	}
+	assert.Equal(t, []string{"code", "device_name", "email", "format", "full_name", "note", "password"}, names)
+
+	testCases := []struct {
+		field    string
+		value    string
+		required bool
+		errors   Errors
+	}{
+		{"full_name", "", true, Errors{NewError("full_name", i18n.Required, "full_name")}},
+		{"full_name", "Budi", true, nil},
+		{"full_name", "Bu", true, Errors{NewError("full_name", i18n.LengthBetween, 3, 60)}},
+		{"email", "budi@example.com", true, nil},
+		{"email", "budi", true, Errors{NewError("email", i18n.InvalidEmail)}},
+		{"device_name", "", false, nil},
+		{"device_name", "field tablet", false, Errors{NewError("device_name", i18n.MaxLength, 10)}},
+		{"password", "short", false, Errors{NewError("password", i18n.MinLength, 6)}},
+		{"code", "123456", false, nil},
+		{"code", "12345a", false, Errors{NewError("code", i18n.Pattern, "^[0-9]{6}$")}},
+		{"format", "ndjson", false, nil},
+		{"format", "xml", false, Errors{NewError("format", i18n.OneOf, "csv, ndjson")}},
+		{"note", "anything goes", false, nil},
+	}
+	for _, tc := range testCases {
+		field := fields[fields.index(tc.field)]
+		assert.Equal(t, tc.required, field.Required, tc.field)
+		assert.Equal(t, tc.errors, field.Check(tc.value), "%s %q", tc.field, tc.value)
+	}
+
+	assert.Nil(t, FromOpenAPI(nil))
+}
@@ -0,0 +1,115 @@
+package validation
+
+import (
+	"errors"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/AthanatiusC/SawitPro/i18n"
+	"github.com/AthanatiusC/SawitPro/phonenumber"
+)
+
+// Value has min to max characters
+func Length(min, max int) Rule {
+	return func(value string, report Report) {
+		if length := utf8.RuneCountInString(value); length < min || length > max {
+			report(i18n.LengthBetween, min, max)
+		}
+	}
+}
+
+// Value has at most max characters
+func MaxLength(max int) Rule {
+	return func(value string, report Report) {
+		if utf8.RuneCountInString(value) > max {
+			report(i18n.MaxLength, max)
+		}
+	}
+}
+
+// Value has at least min characters
+func MinLength(min int) Rule {
+	return func(value string, report Report) {
+		if utf8.RuneCountInString(value) < min {
+			report(i18n.MinLength, min)
+		}
+	}
+}
+
+// Value matches pattern, reported with code and args so the message can say what is expected
+func Pattern(pattern *regexp.Regexp, code i18n.Code, args ...interface{}) Rule {
+	return func(value string, report Report) {
+		if !pattern.MatchString(value) {
+			report(code, args...)
+		}
+	}
+}
+
+// Value is one of values
+func OneOf(values ...string) Rule {
+	return func(value string, report Report) {
+		for _, allowed := range values {
+			if value == allowed {
+				return
+			}
+		}
+		report(i18n.OneOf, strings.Join(values, ", "))
+	}
+}
+
+// Value is a bare email address, display names and comments are valid in mail headers but not as an identifier
+func Email() Rule {
+	return func(value string, report Report) {
+		address, err := mail.ParseAddress(value)
+		if err != nil || address.Name != "" || address.Address != value {
+			report(i18n.InvalidEmail)
+		}
+	}
+}
+
+// Value is a phone number of a region allowed by parser, in any format it accepts
+func Phone(parser *phonenumber.Parser) Rule {
+	return func(value string, report Report) {
+		_, err := parser.Parse(value)
+		var callingCodeErr *phonenumber.CallingCodeError
+		var lengthErr *phonenumber.LengthError
+		switch {
+		case err == nil:
+		case errors.As(err, &callingCodeErr):
+			report(i18n.PhoneCountryCode, strings.Join(callingCodeErr.CallingCodes, ", "))
+		case errors.As(err, &lengthErr):
+			region := lengthErr.Region
+			report(i18n.PhoneLength, region.MinLength, region.MaxLength, region.CallingCode, region.Name)
+		default:
+			report(i18n.PhoneCharacters)
+		}
+	}
+}
+
+// Value is an IANA time zone e.g Asia/Jakarta, the local zone of the server is not one
+func Timezone() Rule {
+	return func(value string, report Report) {
+		if _, err := time.LoadLocation(value); err != nil || value == "Local" {
+			report(i18n.InvalidTimezone)
+		}
+	}
+}
+
+// Golang regexp does not support lookaround, each class of the password policy is its own pattern
+var (
+	passwordSpecial = regexp.MustCompile(`[!@#$%^&*()_+\[\]{};':"\|,.<>?]`)
+	passwordCapital = regexp.MustCompile(`[A-Z]`)
+	passwordNumber  = regexp.MustCompile(`[0-9]`)
+)
+
+// Value has at least a special character, a capital and a number, each missing class is reported
+func Password() Rule {
+	return func(value string, report Report) {
+		Pattern(passwordSpecial, i18n.PasswordSpecial)(value, report)
+		Pattern(passwordCapital, i18n.PasswordCapital)(value, report)
+		Pattern(passwordNumber, i18n.PasswordNumber)(value, report)
+	}
+}
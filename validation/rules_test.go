@@ -0,0 +1,70 @@
+package validation
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/AthanatiusC/SawitPro/i18n"
+	"github.com/AthanatiusC/SawitPro/phonenumber"
+	"github.com/stretchr/testify/assert"
+)
+
+// Codes and args a rule reports for value, in order
+func check(rule Rule, value string) (reports []*Error) {
+	rule(value, func(code i18n.Code, args ...interface{}) {
+		reports = append(reports, &Error{Code: code, Args: args})
+	})
+	return
+}
+
+/*
+TestRules Criteria:
+- Each rule accepts the values it allows and reports the others with their code and message args
+- Lengths count characters, not bytes
+- Password reports every missing character class
+*/
+func TestRules(t *testing.T) {
+	phoneNumbers, err := phonenumber.NewParser("ID", "MY")
+	if err != nil {
+		t.Fatal(err)
+	}
+	region := phonenumber.Regions["ID"]
+
+	testCases := []struct {
+		name    string
+		rule    Rule
+		value   string
+		reports []*Error
+	}{
+		{"length within", Length(3, 5), "abc", nil},
+		{"length counts characters", Length(3, 5), "ñañañ", nil},
+		{"length too short", Length(3, 5), "ab", []*Error{{Code: i18n.LengthBetween, Args: []interface{}{3, 5}}}},
+		{"length too long", Length(3, 5), "abcdef", []*Error{{Code: i18n.LengthBetween, Args: []interface{}{3, 5}}}},
+		{"max length at max", MaxLength(3), "abc", nil},
+		{"max length over", MaxLength(3), "abcd", []*Error{{Code: i18n.MaxLength, Args: []interface{}{3}}}},
+		{"min length at min", MinLength(3), "abc", nil},
+		{"min length under", MinLength(3), "ab", []*Error{{Code: i18n.MinLength, Args: []interface{}{3}}}},
+		{"pattern match", Pattern(regexp.MustCompile(`^[a-z]+$`), i18n.Pattern, "^[a-z]+$"), "abc", nil},
+		{"pattern mismatch", Pattern(regexp.MustCompile(`^[a-z]+$`), i18n.Pattern, "^[a-z]+$"), "ABC", []*Error{{Code: i18n.Pattern, Args: []interface{}{"^[a-z]+$"}}}},
+		{"one of allowed", OneOf("csv", "ndjson"), "csv", nil},
+		{"one of other", OneOf("csv", "ndjson"), "xml", []*Error{{Code: i18n.OneOf, Args: []interface{}{"csv, ndjson"}}}},
+		{"email bare", Email(), "budi@example.com", nil},
+		{"email display name", Email(), "Budi <budi@example.com>", []*Error{{Code: i18n.InvalidEmail}}},
+		{"email padded", Email(), " budi@example.com", []*Error{{Code: i18n.InvalidEmail}}},
+		{"email without domain", Email(), "budi", []*Error{{Code: i18n.InvalidEmail}}},
+		{"phone national", Phone(phoneNumbers), "0812-3456-7890", nil},
+		{"phone other region", Phone(phoneNumbers), "+6581234567", []*Error{{Code: i18n.PhoneCountryCode, Args: []interface{}{"+62, +60"}}}},
+		{"phone length", Phone(phoneNumbers), "+62812345", []*Error{{Code: i18n.PhoneLength, Args: []interface{}{region.MinLength, region.MaxLength, region.CallingCode, region.Name}}}},
+		{"phone letters", Phone(phoneNumbers), "0812 budi", []*Error{{Code: i18n.PhoneCharacters}}},
+		{"timezone iana", Timezone(), "Asia/Jakarta", nil},
+		{"timezone utc", Timezone(), "UTC", nil},
+		{"timezone unknown", Timezone(), "Asia/Bandung", []*Error{{Code: i18n.InvalidTimezone}}},
+		{"timezone local", Timezone(), "Local", []*Error{{Code: i18n.InvalidTimezone}}},
+		{"password strong", Password(), "Passw0rd!", nil},
+		{"password without special", Password(), "Passw0rd", []*Error{{Code: i18n.PasswordSpecial}}},
+		{"password lower case only", Password(), "password", []*Error{{Code: i18n.PasswordSpecial}, {Code: i18n.PasswordCapital}, {Code: i18n.PasswordNumber}}},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.reports, check(tc.rule, tc.value), tc.name)
+	}
+}
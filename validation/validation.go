@@ -0,0 +1,157 @@
+// This file contains the validation layer.
+// Requests are checked against a Schema, the rules each field must follow, declared once and shared by endpoints.
+package validation
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/AthanatiusC/SawitPro/i18n"
+)
+
+// Failure of one field, localized only once the response is written
+type Error struct {
+	Field string
+	Code  i18n.Code
+	Args  []interface{}
+}
+
+func NewError(field string, code i18n.Code, args ...interface{}) *Error {
+	return &Error{Field: field, Code: code, Args: args}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s : %s", e.Field, i18n.Message(i18n.English, e.Code, e.Args...))
+}
+
+// Failures of a request in the order they were found
+type Errors []*Error
+
+func (e *Errors) Add(field string, code i18n.Code, args ...interface{}) {
+	*e = append(*e, NewError(field, code, args...))
+}
+
+// Report a failure of the value a Rule is checking, args are the arguments of the message of code
+type Report func(code i18n.Code, args ...interface{})
+
+// Rule checks a value that is present and reports every failure, any function with this signature is a rule
+type Rule func(value string, report Report)
+
+// Field of a request with the rules its value must follow, Name is the json name of the field
+type Field struct {
+	Name     string
+	Required bool
+	Rules    []Rule
+}
+
+// Field that must be present, a missing field only reports that it is required
+func Required(name string, rules ...Rule) Field {
+	return Field{Name: name, Required: true, Rules: rules}
+}
+
+// Field whose rules are only checked when it is present
+func Optional(name string, rules ...Rule) Field {
+	return Field{Name: name, Rules: rules}
+}
+
+// Schema of the fields of requests, a field appears once
+type Schema []Field
+
+/*
+Combine two schemas, fields of other are added after the fields of s.
+A field of both schemas is required when either requires it and follows the rules of s then of other,
+so constraints derived from api.yml can be completed by rules only code can express
+*/
+func (s Schema) Merge(other Schema) Schema {
+	merged := make(Schema, len(s), len(s)+len(other))
+	copy(merged, s)
+	for _, field := range other {
+		if i := merged.index(field.Name); i != -1 {
+			merged[i].Required = merged[i].Required || field.Required
+			merged[i].Rules = append(append([]Rule{}, merged[i].Rules...), field.Rules...)
+		} else {
+			merged = append(merged, field)
+		}
+	}
+	return merged
+}
+
+// Fields of the schema with the given names, for requests that only accept some of them
+func (s Schema) Only(names ...string) Schema {
+	var only Schema
+	for _, field := range s {
+		for _, name := range names {
+			if field.Name == name {
+				only = append(only, field)
+			}
+		}
+	}
+	return only
+}
+
+// Same schema with every field optional, for requests that only carry the fields being changed
+func (s Schema) Optional() Schema {
+	optional := make(Schema, len(s))
+	for i, field := range s {
+		field.Required = false
+		optional[i] = field
+	}
+	return optional
+}
+
+/*
+Validate a request struct, fields are matched by their json name.
+- string and *string fields are checked, empty strings and nil pointers are missing
+- fields the schema does not know are skipped, so one schema serves every request carrying its fields
+- failures are returned in the order of the fields of request
+*/
+func (s Schema) Validate(request interface{}) (errors Errors) {
+	v := reflect.Indirect(reflect.ValueOf(request))
+	for i := 0; i < v.NumField(); i++ {
+		name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("json"), ",")
+		j := s.index(name)
+		if j == -1 {
+			continue
+		}
+
+		value := v.Field(i)
+		if value.Kind() == reflect.Pointer {
+			if value.IsNil() {
+				errors = append(errors, s[j].Check("")...)
+				continue
+			}
+			value = value.Elem()
+		}
+		if value.Kind() == reflect.String {
+			errors = append(errors, s[j].Check(value.String())...)
+		}
+	}
+	return
+}
+
+// Check a value of the field, empty is missing
+func (f Field) Check(value string) (errors Errors) {
+	if value == "" {
+		if f.Required {
+			errors.Add(f.Name, i18n.Required, f.Name)
+		}
+		return
+	}
+
+	for _, rule := range f.Rules {
+		rule(value, func(code i18n.Code, args ...interface{}) {
+			errors.Add(f.Name, code, args...)
+		})
+	}
+	return
+}
+
+func (s Schema) index(name string) int {
+	for i, field := range s {
+		if field.Name == name {
+			return i
+		}
+	}
+	return -1
+}
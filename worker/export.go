@@ -0,0 +1,422 @@
+package worker
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/AthanatiusC/SawitPro/repository"
+)
+
+// Rows read per query while collecting the login history and audit events
+const exportPageSize = 500
+
+// Exporter builds the archives of requested data exports and drops them once expired
+type Exporter struct {
+	Repository repository.RepositoryInterface
+	TTL        time.Duration
+	Timeout    time.Duration // a running export started longer ago is assumed abandoned and claimed again
+	Interval   time.Duration
+	Logger     *log.Logger
+}
+
+type NewExporterOptions struct {
+	Repository repository.RepositoryInterface
+	TTL        time.Duration
+	Timeout    time.Duration
+	Interval   time.Duration
+	Logger     *log.Logger
+}
+
+// Time an archive stays downloadable when no TTL is configured
+const defaultExportTTL = 72 * time.Hour
+
+// Time a claimed export may run when no timeout is configured, archives hold a single user and build in seconds
+const defaultExportTimeout = 30 * time.Minute
+
+func NewExporter(opts NewExporterOptions) *Exporter {
+	if opts.TTL == 0 {
+		opts.TTL = defaultExportTTL
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = defaultExportTimeout
+	}
+
+	return &Exporter{
+		Repository: opts.Repository,
+		TTL:        opts.TTL,
+		Timeout:    opts.Timeout,
+		Interval:   opts.Interval,
+		Logger:     opts.Logger,
+	}
+}
+
+// Everything held about a user, the password hash and api key hashes are left out on purpose
+type exportDocument struct {
+	Profile      exportProfile        `json:"profile"`
+	Sessions     []exportSession      `json:"sessions"`
+	LoginHistory []exportLoginAttempt `json:"login_history"`
+	ApiKeys      []exportApiKey       `json:"api_keys"`
+	AuditEvents  []exportAuditEvent   `json:"audit_events"`
+	Activity     []exportActivity     `json:"activity"`
+	GeneratedAt  time.Time            `json:"generated_at"`
+}
+
+type exportProfile struct {
+	Id         int                   `json:"id"`
+	FullName   string                `json:"full_name"`
+	Phone      string                `json:"phone_number"`
+	Email      *string               `json:"email,omitempty"`
+	Locale     *string               `json:"locale,omitempty"`
+	Timezone   *string               `json:"timezone,omitempty"`
+	Avatar     *string               `json:"avatar,omitempty"` // blob key prefix the avatar images are stored under
+	Attributes repository.Attributes `json:"attributes"`
+	Role       string                `json:"role"`
+	Status     string                `json:"status"`
+	DeletedAt  *time.Time            `json:"deleted_at,omitempty"`
+	UpdatedAt  time.Time             `json:"updated_at"`
+	CreatedAt  time.Time             `json:"created_at"`
+}
+
+type exportSession struct {
+	DeviceName string     `json:"device_name"`
+	UserAgent  string     `json:"user_agent"`
+	Ip         string     `json:"ip"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type exportLoginAttempt struct {
+	Success   bool      `json:"success"`
+	Reason    string    `json:"reason"`
+	Ip        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type exportApiKey struct {
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Actions taken on the account by someone else, e.g support staff impersonating the user or suspending the account
+type exportAuditEvent struct {
+	ActorId   int             `json:"actor_id"`
+	Action    string          `json:"action"`
+	Reason    string          `json:"reason,omitempty"`
+	Method    string          `json:"method,omitempty"`
+	Path      string          `json:"path,omitempty"`
+	Status    int             `json:"status,omitempty"`
+	From      string          `json:"from,omitempty"`
+	To        string          `json:"to,omitempty"`
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Writes the user made, to their own account or as an admin to other accounts
+type exportActivity struct {
+	TargetId  int             `json:"target_id"`
+	Action    string          `json:"action"`
+	Before    json.RawMessage `json:"before,omitempty"` // only for writes to the user's own account, other
+	After     json.RawMessage `json:"after,omitempty"`  // accounts' fields are not the user's data
+	Ip        string          `json:"ip"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Run processes pending exports on every interval until ctx is done, the first run happens immediately
+func (e *Exporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.Interval)
+	defer ticker.Stop()
+
+	for {
+		count, err := e.Repository.ExpireDataExports(ctx)
+		if err != nil {
+			e.Logger.Printf("expire data exports failed: %v", err)
+		} else if count != 0 {
+			e.Logger.Printf("expired %d data exports", count)
+		}
+
+		for e.processNext(ctx) {
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Claim and build a single pending export, returns false once nothing is left to claim. Exports left running by a
+// worker that stopped are claimed again once their timeout is over
+func (e *Exporter) processNext(ctx context.Context) bool {
+	export, err := e.Repository.ClaimPendingDataExport(ctx, time.Now().Add(-e.Timeout))
+	if err == sql.ErrNoRows {
+		return false
+	} else if err != nil {
+		e.Logger.Printf("claim data export failed: %v", err)
+		return false
+	}
+
+	archive, err := e.Build(ctx, export)
+	if err != nil {
+		e.Logger.Printf("data export %d failed: %v", export.Id, err)
+		e.fail(ctx, export.Id, "archive could not be generated")
+		return true
+	}
+
+	err = e.Repository.CompleteDataExport(ctx, repository.CompleteDataExportInput{
+		Id:        export.Id,
+		Archive:   archive,
+		ExpiresAt: time.Now().Add(e.TTL),
+	})
+	if err != nil {
+		e.Logger.Printf("complete data export %d failed: %v", export.Id, err)
+		e.fail(ctx, export.Id, "archive could not be saved")
+	}
+	return true
+}
+
+// Mark an export failed so the user can request another, an export that cannot even be marked stays running until
+// its timeout and is claimed again
+func (e *Exporter) fail(ctx context.Context, id int, reason string) {
+	if err := e.Repository.FailDataExport(ctx, id, reason); err != nil {
+		e.Logger.Printf("mark data export %d failed: %v", id, err)
+	}
+}
+
+// Build collects the data of the export's user into an archive of the export's format
+func (e *Exporter) Build(ctx context.Context, export repository.DataExport) ([]byte, error) {
+	document, err := e.collect(ctx, export.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if export.Format == repository.DataExportFormatZIP {
+		return zipDocument(document)
+	}
+	return json.MarshalIndent(document, "", "  ")
+}
+
+func (e *Exporter) collect(ctx context.Context, userId int) (document exportDocument, err error) {
+	user, err := e.Repository.GetUserById(ctx, userId)
+	if err != nil {
+		return
+	}
+	document.Profile = exportProfile{
+		Id:         user.Id,
+		FullName:   user.Name,
+		Phone:      user.Phone,
+		Email:      user.Email,
+		Locale:     user.Locale,
+		Timezone:   user.Timezone,
+		Avatar:     user.Avatar,
+		Attributes: user.Attributes,
+		Role:       user.Role,
+		Status:     user.Status,
+		DeletedAt:  user.DeletedAt,
+		UpdatedAt:  user.UpdatedAt,
+		CreatedAt:  user.CreatedAt,
+	}
+
+	// Every session ever opened, revoked and expired ones included
+	sessions, err := e.Repository.ListAllSessionsByUserId(ctx, userId)
+	if err != nil {
+		return
+	}
+	document.Sessions = []exportSession{}
+	for _, session := range sessions {
+		document.Sessions = append(document.Sessions, exportSession{
+			DeviceName: session.DeviceName,
+			UserAgent:  session.UserAgent,
+			Ip:         session.Ip,
+			ExpiresAt:  session.ExpiresAt,
+			LastSeenAt: session.LastSeenAt,
+			RevokedAt:  session.RevokedAt,
+			CreatedAt:  session.CreatedAt,
+		})
+	}
+
+	document.LoginHistory = []exportLoginAttempt{}
+	input := repository.ListLoginAttemptsInput{UserId: userId, Limit: exportPageSize}
+	for {
+		var attempts []repository.LoginAttempt
+		attempts, err = e.Repository.ListLoginAttemptsByUserId(ctx, input)
+		if err != nil {
+			return
+		}
+		for _, attempt := range attempts {
+			document.LoginHistory = append(document.LoginHistory, exportLoginAttempt{
+				Success:   attempt.Success,
+				Reason:    attempt.Reason,
+				Ip:        attempt.Ip,
+				UserAgent: attempt.UserAgent,
+				CreatedAt: attempt.CreatedAt,
+			})
+		}
+		if len(attempts) < input.Limit {
+			break
+		}
+		input.BeforeId = attempts[len(attempts)-1].Id
+	}
+
+	apiKeys, err := e.Repository.ListAllApiKeysByUserId(ctx, userId)
+	if err != nil {
+		return
+	}
+	document.ApiKeys = []exportApiKey{}
+	for _, key := range apiKeys {
+		document.ApiKeys = append(document.ApiKeys, exportApiKey{
+			Name:       key.Name,
+			Prefix:     key.Prefix,
+			Scopes:     key.Scopes,
+			ExpiresAt:  key.ExpiresAt,
+			LastUsedAt: key.LastUsedAt,
+			RevokedAt:  key.RevokedAt,
+			CreatedAt:  key.CreatedAt,
+		})
+	}
+
+	events, err := e.Repository.ListImpersonationEventsByTargetId(ctx, userId)
+	if err != nil {
+		return
+	}
+	document.AuditEvents = []exportAuditEvent{}
+	for _, event := range events {
+		document.AuditEvents = append(document.AuditEvents, exportAuditEvent{
+			ActorId:   event.ActorId,
+			Action:    event.Action,
+			Reason:    event.Reason,
+			Method:    event.Method,
+			Path:      event.Path,
+			Status:    event.Status,
+			CreatedAt: event.CreatedAt,
+		})
+	}
+
+	statusEvents, err := e.Repository.ListUserStatusEventsByUserId(ctx, userId)
+	if err != nil {
+		return
+	}
+	for _, event := range statusEvents {
+		document.AuditEvents = append(document.AuditEvents, exportAuditEvent{
+			ActorId:   event.ActorId,
+			Action:    "status_change",
+			Reason:    event.Reason,
+			From:      event.FromStatus,
+			To:        event.ToStatus,
+			CreatedAt: event.CreatedAt,
+		})
+	}
+
+	// Status changes are already listed with their reason, writes the user made themselves are not actions of someone else
+	auditInput := repository.ListAuditEventsInput{TargetId: userId, Limit: exportPageSize}
+	for {
+		var auditEvents []repository.AuditEvent
+		auditEvents, err = e.Repository.ListAuditEvents(ctx, auditInput)
+		if err != nil {
+			return
+		}
+		for _, event := range auditEvents {
+			if event.ActorId == nil || *event.ActorId == userId || event.Action == repository.AuditActionUserStatusChange {
+				continue
+			}
+			document.AuditEvents = append(document.AuditEvents, exportAuditEvent{
+				ActorId:   *event.ActorId,
+				Action:    event.Action,
+				Before:    event.Before,
+				After:     event.After,
+				CreatedAt: event.CreatedAt,
+			})
+		}
+		if len(auditEvents) < auditInput.Limit {
+			break
+		}
+		auditInput.BeforeId = auditEvents[len(auditEvents)-1].Id
+	}
+
+	sort.SliceStable(document.AuditEvents, func(i, j int) bool {
+		return document.AuditEvents[i].CreatedAt.Before(document.AuditEvents[j].CreatedAt)
+	})
+
+	document.Activity = []exportActivity{}
+	activityInput := repository.ListAuditEventsInput{ActorId: userId, Limit: exportPageSize}
+	for {
+		var auditEvents []repository.AuditEvent
+		auditEvents, err = e.Repository.ListAuditEvents(ctx, activityInput)
+		if err != nil {
+			return
+		}
+		for _, event := range auditEvents {
+			activity := exportActivity{
+				TargetId:  event.TargetId,
+				Action:    event.Action,
+				Ip:        event.Ip,
+				CreatedAt: event.CreatedAt,
+			}
+			if event.TargetId == userId {
+				activity.Before, activity.After = event.Before, event.After
+			}
+			document.Activity = append(document.Activity, activity)
+		}
+		if len(auditEvents) < activityInput.Limit {
+			break
+		}
+		activityInput.BeforeId = auditEvents[len(auditEvents)-1].Id
+	}
+	// Listed newest first, the archive reads oldest first like the audit events
+	for i, j := 0, len(document.Activity)-1; i < j; i, j = i+1, j-1 {
+		document.Activity[i], document.Activity[j] = document.Activity[j], document.Activity[i]
+	}
+
+	document.GeneratedAt = time.Now()
+	return document, nil
+}
+
+// Split the document into one json file per section
+func zipDocument(document exportDocument) ([]byte, error) {
+	files := []struct {
+		name    string
+		content interface{}
+	}{
+		{"profile.json", document.Profile},
+		{"sessions.json", document.Sessions},
+		{"login_history.json", document.LoginHistory},
+		{"api_keys.json", document.ApiKeys},
+		{"audit_events.json", document.AuditEvents},
+		{"activity.json", document.Activity},
+	}
+
+	var buffer bytes.Buffer
+	writer := zip.NewWriter(&buffer)
+	for _, file := range files {
+		w, err := writer.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: document.GeneratedAt})
+		if err != nil {
+			return nil, err
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.content); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
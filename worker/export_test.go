@@ -0,0 +1,139 @@
+package worker
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/AthanatiusC/SawitPro/repository"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// Expect every read of collect for a user holding one row of each kind
+func expectCollect(repo *repository.MockRepositoryInterface, user repository.User) {
+	revokedAt := time.Now().Add(-time.Hour)
+	otherId := user.Id + 1
+	repo.EXPECT().GetUserById(gomock.Any(), user.Id).Return(user, nil)
+	repo.EXPECT().ListAllSessionsByUserId(gomock.Any(), user.Id).
+		Return([]repository.Session{{Id: 1, UserId: user.Id, DeviceName: "old phone", RevokedAt: &revokedAt}}, nil)
+	repo.EXPECT().ListLoginAttemptsByUserId(gomock.Any(), repository.ListLoginAttemptsInput{UserId: user.Id, Limit: exportPageSize}).
+		Return([]repository.LoginAttempt{{Id: 1, UserId: &user.Id, Success: true}}, nil)
+	repo.EXPECT().ListAllApiKeysByUserId(gomock.Any(), user.Id).
+		Return([]repository.ApiKey{{Id: 1, UserId: user.Id, Name: "ci", RevokedAt: &revokedAt}}, nil)
+	repo.EXPECT().ListImpersonationEventsByTargetId(gomock.Any(), user.Id).Return(nil, nil)
+	repo.EXPECT().ListUserStatusEventsByUserId(gomock.Any(), user.Id).Return(nil, nil)
+	repo.EXPECT().ListAuditEvents(gomock.Any(), repository.ListAuditEventsInput{TargetId: user.Id, Limit: exportPageSize}).
+		Return([]repository.AuditEvent{{Id: 2, ActorId: &otherId, TargetId: user.Id, Action: "user.update"}}, nil)
+	repo.EXPECT().ListAuditEvents(gomock.Any(), repository.ListAuditEventsInput{ActorId: user.Id, Limit: exportPageSize}).
+		Return([]repository.AuditEvent{
+			{Id: 4, ActorId: &user.Id, TargetId: otherId, Action: "user.update", After: json.RawMessage(`{"name":"other"}`)},
+			{Id: 3, ActorId: &user.Id, TargetId: user.Id, Action: "user.update", After: json.RawMessage(`{"name":"user"}`)},
+		}, nil)
+}
+
+/*
+TestExporterProcessNext Criteria:
+- Exports are claimed with abandoned running exports started before the timeout
+- A built archive holds the locale, time zone and avatar key and custom attributes of the profile, revoked sessions and api keys, actions of others and writes of the user, and completes the export
+- An export whose archive cannot be built or saved is marked failed
+- Nothing is processed once no export is left to claim
+*/
+func TestExporterProcessNext(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	exporter := NewExporter(NewExporterOptions{Repository: repo, Interval: time.Minute, Logger: log.New(io.Discard, "", 0)})
+	ctx := context.Background()
+
+	locale, timezone, avatar := "id", "Asia/Jakarta", "avatars/1/0123456789abcdef"
+	user := repository.User{Id: 1, Name: "user", Status: repository.UserStatusActive, Locale: &locale, Timezone: &timezone, Avatar: &avatar,
+		Attributes: repository.Attributes{"estate": "Riau 2"}}
+	claim := func(export repository.DataExport, err error) {
+		repo.EXPECT().ClaimPendingDataExport(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, startedBefore time.Time) (repository.DataExport, error) {
+				assert.WithinDuration(t, time.Now().Add(-defaultExportTimeout), startedBefore, time.Minute)
+				return export, err
+			})
+	}
+
+	claim(repository.DataExport{Id: 5, UserId: user.Id, Format: repository.DataExportFormatJSON}, nil)
+	expectCollect(repo, user)
+	var archive []byte
+	repo.EXPECT().CompleteDataExport(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, input repository.CompleteDataExportInput) error {
+			assert.Equal(t, 5, input.Id)
+			assert.WithinDuration(t, time.Now().Add(defaultExportTTL), input.ExpiresAt, time.Minute)
+			archive = input.Archive
+			return nil
+		})
+	assert.True(t, exporter.processNext(ctx))
+
+	var document exportDocument
+	if assert.NoError(t, json.Unmarshal(archive, &document)) {
+		assert.Equal(t, "user", document.Profile.FullName)
+		assert.Equal(t, &locale, document.Profile.Locale)
+		assert.Equal(t, &timezone, document.Profile.Timezone)
+		assert.Equal(t, &avatar, document.Profile.Avatar)
+		assert.Equal(t, repository.Attributes{"estate": "Riau 2"}, document.Profile.Attributes)
+		assert.Len(t, document.Sessions, 1)
+		assert.Len(t, document.ApiKeys, 1)
+		assert.Len(t, document.AuditEvents, 1)
+		if assert.Len(t, document.Activity, 2) {
+			assert.Equal(t, user.Id, document.Activity[0].TargetId)
+			assert.JSONEq(t, `{"name":"user"}`, string(document.Activity[0].After))
+			assert.Nil(t, document.Activity[1].After)
+		}
+	}
+
+	claim(repository.DataExport{Id: 6, UserId: user.Id, Format: repository.DataExportFormatZIP}, nil)
+	expectCollect(repo, user)
+	repo.EXPECT().CompleteDataExport(gomock.Any(), gomock.Any()).Return(errors.New("connection reset"))
+	repo.EXPECT().FailDataExport(gomock.Any(), 6, "archive could not be saved").Return(nil)
+	assert.True(t, exporter.processNext(ctx))
+
+	claim(repository.DataExport{Id: 7, UserId: user.Id, Format: repository.DataExportFormatJSON}, nil)
+	repo.EXPECT().GetUserById(gomock.Any(), user.Id).Return(repository.User{}, errors.New("connection reset"))
+	repo.EXPECT().FailDataExport(gomock.Any(), 7, "archive could not be generated").Return(nil)
+	assert.True(t, exporter.processNext(ctx))
+
+	claim(repository.DataExport{}, sql.ErrNoRows)
+	assert.False(t, exporter.processNext(ctx))
+}
+
+/*
+TestExporterBuildZIP Criteria:
+- Zip archives hold one json file per section
+*/
+func TestExporterBuildZIP(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	exporter := NewExporter(NewExporterOptions{Repository: repo})
+
+	user := repository.User{Id: 1, Name: "user", Status: repository.UserStatusActive}
+	expectCollect(repo, user)
+	archive, err := exporter.Build(context.Background(), repository.DataExport{Id: 5, UserId: user.Id, Format: repository.DataExportFormatZIP})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if !assert.NoError(t, err) {
+		return
+	}
+	var names []string
+	for _, file := range reader.File {
+		names = append(names, file.Name)
+	}
+	assert.Equal(t, []string{"profile.json", "sessions.json", "login_history.json", "api_keys.json", "audit_events.json", "activity.json"}, names)
+}
@@ -0,0 +1,67 @@
+// This file contains background jobs.
+// Jobs run on an interval next to the HTTP server and only talk to the repository layer.
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/AthanatiusC/SawitPro/repository"
+	"github.com/AthanatiusC/SawitPro/storage"
+)
+
+// Purger permanently anonymizes accounts once their deletion grace period is over
+type Purger struct {
+	Repository  repository.RepositoryInterface
+	Blobs       storage.BlobStore // avatars of purged accounts are deleted from it
+	GracePeriod time.Duration
+	Interval    time.Duration
+	Logger      *log.Logger
+}
+
+type NewPurgerOptions struct {
+	Repository  repository.RepositoryInterface
+	Blobs       storage.BlobStore
+	GracePeriod time.Duration
+	Interval    time.Duration
+	Logger      *log.Logger
+}
+
+func NewPurger(opts NewPurgerOptions) *Purger {
+	return &Purger{
+		Repository:  opts.Repository,
+		Blobs:       opts.Blobs,
+		GracePeriod: opts.GracePeriod,
+		Interval:    opts.Interval,
+		Logger:      opts.Logger,
+	}
+}
+
+// Run purges on every interval until ctx is done, the first purge happens immediately
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for {
+		count, avatars, err := p.Repository.PurgeDeletedUsers(ctx, time.Now().Add(-p.GracePeriod))
+		if err != nil {
+			p.Logger.Printf("purge deleted users failed: %v", err)
+		} else if count != 0 {
+			p.Logger.Printf("purged %d deleted users", count)
+		}
+
+		// Accounts are already anonymized, a failed delete only leaves files nothing references
+		for _, avatar := range avatars {
+			if err := p.Blobs.DeletePrefix(ctx, avatar); err != nil {
+				p.Logger.Printf("delete avatar %s of purged user failed: %v", avatar, err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
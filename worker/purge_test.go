@@ -0,0 +1,63 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/AthanatiusC/SawitPro/repository"
+	"github.com/AthanatiusC/SawitPro/storage"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+TestPurgerRun Criteria:
+- Accounts deleted before the grace period are purged on the first run
+- Avatars of purged accounts are deleted, other avatars are kept
+- A failed purge is logged and deletes nothing
+*/
+func TestPurgerRun(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	dir := t.TempDir()
+	for _, avatar := range []string{"avatars/1/a", "avatars/2/b", "avatars/3/c"} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(dir, avatar), 0o755))
+	}
+
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	purger := NewPurger(NewPurgerOptions{
+		Repository:  repo,
+		Blobs:       storage.NewLocalStore(dir, "/blobs"),
+		GracePeriod: 30 * 24 * time.Hour,
+		Interval:    time.Hour,
+		Logger:      log.New(io.Discard, "", 0),
+	})
+
+	// Run stops after its first purge once ctx is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	gomock.InOrder(
+		repo.EXPECT().PurgeDeletedUsers(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, deletedBefore time.Time) (int, []string, error) {
+				assert.WithinDuration(t, time.Now().Add(-purger.GracePeriod), deletedBefore, time.Minute)
+				return 2, []string{"avatars/1/a", "avatars/2/b"}, nil
+			}),
+		repo.EXPECT().PurgeDeletedUsers(gomock.Any(), gomock.Any()).Return(0, nil, errors.New("connection refused")),
+	)
+
+	purger.Run(ctx)
+	assert.NoDirExists(t, filepath.Join(dir, "avatars/1/a"))
+	assert.NoDirExists(t, filepath.Join(dir, "avatars/2/b"))
+	assert.DirExists(t, filepath.Join(dir, "avatars/3/c"))
+
+	purger.Run(ctx)
+	assert.DirExists(t, filepath.Join(dir, "avatars/3/c"))
+}